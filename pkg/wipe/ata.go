@@ -0,0 +1,264 @@
+package wipe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// sgIO is the SG_IO ioctl, which sends a SCSI command, here an ATA
+	// PASS-THROUGH(16), to a disk.
+	sgIO = 0x2285
+
+	// Data transfer directions of sg_io_hdr
+	sgDxferNone    = -1
+	sgDxferToDev   = -2
+	sgDxferFromDev = -3
+
+	// ATA PASS-THROUGH(16) protocols
+	ataProtocolNonData = 3
+	ataProtocolPIOIn   = 4
+	ataProtocolPIOOut  = 5
+
+	// ATA commands
+	ataIdentifyDevice      = 0xec
+	ataSecuritySetPassword = 0xf1
+	ataSecurityErasePrep   = 0xf3
+	ataSecurityEraseUnit   = 0xf4
+
+	// Bits of IDENTIFY DEVICE word 128, the security status
+	ataSecuritySupported = 1 << 0
+	ataSecurityEnabled   = 1 << 1
+	ataSecurityLocked    = 1 << 2
+	ataSecurityFrozen    = 1 << 3
+	ataSecurityEnhanced  = 1 << 5
+
+	// ataErasePassword is the temporary user password set for the erase;
+	// the drive clears it when the erase completes.
+	ataErasePassword = "quickwipe"
+
+	// ataDefaultEraseTimeout is how long the erase may take when the drive
+	// doesn't give an estimate.
+	ataDefaultEraseTimeout = 24 * time.Hour
+)
+
+// Wipe methods recorded in Result.Method for ATA drives.
+const (
+	methodATASecureErase         = "ata-secure-erase"
+	methodATAEnhancedSecureErase = "ata-enhanced-secure-erase"
+)
+
+// errSecurityFrozen is returned when the drive's security feature set is
+// frozen, as most BIOSes do at boot.
+var errSecurityFrozen = errors.New("ATA security is frozen; suspend and resume the machine (e.g. echo mem > /sys/power/state) or hot-plug the drive to unfreeze it, then try again")
+
+// sgIOHdr mirrors struct sg_io_hdr from <scsi/sg.h>.
+type sgIOHdr struct {
+	InterfaceID    int32
+	DxferDirection int32
+	CmdLen         uint8
+	MxSbLen        uint8
+	IovecCount     uint16
+	DxferLen       uint32
+	Dxferp         uintptr
+	Cmdp           uintptr
+	Sbp            uintptr
+	Timeout        uint32
+	Flags          uint32
+	PackID         int32
+	UsrPtr         uintptr
+	Status         uint8
+	MaskedStatus   uint8
+	MsgStatus      uint8
+	SbLenWr        uint8
+	HostStatus     uint16
+	DriverStatus   uint16
+	Resid          int32
+	Duration       uint32
+	Info           uint32
+}
+
+// ataCommand sends the ATA command cmd through file. data is the sector
+// read with ataProtocolPIOIn or written with ataProtocolPIOOut, or nil.
+func ataCommand(file *os.File, cmd uint8, protocol uint8, data []byte, timeout time.Duration) error {
+	cdb := [16]byte{0: 0x85, 1: protocol << 1, 6: 1, 14: cmd}
+	direction := int32(sgDxferNone)
+	switch protocol {
+	case ataProtocolPIOIn:
+		cdb[2] = 0x0e // T_DIR from the device, BYT_BLOK, length in the sector count
+		direction = sgDxferFromDev
+	case ataProtocolPIOOut:
+		cdb[2] = 0x06 // T_DIR to the device, BYT_BLOK, length in the sector count
+		direction = sgDxferToDev
+	default:
+		cdb[6] = 0
+	}
+	hdr, _, err := sgExecute(file, cdb, direction, data, timeout)
+	if err != nil {
+		return err
+	}
+	if hdr.Status != 0 || hdr.HostStatus != 0 || hdr.DriverStatus != 0 {
+		return fmt.Errorf("ATA command %#x failed (SCSI status %#x, host %#x, driver %#x)",
+			cmd, hdr.Status, hdr.HostStatus, hdr.DriverStatus)
+	}
+	return nil
+}
+
+// sgExecute sends cdb through file with SG_IO and returns the completed
+// header and the sense data.
+func sgExecute(file *os.File, cdb [16]byte, direction int32, data []byte, timeout time.Duration) (sgIOHdr, [32]byte, error) {
+	var sense [32]byte
+	hdr := sgIOHdr{
+		InterfaceID:    'S',
+		DxferDirection: direction,
+		CmdLen:         uint8(len(cdb)),
+		MxSbLen:        uint8(len(sense)),
+		Cmdp:           uintptr(unsafe.Pointer(&cdb[0])),
+		Sbp:            uintptr(unsafe.Pointer(&sense[0])),
+		Timeout:        uint32(timeout / time.Millisecond),
+	}
+	if len(data) > 0 {
+		hdr.DxferLen, hdr.Dxferp = uint32(len(data)), uintptr(unsafe.Pointer(&data[0]))
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), sgIO, uintptr(unsafe.Pointer(&hdr)))
+	runtime.KeepAlive(data)
+	runtime.KeepAlive(&cdb)
+	runtime.KeepAlive(&sense)
+	if errno != 0 {
+		return hdr, sense, classifyErrno(errno)
+	}
+	return hdr, sense, nil
+}
+
+// ataPasswordSector builds the 512-byte data sector of the security
+// commands: the control word followed by the 32-byte password.
+func ataPasswordSector(control uint16) []byte {
+	sector := make([]byte, 512)
+	binary.LittleEndian.PutUint16(sector, control)
+	copy(sector[2:34], ataErasePassword)
+	return sector
+}
+
+// isATADisk reports whether device is a whole SCSI-attached disk such as
+// /dev/sda, which SATA drives show up as.
+func isATADisk(device string) bool {
+	name := blockDeviceName(device)
+	return strings.HasPrefix(name, "sd") && parentDiskName(name) == name && !isRegularFile(device)
+}
+
+// ataErase erases a SATA drive with ATA SECURITY ERASE UNIT, which the
+// drive's firmware carries out on all of its blocks, including remapped
+// ones. The enhanced erase is used when the drive supports it. Devices that
+// are not ATA drives or lack the security feature set are overwritten
+// instead.
+func ataErase(ctx context.Context, job wipeJob, run runInfo, report ProgressFunc) (Result, error) {
+	result, err := ataSecureErase(ctx, job, report)
+	if errors.Is(err, ErrUnsupported) {
+		fmt.Printf("%s: %v, overwriting instead\n", job.Device, err)
+		return wipePasses(ctx, job, nil, run, report)
+	}
+	return result, err
+}
+
+func ataSecureErase(ctx context.Context, job wipeJob, report ProgressFunc) (Result, error) {
+	if !isATADisk(job.Device) {
+		return Result{}, fmt.Errorf("%w: not a whole ATA disk", ErrUnsupported)
+	}
+	file, err := os.OpenFile(job.Device, os.O_RDWR, 0)
+	if err != nil {
+		return Result{}, newDeviceError("open", job.Device, err)
+	}
+	defer file.Close()
+
+	identify := make([]byte, 512)
+	if err := ataCommand(file, ataIdentifyDevice, ataProtocolPIOIn, identify, time.Minute); err != nil {
+		return Result{}, fmt.Errorf("%w: IDENTIFY DEVICE through ATA pass-through failed: %w", ErrUnsupported, err)
+	}
+	word := func(n int) uint16 { return binary.LittleEndian.Uint16(identify[2*n:]) }
+	security := word(128)
+	switch {
+	case security&ataSecuritySupported == 0:
+		return Result{}, fmt.Errorf("%w: the drive has no ATA security feature set", ErrUnsupported)
+	case security&ataSecurityFrozen != 0:
+		return Result{}, newDeviceError("secure erase", job.Device, errSecurityFrozen)
+	case security&(ataSecurityEnabled|ataSecurityLocked) != 0:
+		return Result{}, newDeviceError("secure erase", job.Device,
+			errors.New("the drive already has a security password; disable it first (hdparm --security-disable)"))
+	}
+
+	// Words 89 and 90 estimate the normal and enhanced erase in units of 2
+	// minutes
+	method, control, estimate := methodATASecureErase, uint16(0), word(89)
+	if security&ataSecurityEnhanced != 0 {
+		method, control, estimate = methodATAEnhancedSecureErase, 0x2, word(90)
+	}
+	expected := time.Duration(estimate&0x7fff) * 2 * time.Minute
+	timeout := ataDefaultEraseTimeout
+	if expected > 0 {
+		timeout = max(2*expected, time.Hour)
+	}
+
+	start := time.Now()
+	if err := ataCommand(file, ataSecuritySetPassword, ataProtocolPIOOut, ataPasswordSector(0), time.Minute); err != nil {
+		return Result{}, newDeviceError("set security password", job.Device, err)
+	}
+	if err := ataCommand(file, ataSecurityErasePrep, ataProtocolNonData, nil, time.Minute); err != nil {
+		return Result{}, newDeviceError("secure erase", job.Device, fmt.Errorf("%w; the drive keeps the user password %q", err, ataErasePassword))
+	}
+
+	fmt.Printf("Starting %s of %s", firmwareMethodNames[method], job.Device)
+	if expected > 0 {
+		fmt.Printf(", the drive estimates %s", FormatDuration(expected))
+	}
+	fmt.Println()
+
+	// The erase runs inside the ioctl, which can't be interrupted
+	done := make(chan error, 1)
+	go func() {
+		done <- ataCommand(file, ataSecurityEraseUnit, ataProtocolPIOOut, ataPasswordSector(control), timeout)
+	}()
+	ticker := time.NewTicker(job.ProgressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				return Result{}, newDeviceError("secure erase", job.Device, fmt.Errorf("%w; the drive may keep the user password %q", err, ataErasePassword))
+			}
+			return Result{
+				Device:         job.Device,
+				Size:           job.size,
+				BytesProcessed: job.size,
+				Coverage:       coverage{Num: 1, Den: 1},
+				Duration:       time.Since(start),
+				Method:         method,
+			}, nil
+		case <-ctx.Done():
+			return Result{}, fmt.Errorf("stopped waiting for the secure erase of %s, which carries on in the drive: %w", job.Device, context.Cause(ctx))
+		case <-ticker.C:
+			if expected <= 0 {
+				continue
+			}
+			// The drive doesn't report progress; estimate it from its
+			// own time estimate
+			elapsed := time.Since(start)
+			progress := min(float64(elapsed)/float64(expected), 0.99)
+			report(Progress{
+				Device:         job.Device,
+				BytesProcessed: int64(progress * float64(job.size)),
+				Total:          job.size,
+				Speed:          progress * float64(job.size) / elapsed.Seconds(),
+				ETA:            max(expected-elapsed, 0),
+				Coverage:       coverage{Num: 1, Den: 1},
+			})
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// retryBackoff is how long writeWithRetries waits before the first retry of
+// a failed block. The wait doubles with every further retry, giving a dying
+// drive time to remap the sector.
+const retryBackoff = 100 * time.Millisecond
+
+// retryable reports whether a failed write may succeed when tried again or
+// is worth skipping. A vanished or resized device, a full filesystem and
+// misaligned I/O fail the same way for every block.
+func retryable(err error) bool {
+	return !errors.Is(err, ErrDeviceGone) && !errors.Is(err, ErrDeviceResized) && !errors.Is(err, ErrNoSpace) &&
+		!errors.Is(err, ErrUnaligned) && !errors.Is(err, context.Canceled)
+}
+
+// writeWithRetries writes buf at offset like writeBlock, retrying up to
+// job.Retries times with exponential backoff if the write fails with what
+// looks like a media error. The error of the last attempt is returned.
+func writeWithRetries(ctx context.Context, file **os.File, job wipeJob, serial string, buf []byte, offset int64) (int, error) {
+	n, err := writeBlock(ctx, file, job, serial, buf, offset)
+	wait := retryBackoff
+	for attempt := 1; err != nil && attempt <= job.Retries && retryable(err); attempt++ {
+		fmt.Println()
+		warnf("Write at offset %d failed (%v), retry %d/%d in %s", job.physical(offset), err, attempt, job.Retries, wait)
+		select {
+		case <-ctx.Done():
+			return n, err
+		case <-time.After(wait):
+		}
+		wait *= 2
+		n, err = writeBlock(ctx, file, job, serial, buf, offset)
+	}
+	return n, err
+}
+
+// badBlockSummary lists the blocks that could not be written, naming at most
+// maxListedMismatches offsets.
+func badBlockSummary(blocks []int64) string {
+	summary := fmt.Sprintf("Unrecoverable: %d blocks could not be written and still hold their old data, at offsets", len(blocks))
+	for i, offset := range blocks {
+		if i == maxListedMismatches {
+			summary += fmt.Sprintf(" and %d more", len(blocks)-i)
+			break
+		}
+		summary += fmt.Sprintf(" %d", offset)
+	}
+	return summary
+}
@@ -0,0 +1,204 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// sweepBufferSizes are the -buffer values tried by -bench-sweep.
+var sweepBufferSizes = []int{
+	64 << 10, 128 << 10, 256 << 10, 512 << 10,
+	1 << 20, 2 << 20, 4 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// sweepRegionSize is how much of the device each buffer size is timed on.
+// The region is saved before the sweep and written back afterwards.
+const sweepRegionSize = 128 << 20
+
+// sweepResult is the write speed measured with one buffer size.
+type sweepResult struct {
+	BufferSize int
+	Speed      float64 // bytes per second
+}
+
+// benchmarkBufferSweep times writing the same region in the middle of the
+// device with each of sweepBufferSizes. The region's contents are read
+// first and restored at the end, so the sweep leaves the device as it was
+// unless it is interrupted. The random data written is generated once, so
+// only the device is measured.
+func benchmarkBufferSweep(path string, noExcl bool) (results []sweepResult, err error) {
+	mode := os.O_RDWR
+	if !noExcl {
+		mode |= syscall.O_EXCL
+	}
+	file, err := openSynced(path, mode)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	deviceSize, err := DeviceSize(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stay within a quarter of small devices, aligned to 1MB
+	regionSize := min(int64(sweepRegionSize), deviceSize/4) &^ (1<<20 - 1)
+	if regionSize == 0 {
+		return nil, errors.New("device too small to benchmark")
+	}
+	regionOffset := (deviceSize/2 - regionSize/2) &^ (1<<20 - 1)
+
+	saved, err := AllocAlignedBuffer(int(regionSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(saved)
+	buffer, err := AllocAlignedBuffer(min(sweepBufferSizes[len(sweepBufferSizes)-1], int(regionSize)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(buffer)
+	if err := fillRandom(rngCrypto, buffer); err != nil {
+		return nil, err
+	}
+
+	if _, err := file.ReadAt(saved, regionOffset); err != nil {
+		return nil, newDeviceError("read", path, err)
+	}
+	defer func() {
+		if _, restoreErr := file.WriteAt(saved, regionOffset); restoreErr != nil {
+			err = fmt.Errorf("failed to restore the benchmark region at offset %d: %w", regionOffset, newWriteError(regionOffset, restoreErr))
+			return
+		}
+		if syncErr := file.Sync(); syncErr != nil && err == nil {
+			err = newDeviceError("sync", path, syncErr)
+		}
+		if err == nil {
+			fmt.Println("Benchmark region restored")
+		}
+	}()
+
+	fmt.Printf("Running benchmark: writing %s at offset %s with %d buffer sizes (restored afterwards)...\n",
+		FormatBytes(regionSize), FormatBytes(regionOffset), len(sweepBufferSizes))
+
+	for _, size := range sweepBufferSizes {
+		if int64(size) > regionSize {
+			break
+		}
+		fmt.Printf("\r\033[K\rBenchmarking: buffer %s...", FormatBytes(int64(size)))
+
+		startTime := time.Now()
+		for off := int64(0); off < regionSize; off += int64(size) {
+			if _, err := file.WriteAt(buffer[:min(int64(size), regionSize-off)], regionOffset+off); err != nil {
+				return nil, newWriteError(regionOffset+off, err)
+			}
+		}
+		if err := file.Sync(); err != nil {
+			return nil, newDeviceError("sync", path, err)
+		}
+		results = append(results, sweepResult{BufferSize: size, Speed: float64(regionSize) / time.Since(startTime).Seconds()})
+	}
+	fmt.Printf("\r\033[K\rBenchmark complete\n")
+	return results, nil
+}
+
+// Modes of -benchmark-regions: how the speeds at the start, middle and end
+// of the device are combined into the one auto-skip uses.
+const (
+	benchmarkAverage = "average"
+	benchmarkWorst   = "worst"
+)
+
+// validBenchmarkRegions reports whether mode is a -benchmark-regions mode;
+// empty benchmarks the start of the device only.
+func validBenchmarkRegions(mode string) bool {
+	return mode == "" || mode == benchmarkAverage || mode == benchmarkWorst
+}
+
+// regionSpeed is the write speed measured at one position of the device.
+type regionSpeed struct {
+	Position string  `json:"position"` // start, middle or end
+	Offset   int64   `json:"offset"`
+	Speed    float64 `json:"speed"` // bytes per second
+}
+
+// benchmarkWriteRegions runs the write benchmark at the start, the middle
+// and the end of the length bytes from start, which on a hard disk are its
+// outer, middle and inner tracks, and combines the speeds as
+// opts.BenchmarkRegions asks. Each position writes what a benchmark of a
+// third of the range would.
+func benchmarkWriteRegions(ctx context.Context, path string, opts Options, start, length int64) (float64, []regionSpeed, error) {
+	blockSize := deviceBlockSize(path)
+	bufferSize := alignBufferSize(opts.BufferSize, blockSize)
+	if length/3 < int64(bufferSize)*2 {
+		return 0, nil, errTooSmallToBenchmark
+	}
+	size := benchmarkSize(opts, length/3, bufferSize, blockSize)
+	aligned := func(offset int64) int64 { return start + offset/int64(blockSize)*int64(blockSize) }
+
+	speeds := []regionSpeed{
+		{Position: "start", Offset: start},
+		{Position: "middle", Offset: aligned(length/2 - size/2)},
+		{Position: "end", Offset: aligned(length - size)},
+	}
+	for i := range speeds {
+		fmt.Printf("Benchmarking the %s of %s (offset %s)...\n", speeds[i].Position, path, FormatBytes(speeds[i].Offset))
+		speed, err := benchmarkWriteRange(ctx, path, opts, speeds[i].Offset, size)
+		if err != nil {
+			return 0, nil, err
+		}
+		speeds[i].Speed = speed
+	}
+
+	combined := speeds[0].Speed
+	var sum float64
+	for _, s := range speeds {
+		combined = min(combined, s.Speed)
+		sum += s.Speed
+	}
+	if opts.BenchmarkRegions == benchmarkAverage {
+		combined = sum / float64(len(speeds))
+	}
+	fmt.Printf("Write speed of %s: %.2f MB/s at the start, %.2f MB/s in the middle, %.2f MB/s at the end; using the %s\n",
+		path, speeds[0].Speed/1024/1024, speeds[1].Speed/1024/1024, speeds[2].Speed/1024/1024, opts.BenchmarkRegions)
+	return combined, speeds, nil
+}
+
+// printSweep prints the results of benchmarkBufferSweep as a table and
+// recommends the fastest buffer size.
+func printSweep(path string, results []sweepResult) {
+	fmt.Printf("Write speed of %s by buffer size:\n", path)
+	fmt.Printf("  %10s  %10s  %10s\n", "Buffer", "MB/s", "MiB/s")
+
+	best := results[0]
+	for _, r := range results {
+		fmt.Printf("  %10s  %10.2f  %10.2f\n", FormatBytes(int64(r.BufferSize)), r.Speed/1e6, r.Speed/(1<<20))
+		if r.Speed > best.Speed {
+			best = r
+		}
+	}
+	fmt.Printf("Recommended: -buffer %d (%s, %.2f MB/s)\n", best.BufferSize, FormatBytes(int64(best.BufferSize)), best.Speed/1e6)
+}
+
+// runBenchSweep runs the buffer size sweep on every job's device in turn and
+// returns the exit code.
+func runBenchSweep(jobs []wipeJob, force bool) int {
+	for _, job := range jobs {
+		if err := checkTarget(job.Device, force); err != nil {
+			errorf("%v", err)
+			return exitCodeFor(err)
+		}
+		results, err := benchmarkBufferSweep(job.Device, job.NoExcl)
+		if err != nil {
+			errorf("Cannot benchmark %s: %v", job.Device, err)
+			return exitCodeFor(err)
+		}
+		printSweep(job.Device, results)
+	}
+	return exitOK
+}
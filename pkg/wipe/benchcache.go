@@ -0,0 +1,103 @@
+package wipe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// defaultBenchmarkCacheAge is how long a cached write speed is used for
+// before the drive is benchmarked again.
+const defaultBenchmarkCacheAge = 24 * time.Hour
+
+// cachedBenchmark is a write speed measured by an earlier -auto-skip run.
+type cachedBenchmark struct {
+	Speed        float64       `json:"speed"` // bytes per second
+	RegionSpeeds []regionSpeed `json:"region_speeds,omitempty"`
+	MeasuredAt   time.Time     `json:"measured_at"`
+}
+
+// benchmarkCachePath returns the file write speeds are cached in, under the
+// user's cache directory (~/.cache/quickwipe on Linux), or "" if there is
+// none.
+func benchmarkCachePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "quickwipe", "benchmarks.json")
+}
+
+// benchmarkCacheKey identifies the drive behind device by model and serial
+// number, together with the options that change how fast it is written.
+// Drives without a serial number, such as disk images, aren't cached.
+func benchmarkCacheKey(device string, opts Options) string {
+	serial := deviceSerial(device)
+	if serial == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s buffer=%d rng=%s refresh=%d sync=%s/%d direct=%t regions=%s",
+		deviceModel(device), serial, opts.BufferSize, opts.RNG, opts.RandomRefresh,
+		opts.SyncMode, opts.SyncEvery, !opts.NoDirect, opts.BenchmarkRegions)
+}
+
+// readBenchmarkCache reads the cache file at path; a missing file is an
+// empty cache.
+func readBenchmarkCache(path string) (map[string]cachedBenchmark, error) {
+	cache := map[string]cachedBenchmark{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return nil, fmt.Errorf("benchmark cache %s: %w", path, err)
+	}
+	return cache, nil
+}
+
+// lookupBenchmark returns the speed cached at path under key if it was
+// measured less than maxAge before now. Any problem with the cache only
+// means the drive is benchmarked again.
+func lookupBenchmark(path, key string, maxAge time.Duration, now time.Time) (cachedBenchmark, bool) {
+	if path == "" || key == "" {
+		return cachedBenchmark{}, false
+	}
+	cache, err := readBenchmarkCache(path)
+	if err != nil {
+		warnf("Ignoring the benchmark cache: %v", err)
+		return cachedBenchmark{}, false
+	}
+	b, ok := cache[key]
+	if !ok || b.Speed <= 0 || now.Sub(b.MeasuredAt) >= maxAge || b.MeasuredAt.After(now) {
+		return cachedBenchmark{}, false
+	}
+	return b, true
+}
+
+// storeBenchmark records b under key in the cache file at path, dropping
+// entries that are older than maxAge.
+func storeBenchmark(path, key string, b cachedBenchmark, maxAge time.Duration) error {
+	if path == "" || key == "" {
+		return nil
+	}
+	cache, err := readBenchmarkCache(path)
+	if err != nil {
+		cache = map[string]cachedBenchmark{}
+	}
+	for k, old := range cache {
+		if b.MeasuredAt.Sub(old.MeasuredAt) >= maxAge {
+			delete(cache, k)
+		}
+	}
+	cache[key] = b
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return writeJSONFile(path, cache)
+}
@@ -0,0 +1,77 @@
+package wipe
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// defaultBlockSize is assumed when the sector size can't be detected, for
+// example for disk images.
+const defaultBlockSize = 4096
+
+// deviceBlockSize returns the size writes to path are aligned to: the
+// physical sector size, so that 512e drives aren't made to read-modify-write
+// their 4K sectors, or the logical sector size if that is all the device
+// reports. It falls back to defaultBlockSize if neither can be read.
+func deviceBlockSize(path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		return defaultBlockSize
+	}
+	defer file.Close()
+
+	logical, physical, ok := sectorSizes(file)
+	if !ok || logical <= 0 {
+		return defaultBlockSize
+	}
+	return max(logical, physical)
+}
+
+// alignBufferSize rounds size down to a multiple of blockSize, but to no
+// less than one block.
+func alignBufferSize(size, blockSize int) int {
+	return max(size/blockSize*blockSize, blockSize)
+}
+
+// writeAligned writes buf at offset through file, which may be opened for
+// direct I/O and then only accepts whole blocks of blockSize. Only an image
+// file whose size isn't a multiple of the block size ends in a partial
+// block; that tail is written through a second, buffered descriptor.
+func writeAligned(file *os.File, path string, buf []byte, offset int64, blockSize int) (int, error) {
+	head := len(buf) / blockSize * blockSize
+	if head == len(buf) {
+		return file.WriteAt(buf, offset)
+	}
+
+	n := 0
+	if head > 0 {
+		var err error
+		if n, err = file.WriteAt(buf[:head], offset); err != nil {
+			return n, err
+		}
+	}
+	tail, err := os.OpenFile(path, os.O_WRONLY|syscall.O_SYNC, 0)
+	if err != nil {
+		return n, err
+	}
+	defer tail.Close()
+	m, err := tail.WriteAt(buf[head:], offset+int64(head))
+	return n + m, err
+}
+
+// readAligned reads length bytes at offset into buf. With direct I/O the
+// read has to be whole blocks, so a partial block at the end of an image
+// file is read by asking for the whole block, which stops short at the end
+// of the file. buf must have room for length rounded up to blockSize.
+func readAligned(file *os.File, buf []byte, length int, offset int64, blockSize int) (int, error) {
+	rounded := min(len(buf), (length+blockSize-1)/blockSize*blockSize)
+	n, err := file.ReadAt(buf[:rounded], offset)
+	if n >= length {
+		return length, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
@@ -0,0 +1,186 @@
+package wipe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certificate records how a device was wiped, by whom and where.
+type certificate struct {
+	JobID           string        `json:"job_id,omitempty"`
+	Device          string        `json:"device"`
+	Serial          string        `json:"serial,omitempty"`
+	Model           string        `json:"model,omitempty"`
+	Size            int64         `json:"size"`
+	Method          string        `json:"method"`
+	Pattern         string        `json:"pattern,omitempty"`
+	Scheme          string        `json:"scheme,omitempty"` // e.g. "DoD 5220.22-M"
+	Coverage        coverage      `json:"coverage"`
+	SkipSeed        uint64        `json:"skip_seed,omitempty"` // the blocks were chosen at random with this seed
+	BytesWritten    int64         `json:"bytes_written"`
+	Verified        bool          `json:"verified"`
+	VerifiedSamples int           `json:"verified_samples,omitempty"` // blocks read back, if only a sample was
+	Stamped         bool          `json:"stamped,omitempty"`          // -stamp wrote a marker to the first block
+	BadBlocks       []int64       `json:"bad_blocks,omitempty"`       // offsets of blocks that could not be written
+	Digest          string        `json:"digest,omitempty"`
+	DigestAlgorithm string        `json:"digest_algorithm,omitempty"`
+	SMARTBefore     *smartSummary `json:"smart_before,omitempty"`
+	SMARTAfter      *smartSummary `json:"smart_after,omitempty"`
+	StartedAt       time.Time     `json:"started_at"`
+	FinishedAt      time.Time     `json:"finished_at"`
+	AverageSpeed    float64       `json:"average_speed_bps"`
+	Run             runInfo       `json:"run"`
+}
+
+// errNotFinished is returned when a certificate is requested for a job that
+// has not completed successfully.
+var errNotFinished = errors.New("job has not finished successfully")
+
+// defaultCertificatePath derives a certificate file name in the working
+// directory from the device name, e.g. quickwipe-sdb.certificate.json.
+func defaultCertificatePath(device string) string {
+	return fmt.Sprintf("quickwipe-%s.certificate.json", filepath.Base(device))
+}
+
+// certificateTextPath returns where the human-readable form of the
+// certificate at path is written: next to it, with a .txt extension.
+func certificateTextPath(path string) string {
+	return strings.TrimSuffix(path, ".json") + ".txt"
+}
+
+// writeCertificate writes cert as JSON to path and as text next to it. The
+// text form ends with the SHA-256 of the JSON file so that a printed copy
+// can be matched to the record it was made from.
+func writeCertificate(path string, cert certificate) error {
+	if err := writeJSONFile(path, cert); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+
+	text := cert.text() + fmt.Sprintf("%-20s %s\n", "JSON record SHA-256:", hex.EncodeToString(sum[:]))
+	tmp := certificateTextPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, []byte(text), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, certificateTextPath(path))
+}
+
+// text renders the certificate for people: one field per line, leaving out
+// what is unknown or doesn't apply.
+func (c certificate) text() string {
+	var b strings.Builder
+	line := func(label, format string, args ...any) {
+		fmt.Fprintf(&b, "%-20s %s\n", label+":", fmt.Sprintf(format, args...))
+	}
+
+	b.WriteString("quickwipe wipe certificate\n\n")
+	if c.JobID != "" {
+		line("Job", "%s", c.JobID)
+	}
+	line("Device", "%s", c.Device)
+	if c.Model != "" {
+		line("Model", "%s", c.Model)
+	}
+	if c.Serial != "" {
+		line("Serial", "%s", c.Serial)
+	}
+	line("Size", "%s (%d bytes)", FormatBytes(c.Size), c.Size)
+	line("Method", "%s", c.Method)
+	if c.Scheme != "" {
+		line("Scheme", "%s", c.Scheme)
+	}
+	if c.Pattern != "" {
+		line("Pattern", "%s", c.Pattern)
+	}
+	if c.Coverage.full() {
+		line("Coverage", "whole device")
+	} else if c.SkipSeed != 0 {
+		line("Coverage", "%s (seed %d)", c.Coverage.describe(skipModeRandom), c.SkipSeed)
+	} else {
+		line("Coverage", "%s", c.Coverage)
+	}
+	line("Bytes written", "%s (%d bytes)", FormatBytes(c.BytesWritten), c.BytesWritten)
+	if c.Verified && c.VerifiedSamples > 0 {
+		line("Verified", "%t (%d sampled blocks)", c.Verified, c.VerifiedSamples)
+	} else {
+		line("Verified", "%t", c.Verified)
+	}
+	if c.Stamped {
+		line("Marker", "written to the first block after verifying")
+	}
+	if len(c.BadBlocks) > 0 {
+		line("Unwritten blocks", "%d, see bad_blocks in the JSON record", len(c.BadBlocks))
+	}
+	if c.Digest != "" {
+		line("Digest", "%s %s", c.DigestAlgorithm, c.Digest)
+	}
+	if c.SMARTBefore != nil {
+		line("SMART before", "%s", c.SMARTBefore)
+	}
+	if c.SMARTAfter != nil {
+		line("SMART after", "%s", c.SMARTAfter)
+	}
+	line("Started", "%s", c.StartedAt.Format(time.RFC3339))
+	line("Finished", "%s", c.FinishedAt.Format(time.RFC3339))
+	if c.AverageSpeed > 0 {
+		line("Average speed", "%.2f MB/s", c.AverageSpeed/1024/1024)
+	}
+	line("Host", "%s", c.Run.Hostname)
+	line("Operator", "%s", c.Run.Operator)
+	line("quickwipe version", "%s", c.Run.Version)
+	return b.String()
+}
+
+// newCertificate builds the certificate of a successful wipe of job.
+func newCertificate(job wipeJob, result Result, started, finished time.Time, run runInfo) certificate {
+	cert := certificate{
+		Device:          result.Device,
+		Serial:          deviceSerial(job.Device),
+		Model:           deviceModel(job.Device),
+		Size:            result.Size,
+		Method:          result.Method,
+		Coverage:        result.Coverage,
+		SkipSeed:        result.SkipSeed,
+		BytesWritten:    result.BytesWritten,
+		Verified:        result.Verified,
+		VerifiedSamples: result.VerifiedSamples,
+		Stamped:         result.Stamped,
+		BadBlocks:       result.BadBlocks,
+		Digest:          result.Digest,
+		DigestAlgorithm: result.DigestAlgorithm,
+		SMARTBefore:     result.SMARTBefore,
+		SMARTAfter:      result.SMARTAfter,
+		StartedAt:       started,
+		FinishedAt:      finished,
+		Run:             run,
+	}
+	if result.Method == methodOverwrite {
+		cert.Pattern = strings.Join(job.passPatterns(), "+")
+		cert.Scheme = schemeNames[job.Scheme]
+	}
+	if result.Duration > 0 {
+		cert.AverageSpeed = result.averageSpeed()
+	}
+	return cert
+}
+
+// jobCertificate builds the certificate of a finished daemon job.
+func jobCertificate(job daemonJob, run runInfo) (certificate, error) {
+	if job.State != jobDone || job.Result == nil || job.StartedAt == nil || job.FinishedAt == nil {
+		return certificate{}, errNotFinished
+	}
+	cert := newCertificate(job.Spec, *job.Result, *job.StartedAt, *job.FinishedAt, run)
+	cert.JobID = job.ID
+	cert.Serial = job.Serial
+	return cert, nil
+}
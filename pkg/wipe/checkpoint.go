@@ -0,0 +1,128 @@
+package wipe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointInterval is how often the checkpoint is rewritten while a wipe
+// is running.
+const checkpointInterval = 5 * time.Second
+
+// checkpoint records how far a wipe got so that it can be resumed later.
+type checkpoint struct {
+	Device       string    `json:"device"`
+	Size         int64     `json:"size"`
+	Offset       int64     `json:"offset"`
+	BytesWritten int64     `json:"bytes_written"`
+	BufferSize   int       `json:"buffer_size"`
+	Coverage     coverage  `json:"coverage"`
+	Pattern      string    `json:"pattern,omitempty"`
+	Run          runInfo   `json:"run"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// SelectorState is the block selector's accumulator, so that a resumed
+	// partial-coverage wipe keeps the same block spacing.
+	SelectorState int64 `json:"selector_state,omitempty"`
+
+	// SkipSeed is the seed of a -skip-mode random wipe, which a resumed
+	// wipe must keep to write the same blocks.
+	SkipSeed uint64 `json:"skip_seed,omitempty"`
+
+	// Pass is the index of the overwrite pass that was interrupted; earlier
+	// passes had completed.
+	Pass int `json:"pass,omitempty"`
+
+	// FillingGaps is set when the second stage of -fill-gaps was
+	// interrupted; all passes had completed.
+	FillingGaps bool `json:"filling_gaps,omitempty"`
+
+	// PartitionTable is the table saved by -preserve-partition-table, which
+	// the interrupted wipe may already have overwritten.
+	PartitionTable []savedRegion `json:"partition_table,omitempty"`
+
+	// Partition is the number of the partition -preserve-table was wiping;
+	// Offset is then relative to its start, and earlier partitions had
+	// completed.
+	Partition int `json:"partition,omitempty"`
+}
+
+// defaultCheckpointPath derives a checkpoint file name in the working
+// directory from the device name, e.g. quickwipe-sdb.checkpoint.
+func defaultCheckpointPath(device string) string {
+	return fmt.Sprintf("quickwipe-%s.checkpoint", filepath.Base(device))
+}
+
+// writeCheckpoint atomically replaces the checkpoint file at path.
+func writeCheckpoint(path string, cp checkpoint) error {
+	cp.UpdatedAt = time.Now().UTC()
+	return writeJSONFile(path, cp)
+}
+
+// readCheckpoint loads a checkpoint written by writeCheckpoint.
+func readCheckpoint(path string) (checkpoint, error) {
+	var cp checkpoint
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cp, err
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("invalid checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+// writeJSONFile atomically replaces path with the JSON encoding of v, so a
+// crash mid-write never leaves a truncated file behind.
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadResumeCheckpoint reads the checkpoint at path and checks that it
+// belongs to job's device. Which blocks are skipped depends on the buffer
+// size, the coverage and whether gaps were being filled, so job takes them
+// over from the checkpoint.
+func loadResumeCheckpoint(path string, job *wipeJob) (*checkpoint, error) {
+	cp, err := readCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Device != job.Device || cp.Size != job.size {
+		return nil, fmt.Errorf("checkpoint %s is for %s (%d bytes), not %s (%d bytes)",
+			path, cp.Device, cp.Size, job.Device, job.size)
+	}
+	// Offsets within a partition mean nothing for the whole disk
+	if (cp.Partition != 0) != job.PreserveTable {
+		return nil, fmt.Errorf("checkpoint %s was written with -preserve-table %t", path, cp.Partition != 0)
+	}
+	if cp.BufferSize > 0 {
+		job.BufferSize = cp.BufferSize
+	}
+	if cp.FillingGaps {
+		job.FillGaps = true
+	}
+	if cp.SkipSeed != 0 {
+		job.SkipMode, job.SkipSeed = skipModeRandom, cp.SkipSeed
+		fmt.Printf("Continuing the random block selection of %s with seed %d\n", path, cp.SkipSeed)
+	} else if job.SkipMode == skipModeRandom && !cp.Coverage.full() {
+		return nil, fmt.Errorf("checkpoint %s was written without -skip-mode random", path)
+	}
+	if cp.Coverage.Num == 1 {
+		job.SkipFactor, job.Coverage = int(cp.Coverage.Den), 0
+	} else if cp.Coverage.Den > 0 {
+		job.Coverage = float64(cp.Coverage.Num) / float64(cp.Coverage.Den)
+	}
+	return &cp, nil
+}
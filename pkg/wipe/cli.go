@@ -0,0 +1,538 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// Main runs the quickwipe command: it parses the command-line flags, wipes
+// the devices they name and exits the process with one of the exit codes.
+func Main() {
+	// Parse command-line arguments
+	var blockDevices deviceListFlag
+	flag.Var(&blockDevices, "device", "Path to block device (required unless -devices-file is given); repeat or separate with commas to wipe several")
+	devicesFile := flag.String("devices-file", "", "File listing devices to wipe, one per line with optional key=value overrides")
+	parallel := flag.Bool("parallel", false, "Wipe multiple devices concurrently instead of one after another")
+	perPartition := flag.Bool("per-partition", false, "Wipe each partition of a disk separately, leaving the partition table intact")
+	bufferSize := bufferSizeFlag(defaultBufferSize)
+	flag.Var(&bufferSize, "buffer", "Buffer size, in bytes or with a K, M or G suffix (binary: 4M = 4194304)")
+	skipFactor := flag.Int("skip", 1, "Only write every Nth block (1 = wipe all)")
+	skipMode := flag.String("skip-mode", skipModeStride, "How -skip and -coverage choose the blocks to write: stride (at regular intervals) or random (the same share, at pseudo-random positions from -skip-seed)")
+	skipSeed := flag.Uint64("skip-seed", 0, "Seed of -skip-mode random, printed and recorded by every wipe so that its choice can be repeated (0 = pick one)")
+	pattern := flag.String("pattern", patternRandom, "Data to write: random, zero, one (0xFF), a hex byte such as 0xAA, or a test pattern: counter (each sector holds its LBA), prbs7 or prbs15 (prbs)")
+	patternFileName := flag.String("pattern-file", "", "Write the contents of this file, repeated across the device, instead of -pattern (- reads standard input and needs -force or -assume-yes)")
+	verify := flag.Bool("verify", false, "Read back every written block after the wipe and check its contents")
+	verifyInline := flag.Bool("verify-inline", false, "Read back every block right after writing it and compare it with what was written, instead of a separate verify pass")
+	verifySample := flag.Int("verify-sample", 0, "Verify only this many randomly chosen written blocks instead of all of them (implies -verify)")
+	entropyCheck := flag.Bool("entropy-check", false, "While verifying random data, warn about blocks that read back with suspiciously low entropy, e.g. zeros (implies -verify)")
+	passes := flag.Int("passes", 1, "Number of overwrite passes; with -skip or -coverage each pass writes different blocks")
+	scheme := flag.String("scheme", "", "Run a standard multi-pass scheme instead of -pattern: dod (DoD 5220.22-M: zero, one, random, then verify)")
+	secureRandomZero := flag.Bool("secure-random-zero", false, "Overwrite with random data, then with zeros, then verify that the device reads back as zeros")
+	rng := flag.String("rng", rngChaCha, "Random data source: chacha8 (ChaCha8 stream seeded from crypto/rand), secure or crypto (crypto/rand for every block), hw (CPU RDRAND, falls back to chacha8 if unavailable) or aes (AES-CTR keystream under a per-run random key)")
+	randomRefresh := flag.Int("random-refresh", 1, "Regenerate random data only every Nth write (1 = fresh data for every block; higher is faster but repeats data)")
+	coverageValue := flag.String("coverage", "", "Percentage of blocks to write, spread out, from 1 to 100, e.g. 25 or 25% (instead of -skip or -auto-skip)")
+	autoSkip := flag.Bool("auto-skip", false, "Auto-determine skip factor to finish in -target-hours (default: 20)")
+	fillGaps := flag.Bool("fill-gaps", false, "After a partial wipe, go back and write the skipped blocks until -target-hours is reached")
+	targetHours := flag.Float64("target-hours", defaultTargetHours, "Target completion time in hours for auto-skip")
+	maxDuration := flag.Duration("max-duration", 0, "Stop each wipe cleanly once it has run this long, e.g. 6h, writing a checkpoint to resume from (0 = no limit)")
+	benchmarkSize := flag.String("benchmark-size", "", "How much the -auto-skip write benchmark writes, e.g. 1G (default 10G, at most a quarter of the device)")
+	benchmarkTime := flag.Duration("benchmark-time", 0, "Run the -auto-skip write benchmark for this long instead, e.g. 30s, stopping early at -benchmark-size if given")
+	benchmarkRegions := flag.String("benchmark-regions", "", "Benchmark writing at the start, middle and end of the device and base -auto-skip on their average or worst speed, for hard disks that slow down towards the end")
+	benchmarkCacheAge := flag.Duration("benchmark-cache-age", defaultBenchmarkCacheAge, "Reuse the -auto-skip write speed measured for the same drive (by model and serial number) within this long, e.g. 72h, instead of benchmarking it again (0 = always benchmark)")
+	rebenchmark := flag.Bool("rebenchmark", false, "Run the -auto-skip write benchmark even if a cached speed is fresh, and cache the new one")
+	writeZeroes := flag.Bool("write-zeroes", false, "Leave whole-device zero passes to the disk with BLKZEROOUT if it supports write-zeroes offload, which is much faster; falls back to writing zeros")
+	syncMode := flag.String("sync-mode", syncOSync, "How writes are made durable: osync (every write waits until it is on the device; safest, slowest), fdatasync (no O_SYNC, flush every -sync-every buffers; a crash loses at most that many) or none (no O_SYNC, only a final sync; a crash can lose everything not yet flushed, and the drive may report write errors late)")
+	syncEvery := flag.Int("sync-every", defaultSyncEvery, "Buffers written between flushes with -sync-mode fdatasync")
+	noDirect := flag.Bool("no-direct", false, "Write through the page cache instead of trying direct I/O (O_DIRECT) first; may be slower, but works on storage stacks where direct I/O misbehaves")
+	noExcl := flag.Bool("no-excl", false, "Open block devices without O_EXCL, so the kernel doesn't refuse devices that are mounted or held by md or LVM (dangerous)")
+	force := flag.Bool("force", false, "Skip confirmation prompt")
+	assumeYes := flag.Bool("assume-yes", false, "Answer confirmation prompts with yes, but keep the safety checks: a path outside /dev/ or a size that doesn't match -expect-size is refused instead of asked about (use -force to override those too)")
+	progressInterval := flag.Duration("progress-interval", defaultProgressInterval, "How often to update the progress display, e.g. 5s or 250ms")
+	etaWindow := flag.Int("eta-window", defaultETAWindow, "Number of progress updates the speed behind the ETA is averaged over")
+	bar := flag.Bool("bar", isTerminal(os.Stdout), "Show progress as a bar sized to the terminal; on by default when stdout is a terminal")
+	quiet := flag.Bool("quiet", false, "Don't show progress while wiping; only the final summary is printed")
+	dryRun := flag.Bool("dry-run", false, "Print the size, coverage, passes and estimated time for each device, then exit without writing")
+	discard := flag.Bool("discard", false, "Discard (TRIM) the whole device instead of overwriting it; devices without discard support are overwritten")
+	discardFirst := flag.Bool("discard-first", false, "Discard (TRIM) the whole device, then overwrite it as usual")
+	nvmeSanitize := flag.Bool("nvme-sanitize", false, "Erase NVMe namespaces with the controller's Sanitize (or Format NVM) command instead of overwriting; other devices are overwritten")
+	ataSecureErase := flag.Bool("ata-secure-erase", false, "Erase SATA drives with the drive's ATA Security Erase Unit command instead of overwriting; other devices are overwritten")
+	discardVerify := flag.Bool("discard-verify", false, "Discard (TRIM) the whole device, then sample it; overwrite only if it doesn't read back as zeros")
+	daemonMode := flag.Bool("daemon", false, "Run as a service that accepts wipe jobs on -socket")
+	socketPath := flag.String("socket", "/run/quickwipe.sock", "Unix socket the daemon listens on")
+	concurrency := flag.Int("concurrency", 1, "Number of jobs the daemon runs at the same time")
+	statePath := flag.String("state-file", "/var/lib/quickwipe/jobs.json", "File the daemon persists its job history to (empty disables persistence)")
+	apiAddr := flag.String("api-addr", "", "Also serve the daemon's HTTP API on this TCP address, e.g. :8080; needs -api-token unless it is a loopback address (disabled when empty)")
+	apiToken := flag.String("api-token", "", "Bearer token clients of -api-addr and -grpc-addr must send; best set through QUICKWIPE_API_TOKEN, which doesn't show in ps")
+	grpcAddr := flag.String("grpc-addr", "", "Also serve the daemon's gRPC API on this TCP address, e.g. :7070; needs -api-token unless it is a loopback address (disabled when empty)")
+	startAt := flag.String("at", "", "Wait until this time (HH:MM, \"YYYY-MM-DD HH:MM\" or RFC3339) before starting the wipe")
+	timelinePath := flag.String("timeline", "", "Append a CSV row with time, offset, speed and drive temperature per progress update to this file")
+	notify := flag.Bool("notify", false, "Show a desktop notification when the wipe finishes or fails")
+	operator := flag.String("operator", "", "Name of the person performing the wipe, recorded in the output and checkpoint (default: invoking user)")
+	showVersion := flag.Bool("version", false, "Print version information and exit")
+	removeHPA := flag.Bool("remove-hpa", false, "Remove the Host Protected Area of ATA drives until the next power cycle so that the sectors it hides are wiped too")
+	preserveTable := flag.Bool("preserve-partition-table", false, "Save the MBR/GPT before wiping a whole disk and restore it afterwards")
+	partitionsOnly := flag.Bool("preserve-table", false, "Wipe only the partitions listed in the MBR/GPT of a whole disk, leaving the table itself untouched")
+	mlock := flag.Bool("mlock", false, "Lock the write buffers into RAM so pattern data is never swapped out")
+	smart := flag.Bool("smart", false, "Record the drive's SMART health before and after the wipe (needs smartctl)")
+	stamp := flag.Bool("stamp", false, "After a successful wipe, write a \"DATA WIPED\" marker with the tool, version and time to the first block of the device")
+	summaryPath := flag.String("summary", "", "Write the wipe statistics as JSON to this file when the wipe completes or is interrupted (\"auto\": quickwipe-<device>.summary.json)")
+	certificatePath := flag.String("certificate", "", "Write a JSON wipe certificate to this file on success, and a text copy with a .txt extension next to it (\"auto\": quickwipe-<device>.certificate.json)")
+	workflow := flag.String("workflow", "", "Run a predefined sequence of steps; \"full\" enables -verify, -smart and -certificate auto unless they are set explicitly")
+	digest := flag.String("digest", digestSHA256, "Checksum recorded per written block of random data for -verify and the certificate: sha256, or crc32c (much cheaper, not collision resistant)")
+	pipelineDepth := flag.Int("pipeline-depth", defaultPipelineDepth, "Number of buffers in flight, so the next blocks are filled while one is written (1 = no overlap)")
+	listDevices := flag.Bool("list", false, "List the disks that could be wiped with their model, serial number, size, type and mounts, then exit")
+	benchSweep := flag.Bool("bench-sweep", false, "Time writes with a range of buffer sizes on a region in the middle of the device, restore it, and recommend a -buffer value; nothing is wiped")
+	expectSize := flag.String("expect-size", "", "Expected device size, e.g. 500G or 931.5GiB; a device more than 1% off needs extra confirmation, or is refused with -force")
+	expectSerial := flag.String("expect-serial", "", "Refuse to wipe unless the disk reports this serial number (single device only)")
+	logPath := flag.String("log", "", "Append a JSON line per event (run start, passes, progress, errors, results) to this file")
+	logMaxSize := flag.String("log-max-size", "10MB", "Roll -log over to a new file once it reaches this size, e.g. 10MB")
+	logKeep := flag.Int("log-keep", 5, "Number of rolled-over -log files to keep")
+	flag.String("config", "", "Read options from this file of key = value lines named after the flags; command-line flags and environment variables take precedence")
+	showConfig := flag.Bool("print-config", false, "Print every effective option and the detected device parameters before wiping")
+	rate := flag.String("rate", "", "Limit write throughput, e.g. 50M for 50 MB/s, to leave I/O for other work on a busy host")
+	retries := flag.Int("retries", 0, "Retry a block that fails to write this many times, then skip it and carry on (0 = fail at the first write error)")
+	reopenWait := flag.Duration("reopen-wait", 0, "If the device disappears mid-wipe (e.g. a USB drive reset), wait this long for it to come back and continue (0 = fail at once)")
+	checkWiped := flag.String("check-wiped", "", "Sample the device first and, if it already holds the pattern, ask whether to skip it (ask) or skip it outright (skip)")
+	jsonOutput := flag.Bool("json", false, "Write progress and results to stdout as newline-delimited JSON; all other messages go to stderr")
+	debug := flag.Bool("debug", false, "Print the block size, buffer sizes and alignment, open flags and first and last write of each wipe to stderr, to diagnose direct I/O failures")
+	colorMode := flag.String("color", colorAuto, "Color warnings, errors and success messages: auto (on a terminal unless NO_COLOR is set), always or never")
+	checkpointPath := flag.String("checkpoint", "", "Checkpoint file kept up to date while wiping and removed on success (default: quickwipe-<device>.checkpoint)")
+	resume := flag.Bool("resume", false, "Continue an interrupted wipe from its -checkpoint file")
+
+	flag.Usage = func() { printUsage(flag.CommandLine.Output(), flag.CommandLine) }
+
+	flag.Parse()
+	sources, err := loadFlags(flag.CommandLine)
+	if err != nil {
+		errorf("%v", err)
+		os.Exit(exitUsage)
+	}
+
+	// With -json, stdout carries only the JSON stream
+	stream, display := newDisplay(*jsonOutput, *quiet, *bar, *parallel)
+	if stream != nil {
+		os.Stdout = os.Stderr
+	}
+	if err := setColorMode(*colorMode); err != nil {
+		errorf("%v", err)
+		os.Exit(exitUsage)
+	}
+	if err := applyWorkflow(*workflow, flag.CommandLine); err != nil {
+		errorf("%v", err)
+		os.Exit(exitUsage)
+	}
+	debugEnabled = *debug
+
+	if *showVersion {
+		fmt.Printf("quickwipe %s\n", buildVersion())
+		fmt.Printf("  commit: %s\n", buildCommit())
+		fmt.Printf("  built:  %s\n", buildDate())
+		fmt.Printf("  go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		os.Exit(exitOK)
+	}
+	if *listDevices {
+		os.Exit(runList())
+	}
+
+	run := newRunInfo(*operator)
+
+	var expectedSize int64
+	if *expectSize != "" {
+		size, err := parseSize(*expectSize)
+		if err != nil {
+			errorf("-expect-size: %v", err)
+			os.Exit(exitUsage)
+		}
+		expectedSize = size
+	}
+
+	var benchSize int64
+	if *benchmarkSize != "" {
+		size, err := parseSize(*benchmarkSize)
+		if err != nil {
+			errorf("-benchmark-size: %v", err)
+			os.Exit(exitUsage)
+		}
+		benchSize = size
+	}
+
+	// -coverage is the percentage way of saying -skip, and -auto-skip picks
+	// the skip factor itself
+	if err := settleExclusive(flag.CommandLine, sources, "coverage", "skip", "auto-skip"); err != nil {
+		errorf("%v", err)
+		os.Exit(exitUsage)
+	}
+	var coverageFraction float64
+	if *coverageValue != "" {
+		fraction, err := parseCoverage(*coverageValue)
+		if err != nil {
+			errorf("-coverage: %v", err)
+			os.Exit(exitUsage)
+		}
+		coverageFraction = fraction
+	}
+
+	var rateLimit int64
+	if *rate != "" {
+		limit, err := parseRate(*rate)
+		if err != nil {
+			errorf("-rate: %v", err)
+			os.Exit(exitUsage)
+		}
+		rateLimit = limit
+	}
+
+	// -pattern-file picks the file pattern unless -pattern names another one
+	// from the same source; whichever comes from the more specific source wins
+	if *patternFileName != "" && sources["pattern"] > sources["pattern-file"] && *pattern != patternFile {
+		*patternFileName = ""
+	}
+	if *patternFileName != "" {
+		if sources["pattern"] < sources["pattern-file"] {
+			*pattern = patternFile
+		}
+		if *patternFileName == "-" && !*force && !*assumeYes {
+			errorf("-pattern-file - needs -force or -assume-yes: the confirmation prompts read standard input too")
+			os.Exit(exitUsage)
+		}
+	}
+
+	base := wipeJob{
+		Options: Options{
+			BufferSize:        int(bufferSize),
+			SkipFactor:        *skipFactor,
+			SkipMode:          *skipMode,
+			SkipSeed:          *skipSeed,
+			Coverage:          coverageFraction,
+			Pattern:           *pattern,
+			PatternFile:       *patternFileName,
+			Verify:            *verify,
+			VerifySample:      *verifySample,
+			VerifyInline:      *verifyInline,
+			EntropyCheck:      *entropyCheck,
+			SecureRandomZero:  *secureRandomZero,
+			Passes:            *passes,
+			Scheme:            *scheme,
+			RNG:               *rng,
+			RandomRefresh:     *randomRefresh,
+			DiscardVerify:     *discardVerify,
+			Discard:           *discard,
+			DiscardFirst:      *discardFirst,
+			NVMeSanitize:      *nvmeSanitize,
+			ATASecureErase:    *ataSecureErase,
+			AutoSkip:          *autoSkip,
+			TargetHours:       *targetHours,
+			MaxDuration:       *maxDuration,
+			WriteZeroes:       *writeZeroes,
+			BenchmarkSize:     benchSize,
+			BenchmarkTime:     *benchmarkTime,
+			BenchmarkRegions:  *benchmarkRegions,
+			BenchmarkCacheAge: *benchmarkCacheAge,
+			Rebenchmark:       *rebenchmark,
+			Mlock:             *mlock,
+			Digest:            *digest,
+			PipelineDepth:     *pipelineDepth,
+			ReopenWait:        *reopenWait,
+			Retries:           *retries,
+			Rate:              rateLimit,
+			ProgressInterval:  *progressInterval,
+			ETAWindow:         *etaWindow,
+			FillGaps:          *fillGaps,
+			NoExcl:            *noExcl,
+			NoDirect:          *noDirect,
+			SyncMode:          *syncMode,
+			SyncEvery:         *syncEvery,
+			Checkpoint:        *checkpointPath,
+		},
+
+		PreservePartitionTable: *preserveTable,
+		PreserveTable:          *partitionsOnly,
+		RemoveHPA:              *removeHPA,
+		SMART:                  *smart,
+		Certificate:            *certificatePath,
+		Summary:                *summaryPath,
+		Stamp:                  *stamp,
+		ExpectSize:             expectedSize,
+		ExpectSerial:           *expectSerial,
+		CheckWiped:             *checkWiped,
+	}
+
+	var activity *activityLog
+	if *logPath != "" {
+		maxSize, err := parseSize(*logMaxSize)
+		if err != nil || *logKeep < 0 {
+			errorf("-log-max-size must be a positive size and -log-keep at least 0")
+			os.Exit(exitUsage)
+		}
+		if activity, err = openActivityLog(*logPath, maxSize, *logKeep); err != nil {
+			errorf("Cannot open log: %v", err)
+			os.Exit(exitUsage)
+		}
+		defer activity.Close()
+	}
+
+	if *daemonMode {
+		os.Exit(runDaemon(*socketPath, *apiAddr, *grpcAddr, *apiToken, *statePath, *concurrency, base, run, activity))
+	}
+
+	var jobs []wipeJob
+	for _, device := range blockDevices {
+		job := base
+		job.Device = device
+		jobs = append(jobs, job)
+	}
+	if *devicesFile != "" {
+		fileJobs, err := readDevicesFile(*devicesFile, base)
+		if err != nil {
+			errorf("Cannot read devices file: %v", err)
+			os.Exit(exitUsage)
+		}
+		jobs = append(jobs, fileJobs...)
+	}
+
+	if len(jobs) == 0 {
+		errorf("Block device path is required")
+		fmt.Println("Usage: go-wiper -device /path/to/device [-devices-file FILE] [-parallel] [-buffer N] [-skip N] [-auto-skip] [-target-hours N] [-force]")
+		os.Exit(exitUsage)
+	}
+
+	if *perPartition && *partitionsOnly {
+		errorf("-per-partition and -preserve-table cannot be combined; -preserve-table already wipes only the partitions")
+		os.Exit(exitUsage)
+	}
+	if *perPartition {
+		jobs = expandPartitions(jobs)
+	}
+
+	if device := duplicateDevice(jobs); device != "" {
+		errorf("%s is listed more than once", device)
+		os.Exit(exitUsage)
+	}
+	if *checkpointPath != "" && len(jobs) > 1 {
+		errorf("-checkpoint can only be used with a single device; use checkpoint= overrides in the devices file instead")
+		os.Exit(exitUsage)
+	}
+	if *expectSerial != "" && len(jobs) > 1 {
+		errorf("-expect-serial can only be used with a single device; use expect-serial= overrides in the devices file instead")
+		os.Exit(exitUsage)
+	}
+	if *certificatePath != "" && *certificatePath != certificateAuto && len(jobs) > 1 {
+		errorf("-certificate can only name a file for a single device; use -certificate auto or certificate= overrides in the devices file instead")
+		os.Exit(exitUsage)
+	}
+	if *summaryPath != "" && *summaryPath != certificateAuto && len(jobs) > 1 {
+		errorf("-summary can only name a file for a single device; use -summary auto or summary= overrides in the devices file instead")
+		os.Exit(exitUsage)
+	}
+
+	for _, job := range jobs {
+		if err := validateJob(job); err != nil {
+			errorf("%v (%s)", err, job.Device)
+			os.Exit(exitUsage)
+		}
+	}
+
+	if *benchSweep {
+		os.Exit(runBenchSweep(jobs, *force))
+	}
+
+	var startTime time.Time
+	if *startAt != "" {
+		t, err := parseStartTime(*startAt, time.Now())
+		if err != nil {
+			errorf("%v", err)
+			os.Exit(exitUsage)
+		}
+		startTime = t
+	}
+
+	// A later device that can't be written to shouldn't only turn up after
+	// the prompts and benchmarks for the earlier ones
+	if !*dryRun {
+		for _, job := range jobs {
+			if err := checkWritable(job.Device); err != nil {
+				errorf("%v", err)
+				os.Exit(exitCodeFor(err))
+			}
+		}
+	}
+
+	// Ask all questions up front so that unattended wipes aren't held up by
+	// a prompt for a later device
+	prepared := jobs[:0]
+	for _, job := range jobs {
+		job.dryRun = *dryRun
+		job.assumeYes = *assumeYes
+		err := prepareJob(context.Background(), &job, *force)
+		if errors.Is(err, errAlreadyWiped) {
+			fmt.Printf("Skipping %s\n", job.Device)
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, errAborted) {
+				fmt.Println("Operation aborted.")
+			} else {
+				errorf("%v", err)
+			}
+			os.Exit(exitCodeFor(err))
+		}
+		if *resume {
+			if job.resumeFrom, err = loadResumeCheckpoint(job.Checkpoint, &job); err != nil {
+				errorf("Cannot resume %s: %v", job.Device, err)
+				os.Exit(exitUsage)
+			}
+			if job.resumeFrom.Partition != 0 {
+				fmt.Printf("Resuming %s from %s into partition %d\n", job.Device, FormatBytes(job.resumeFrom.Offset), job.resumeFrom.Partition)
+			} else {
+				fmt.Printf("Resuming %s from %s (%.2f%%)\n", job.Device, FormatBytes(job.resumeFrom.Offset),
+					float64(job.resumeFrom.Offset)/float64(job.size)*100)
+			}
+		}
+		prepared = append(prepared, job)
+	}
+	jobs = prepared
+	if len(jobs) == 0 {
+		fmt.Println("Nothing to wipe.")
+		os.Exit(exitOK)
+	}
+	if *showConfig {
+		printConfig(flag.CommandLine, jobs)
+	}
+	if *dryRun {
+		printRunEstimate(jobs, *parallel)
+		fmt.Println("Dry run complete, nothing was written.")
+		os.Exit(exitOK)
+	}
+
+	// Stop at the next block boundary on Ctrl-C or SIGTERM (systemd stop,
+	// pod eviction)
+	ctx, stop := signalContext(syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if !startTime.IsZero() {
+		if err := waitUntil(ctx, startTime); err != nil {
+			fmt.Println("Scheduled wipe cancelled before it started.")
+			stop()
+			os.Exit(exitCodeFor(err))
+		}
+	}
+
+	fmt.Printf("quickwipe %s on %s, operator: %s, started at %s\n",
+		run.Version, run.Hostname, run.Operator, time.Now().UTC().Format(time.RFC3339))
+	printRunEstimate(jobs, *parallel)
+
+	var observe ProgressFunc
+	if *timelinePath != "" {
+		t, err := openTimeline(*timelinePath)
+		if err != nil {
+			errorf("Cannot open timeline: %v", err)
+			os.Exit(exitUsage)
+		}
+		defer t.Close()
+		observe = t.record
+	}
+	observe = teeProgress(observe, activity.progress)
+
+	// Under systemd, report progress and feed the watchdog while it lasts
+	sdNotify("READY=1")
+	watchdog := newServiceWatchdog()
+	go watchdog.run(ctx, func() bool { return false })
+	observe = teeProgress(observe, func(u Progress) {
+		watchdog.touch()
+		sdNotify(progressStatus(u))
+	})
+	devices := make([]string, len(jobs))
+	for i, job := range jobs {
+		devices[i] = job.Device
+	}
+	activity.event("run started", "version", run.Version, "hostname", run.Hostname, "operator", run.Operator, "devices", devices)
+
+	// Perform the wipe operations
+	wipeStart := time.Now()
+	outcomes := runJobs(ctx, jobs, *parallel, run, display, observe)
+	fmt.Printf("Finished at %s\n", time.Now().UTC().Format(time.RFC3339))
+
+	exitCode := exitOK
+	failed := 0
+	for _, outcome := range outcomes {
+		stream.result(outcome)
+		err := outcome.Err
+		if err == nil {
+			activity.event("wipe completed", "device", outcome.Job.Device, "result", outcome.Result)
+			if len(outcomes) > 1 {
+				fmt.Printf("%s: completed, %s overwritten in %s\n", outcome.Job.Device,
+					FormatBytes(outcome.Result.BytesWritten), FormatDuration(outcome.Result.Duration))
+			}
+			continue
+		}
+		failed++
+
+		var interrupted *InterruptedError
+		if errors.As(err, &interrupted) {
+			activity.failure("wipe stopped", "device", outcome.Job.Device, "offset", interrupted.Offset,
+				"checkpoint", interrupted.Checkpoint, "error", err.Error())
+			fmt.Printf("%s: wipe stopped at %s of %s (%.2f%%) after %s\n", outcome.Job.Device,
+				FormatBytes(interrupted.Offset), FormatBytes(outcome.Job.size),
+				float64(interrupted.Offset)/float64(outcome.Job.size)*100.0,
+				FormatDuration(outcome.Result.FinishedAt.Sub(outcome.Result.StartedAt)))
+			if errors.Is(err, errMaxDuration) {
+				fmt.Printf("Reached -max-duration: %s overwritten, %.2f%% of the device\n",
+					FormatBytes(outcome.Result.BytesWritten),
+					float64(outcome.Result.BytesWritten)/float64(outcome.Job.size)*100.0)
+			}
+			if interrupted.Checkpoint != "" {
+				fmt.Printf("Checkpoint written to %s\n", interrupted.Checkpoint)
+			}
+		} else {
+			activity.failure("wipe failed", "device", outcome.Job.Device, "error", err.Error())
+			errorf("Wiping device %s failed: %v", outcome.Job.Device, err)
+			var gone *DisconnectError
+			if errors.As(err, &gone) && gone.Checkpoint != "" {
+				fmt.Printf("Checkpoint written to %s; -resume continues from it once the device is back\n", gone.Checkpoint)
+			}
+		}
+
+		// A stop signal outranks individual device failures
+		if code := exitCodeFor(err); exitCode == exitOK || code > exitSignalBase {
+			exitCode = code
+		}
+	}
+
+	if len(outcomes) > 1 {
+		if failed == 0 {
+			successf("Wiped %d of %d devices successfully.", len(outcomes), len(outcomes))
+		} else {
+			fmt.Printf("Wiped %d of %d devices successfully.\n", len(outcomes)-failed, len(outcomes))
+		}
+	}
+
+	if *notify {
+		title := "quickwipe finished"
+		message := fmt.Sprintf("Wiped %s successfully", outcomes[0].Job.Device)
+		if len(outcomes) > 1 {
+			message = fmt.Sprintf("Wiped %d of %d devices successfully", len(outcomes)-failed, len(outcomes))
+		}
+		if failed > 0 {
+			title = "quickwipe failed"
+			if len(outcomes) == 1 {
+				message = fmt.Sprintf("Wiping %s did not complete: %v", outcomes[0].Job.Device, outcomes[0].Err)
+			}
+		}
+		sendNotification(title, message, failed > 0)
+	}
+	activity.event("run finished", "devices", len(outcomes), "succeeded", len(outcomes)-failed, "failed", failed,
+		"duration", time.Since(wipeStart).Round(time.Second).String(), "exit_code", exitCode)
+	stream.summary(outcomes, time.Since(wipeStart), exitCode)
+	if exitCode != exitOK {
+		stop()
+		os.Exit(exitCode)
+	}
+
+	successf("Device wiping completed successfully.")
+}
@@ -0,0 +1,80 @@
+package wipe
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// printConfig prints the value every flag ended up with after environment
+// variables, -workflow and the command line were applied, followed by the
+// settings each prepared job will actually run with. This is what a run
+// used, for the record.
+func printConfig(fs *flag.FlagSet, jobs []wipeJob) {
+	fmt.Println("Effective configuration:")
+	fs.VisitAll(func(f *flag.Flag) {
+		value := f.Value.String()
+		if value == "" {
+			value = `""`
+		}
+		if f.Value.String() == f.DefValue {
+			value += " (default)"
+		} else if f.Name == "api-token" {
+			value = "(set)"
+		}
+		fmt.Printf("  -%s = %s\n", f.Name, value)
+	})
+
+	for _, job := range jobs {
+		patterns := job.passPatterns()
+		fmt.Printf("%s:\n", job.Device)
+		fmt.Printf("  size:            %s (%d bytes)\n", FormatBytes(job.size), job.size)
+		fmt.Printf("  block size:      %s\n", describeBlockSize(job.Device))
+		fmt.Printf("  I/O mode:        %s\n", describeIOMode(job.Device, job.Options))
+		fmt.Printf("  buffer:          %s x %d in flight\n", FormatBytes(int64(job.BufferSize)), job.PipelineDepth)
+		fmt.Printf("  coverage:        %s\n", describeCoverage(job.coverage()))
+		fmt.Printf("  passes:          %d (%s)\n", len(patterns), strings.Join(patterns, ", "))
+		fmt.Printf("  random source:   %s, refreshed every %d write(s)\n", rngDescription(job.RNG), job.RandomRefresh)
+		fmt.Printf("  verify:          %t\n", job.Verify)
+		fmt.Printf("  checkpoint:      %s\n", job.Checkpoint)
+	}
+}
+
+func describeCoverage(c coverage) string {
+	if c.full() {
+		return "every block"
+	}
+	return c.String()
+}
+
+// describeBlockSize reports the logical and physical sector size the kernel
+// gives for the disk holding device.
+func describeBlockSize(device string) string {
+	queue := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)), "queue")
+	logical := readSysfsString(filepath.Join(queue, "logical_block_size"))
+	physical := readSysfsString(filepath.Join(queue, "physical_block_size"))
+	if logical == "" {
+		return "unknown (not a block device)"
+	}
+	return fmt.Sprintf("%s bytes logical, %s bytes physical", logical, physical)
+}
+
+// describeIOMode reports whether wipeDevice will get direct I/O on device or
+// fall back to buffered I/O, by trying to open it the same way, and how its
+// writes are synced with opts.
+func describeIOMode(device string, opts Options) string {
+	if isRegularFile(device) {
+		return "buffered, " + describeSync(opts) + " (regular file)"
+	}
+	if opts.NoDirect {
+		return "buffered, " + describeSync(opts) + " (-no-direct)"
+	}
+	file, err := openDirect(device, os.O_WRONLY)
+	if err != nil {
+		return "buffered, " + describeSync(opts) + " (direct I/O not supported)"
+	}
+	file.Close()
+	return "direct (" + directIOFlag + "), " + describeSync(opts)
+}
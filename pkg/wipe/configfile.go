@@ -0,0 +1,142 @@
+package wipe
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// applyConfigFile sets flags in fs from a config file of key = value lines
+// named after the flags, in a small subset of TOML:
+//
+//	# standard policy
+//	device = ["/dev/sdb", "/dev/sdc"]
+//	buffer = "8M"
+//	passes = 3
+//	verify = true
+//
+// Values may be bare, quoted strings, or arrays of strings for flags that
+// can be repeated. Blank lines and lines starting with '#' are ignored.
+// Flags that were already set, on the command line or through the
+// environment, keep their value; everything is parsed by the flags
+// themselves, so the file is validated exactly like the command line.
+func applyConfigFile(path string, fs *flag.FlagSet) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: expected key = value, got %q", path, lineNo, line)
+		}
+		key = strings.TrimSpace(key)
+		if fs.Lookup(key) == nil || key == "config" {
+			return fmt.Errorf("%s:%d: unknown option %q", path, lineNo, key)
+		}
+		if seen[key] {
+			return fmt.Errorf("%s:%d: %s is set twice", path, lineNo, key)
+		}
+		seen[key] = true
+
+		values, err := parseConfigValue(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s: %v", path, lineNo, key, err)
+		}
+		if explicit[key] {
+			continue
+		}
+		for _, value := range values {
+			if err := fs.Set(key, value); err != nil {
+				return fmt.Errorf("%s:%d: invalid value %q for %s: %v", path, lineNo, value, key, err)
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// parseConfigValue parses a bare value, a quoted string or an array of
+// them, ignoring a trailing comment.
+func parseConfigValue(raw string) ([]string, error) {
+	if inner, ok := strings.CutPrefix(raw, "["); ok {
+		end := strings.LastIndex(inner, "]")
+		if end < 0 || !isConfigComment(inner[end+1:]) {
+			return nil, fmt.Errorf("unterminated array %q", raw)
+		}
+		var values []string
+		for _, item := range strings.Split(inner[:end], ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			value, rest, err := parseConfigScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			if rest != "" {
+				return nil, fmt.Errorf("unexpected %q after %q", rest, value)
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	value, rest, err := parseConfigScalar(raw)
+	if err != nil {
+		return nil, err
+	}
+	if !isConfigComment(rest) {
+		return nil, fmt.Errorf("unexpected %q after %q", rest, value)
+	}
+	return []string{value}, nil
+}
+
+// parseConfigScalar parses a quoted string or a bare word at the start of
+// raw and returns it together with the rest of raw.
+func parseConfigScalar(raw string) (string, string, error) {
+	if strings.HasPrefix(raw, `"`) {
+		end := strings.Index(raw[1:], `"`)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", raw)
+		}
+		value, err := strconv.Unquote(raw[:end+2])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", raw[:end+2])
+		}
+		return value, strings.TrimSpace(raw[end+2:]), nil
+	}
+	if strings.HasPrefix(raw, "'") {
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : end+1], strings.TrimSpace(raw[end+2:]), nil
+	}
+	value, rest, _ := strings.Cut(raw, "#")
+	if value = strings.TrimSpace(value); value == "" {
+		return "", "", fmt.Errorf("missing value")
+	}
+	if rest != "" {
+		rest = "#" + rest
+	}
+	return value, rest, nil
+}
+
+func isConfigComment(rest string) bool {
+	rest = strings.TrimSpace(rest)
+	return rest == "" || strings.HasPrefix(rest, "#")
+}
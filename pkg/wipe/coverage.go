@@ -0,0 +1,186 @@
+package wipe
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// coverageScale is the resolution used when turning a coverage fraction into
+// a ratio. -coverage stops at 1%, but a Coverage set through the APIs or
+// the library can go down to one block in a million.
+const coverageScale = 1000000
+
+// Skip modes: how a partial wipe chooses which blocks to write.
+const (
+	skipModeStride = "stride" // at regular intervals
+	skipModeRandom = "random" // at pseudo-random positions derived from a seed
+)
+
+// coverage is the fraction of buffer-sized blocks that get written: Num out
+// of every Den. An integer skip factor N is the ratio 1/N.
+type coverage struct {
+	Num int64 `json:"num"`
+	Den int64 `json:"den"`
+}
+
+// skipCoverage returns the coverage of writing every skipFactor-th block.
+func skipCoverage(skipFactor int) coverage {
+	return coverage{Num: 1, Den: int64(skipFactor)}
+}
+
+// parseCoverage parses a -coverage percentage from 1 to 100, with or
+// without a % sign, into the fraction of blocks to write.
+func parseCoverage(value string) (float64, error) {
+	s := strings.TrimSpace(value)
+	f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, fmt.Errorf("invalid coverage %q; give a percentage from 1 to 100", value)
+	}
+	if f > 0 && f < 1 && !strings.HasSuffix(s, "%") {
+		return 0, fmt.Errorf("coverage %q looks like a fraction; give a percentage from 1 to 100, e.g. %.4g", value, f*100)
+	}
+	if f < 1 || f > 100 {
+		return 0, fmt.Errorf("coverage %q is out of range; give a percentage from 1 to 100", value)
+	}
+	return f / 100, nil
+}
+
+// fractionCoverage returns the coverage closest to fraction, which must be in
+// (0, 1].
+func fractionCoverage(fraction float64) coverage {
+	c := coverage{Num: int64(math.Round(fraction * coverageScale)), Den: coverageScale}
+	if c.Num < 1 {
+		c.Num = 1
+	}
+	g := gcd(c.Num, c.Den)
+	return coverage{Num: c.Num / g, Den: c.Den / g}
+}
+
+// full reports whether every block is written.
+func (c coverage) full() bool {
+	return c.Num >= c.Den
+}
+
+func (c coverage) String() string {
+	if c.full() {
+		return "every block"
+	}
+	if c.Num == 1 {
+		return fmt.Sprintf("1 in every %d blocks", c.Den)
+	}
+	return fmt.Sprintf("%.4g%% of blocks", float64(c.Num)/float64(c.Den)*100.0)
+}
+
+// describe is String for blocks chosen with skip mode mode: a random
+// choice has no interval to name.
+func (c coverage) describe(mode string) string {
+	if mode == skipModeRandom {
+		return fmt.Sprintf("%.4g%% of blocks at random", float64(c.Num)/float64(c.Den)*100.0)
+	}
+	return c.String()
+}
+
+// blockSelector decides which blocks of a coverage-limited wipe are written.
+// It keeps an integer accumulator so that exactly Num of every Den blocks
+// are written, spread evenly, even when the ratio is not 1/N. The first
+// block is always written.
+type blockSelector struct {
+	coverage
+	acc int64
+
+	// A gap selector picks the blocks a pass with this coverage skipped,
+	// starting with the lead blocks before the pass started.
+	gaps bool
+	lead int64
+
+	// A random selector writes Num blocks at shuffled positions in every
+	// group of Den, so the coverage is as exact as with even spacing; acc
+	// is then the index of the next block, and the first block is not
+	// always written.
+	random bool
+	seed   uint64
+}
+
+func newBlockSelector(c coverage) *blockSelector {
+	return &blockSelector{coverage: c, acc: c.Den - c.Num}
+}
+
+// newGapSelector returns a selector for the blocks that a pass with
+// coverage c left unwritten, when it started lead blocks into the device.
+func newGapSelector(c coverage, lead int64) *blockSelector {
+	return &blockSelector{coverage: c, acc: c.Den - c.Num, gaps: true, lead: lead}
+}
+
+// newRandomSelector returns a selector that picks the blocks of coverage c
+// pseudo-randomly from seed, or the blocks it skips if gaps is set.
+func newRandomSelector(c coverage, seed uint64, gaps bool) *blockSelector {
+	return &blockSelector{coverage: c, gaps: gaps, random: true, seed: seed}
+}
+
+// next reports whether the next block should be written.
+func (s *blockSelector) next() bool {
+	if s.lead > 0 {
+		s.lead--
+		return true
+	}
+	var written bool
+	if s.random {
+		group, pos := s.acc/s.Den, s.acc%s.Den
+		written = permuteIndex(mix64(s.seed, uint64(group)), pos, s.Den) < s.Num
+		s.acc++
+	} else {
+		s.acc += s.Num
+		written = s.acc >= s.Den
+		if written {
+			s.acc -= s.Den
+		}
+	}
+	return written != s.gaps
+}
+
+// permuteIndex maps x in [0, n) to a position in [0, n) with a keyed
+// four-round Feistel network over the smallest even number of bits that
+// holds n, walking the cycle until it lands inside the range.
+func permuteIndex(key uint64, x, n int64) int64 {
+	half := (bits.Len64(uint64(n-1)) + 1) / 2
+	mask := uint64(1)<<half - 1
+	v := uint64(x)
+	for {
+		l, r := v>>half, v&mask
+		for round := range uint64(4) {
+			l, r = r, l^(mix64(key+round, r)&mask)
+		}
+		if v = l<<half | r; v < uint64(n) {
+			return int64(v)
+		}
+	}
+}
+
+// mix64 hashes a and b into well-mixed 64 bits with the SplitMix64
+// finalizer.
+func mix64(a, b uint64) uint64 {
+	z := a ^ b*0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	return z ^ z>>31
+}
+
+// skipRun consumes the decisions for the blocks following a written block
+// and returns how many of them are skipped before the next write.
+func (s *blockSelector) skipRun() int64 {
+	var skipped int64
+	for !s.next() {
+		skipped++
+	}
+	return skipped
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
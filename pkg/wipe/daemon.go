@@ -0,0 +1,670 @@
+package wipe
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// daemonQueueSize bounds the number of jobs waiting to run.
+const daemonQueueSize = 256
+
+// jobState is the lifecycle stage of a daemon job.
+type jobState string
+
+const (
+	jobQueued    jobState = "queued"
+	jobRunning   jobState = "running"
+	jobDone      jobState = "done"
+	jobFailed    jobState = "failed"
+	jobCancelled jobState = "cancelled"
+)
+
+// Errors returned by the daemon's job operations.
+var (
+	errNoSuchJob    = errors.New("no such job")
+	errQueueFull    = errors.New("job queue is full")
+	errJobFinished  = errors.New("job has already finished")
+	errJobCancelled = errors.New("job cancelled")
+	errDaemonNoExcl = errors.New("no_excl cannot be set for daemon jobs: devices are always opened exclusively")
+	errUnauthorized = errors.New("missing or wrong API token")
+)
+
+// daemonJob is a wipe submitted to the daemon together with its status.
+type daemonJob struct {
+	ID          string     `json:"id"`
+	Spec        wipeJob    `json:"spec"`
+	Serial      string     `json:"serial,omitempty"`
+	State       jobState   `json:"state"`
+	Error       string     `json:"error,omitempty"`
+	Progress    *Progress  `json:"progress,omitempty"`
+	Result      *Result    `json:"result,omitempty"`
+	SubmittedAt time.Time  `json:"submitted_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	// ResumeFrom names the checkpoint of an interrupted run to continue from.
+	ResumeFrom string `json:"resume_from,omitempty"`
+
+	// Checkpoint is the file the running job keeps up to date, recorded
+	// when it starts so that a daemon that crashed can resume from it.
+	Checkpoint string `json:"checkpoint,omitempty"`
+}
+
+// daemon queues wipe jobs received over HTTP and runs them with bounded
+// concurrency.
+type daemon struct {
+	defaults  wipeJob
+	run       runInfo
+	statePath string // where the job list is persisted; "" disables persistence
+	activity  *activityLog
+	watchdog  *serviceWatchdog
+
+	mu      sync.Mutex
+	jobs    []*daemonJob // in submission order
+	nextID  int
+	queue   chan *daemonJob
+	cancels map[string]context.CancelCauseFunc // of running jobs, by ID
+}
+
+// runDaemon serves the job API on a Unix socket until SIGINT or SIGTERM and
+// returns the process exit code. Jobs are never confirmed interactively, so
+// access to the socket must be restricted to trusted users. The job list is
+// persisted to statePath so that history survives restarts and unfinished
+// jobs are picked up again. If apiAddr or grpcAddr is set the same jobs can
+// also be managed over HTTP or gRPC on that TCP address; clients there must
+// send apiToken, which may only be empty on a loopback address.
+func runDaemon(socketPath, apiAddr, grpcAddr, apiToken, statePath string, concurrency int, defaults wipeJob, run runInfo, activity *activityLog) int {
+	if concurrency < 1 {
+		errorf("Concurrency must be at least 1")
+		return exitUsage
+	}
+	for name, addr := range map[string]string{"-api-addr": apiAddr, "-grpc-addr": grpcAddr} {
+		if addr != "" && apiToken == "" && !isLoopbackAddr(addr) {
+			errorf("%s %s is reachable from other hosts; set -api-token (or QUICKWIPE_API_TOKEN) or listen on a loopback address", name, addr)
+			return exitUsage
+		}
+	}
+	if defaults.NoExcl {
+		errorf("-no-excl cannot be combined with -daemon: daemon jobs always open devices exclusively")
+		return exitUsage
+	}
+
+	// Remove a stale socket left behind by an unclean shutdown
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errorf("Cannot remove stale socket: %v", err)
+		return exitUsage
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		errorf("Cannot listen on %s: %v", socketPath, err)
+		return exitUsage
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0o600); err != nil {
+		warnf("Cannot restrict socket permissions: %v", err)
+	}
+
+	d := &daemon{
+		defaults:  defaults,
+		run:       run,
+		statePath: statePath,
+		activity:  activity,
+		watchdog:  newServiceWatchdog(),
+		queue:     make(chan *daemonJob, daemonQueueSize),
+		cancels:   make(map[string]context.CancelCauseFunc),
+	}
+	if err := d.load(); err != nil {
+		errorf("Cannot load job state: %v", err)
+		return exitUsage
+	}
+
+	var apiListener, grpcListener net.Listener
+	if apiAddr != "" {
+		apiListener, err = net.Listen("tcp", apiAddr)
+		if err != nil {
+			errorf("Cannot listen on %s: %v", apiAddr, err)
+			return exitUsage
+		}
+	}
+	if grpcAddr != "" {
+		grpcListener, err = net.Listen("tcp", grpcAddr)
+		if err != nil {
+			errorf("Cannot listen on %s: %v", grpcAddr, err)
+			return exitUsage
+		}
+	}
+
+	ctx, stop := signalContext(syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var workers sync.WaitGroup
+	for range concurrency {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			d.worker(ctx)
+		}()
+	}
+
+	server := &http.Server{Handler: d.handler(false)}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	if apiListener != nil {
+		apiServer := &http.Server{Handler: requireToken(apiToken, d.handler(true))}
+		go apiServer.Serve(apiListener)
+		defer apiServer.Close()
+		fmt.Printf("Serving HTTP API on %s\n", apiListener.Addr())
+	}
+
+	if grpcListener != nil {
+		grpcServer := newGRPCServer(d, apiToken)
+		go grpcServer.Serve(grpcListener)
+		defer grpcServer.Stop()
+		fmt.Printf("Serving gRPC on %s\n", grpcListener.Addr())
+	}
+
+	fmt.Printf("quickwipe %s daemon listening on %s with concurrency %d\n", run.Version, socketPath, concurrency)
+	sdNotify("READY=1\nSTATUS=Idle")
+	go d.watchdog.run(ctx, d.idle)
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		errorf("%v", err)
+		return exitDeviceError
+	}
+
+	// Running jobs checkpoint themselves when ctx is cancelled
+	sdNotify("STOPPING=1")
+	workers.Wait()
+	fmt.Println("Daemon stopped.")
+	return exitOK
+}
+
+// handler serves the job API. Remote clients, those of -api-addr, may not
+// name files on the daemon's host in their jobs.
+func (d *daemon) handler(remote bool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", func(w http.ResponseWriter, r *http.Request) { d.handleSubmit(w, r, remote) })
+	mux.HandleFunc("GET /jobs", d.handleList)
+	mux.HandleFunc("GET /jobs/{id}", d.handleGet)
+	mux.HandleFunc("DELETE /jobs/{id}", d.handleCancel)
+	mux.HandleFunc("GET /jobs/{id}/certificate", d.handleCertificate)
+	return mux
+}
+
+// handleSubmit queues a new job. The body is a JSON wipeJob; omitted fields
+// take the daemon's command-line defaults.
+func (d *daemon) handleSubmit(w http.ResponseWriter, r *http.Request, remote bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid job: %v", err))
+		return
+	}
+	spec := d.defaults
+	spec.Checkpoint = ""
+	if err := json.Unmarshal(body, &spec); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid job: %v", err))
+		return
+	}
+	if remote {
+		// Decode the body again on its own, so that the check sees what the
+		// client sent rather than the daemon's defaults, matched to the
+		// fields the same case-insensitive way.
+		var sent wipeJob
+		json.Unmarshal(body, &sent)
+		if err := checkRemoteSpec(sent); err != nil {
+			writeJSONError(w, http.StatusForbidden, err)
+			return
+		}
+	}
+	snapshot, err := d.submit(spec)
+	switch {
+	case errors.Is(err, errQueueFull):
+		writeJSONError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, snapshot)
+}
+
+// submit validates spec and queues it as a new job. Options left at zero
+// get their defaults.
+func (d *daemon) submit(spec wipeJob) (daemonJob, error) {
+	spec.Options = spec.Options.withDefaults()
+	if err := validateJob(spec); err != nil {
+		return daemonJob{}, err
+	}
+	if spec.NoExcl {
+		return daemonJob{}, errDaemonNoExcl
+	}
+
+	d.mu.Lock()
+	d.nextID++
+	job := &daemonJob{
+		ID:          strconv.Itoa(d.nextID),
+		Spec:        spec,
+		State:       jobQueued,
+		SubmittedAt: time.Now().UTC(),
+	}
+	select {
+	case d.queue <- job:
+		d.jobs = append(d.jobs, job)
+		d.save()
+	default:
+		d.mu.Unlock()
+		return daemonJob{}, errQueueFull
+	}
+	snapshot := *job
+	d.mu.Unlock()
+
+	fmt.Printf("Job %s queued: %s\n", job.ID, spec.Device)
+	return snapshot, nil
+}
+
+// cancel stops a job. Queued jobs are cancelled straight away; running ones
+// are interrupted and marked cancelled once the wipe has stopped.
+func (d *daemon) cancel(id string) (daemonJob, error) {
+	job := d.lookup(id)
+	if job == nil {
+		return daemonJob{}, errNoSuchJob
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch job.State {
+	case jobQueued:
+		finished := time.Now().UTC()
+		job.State = jobCancelled
+		job.Error = errJobCancelled.Error()
+		job.FinishedAt = &finished
+		d.save()
+		fmt.Printf("Job %s cancelled: %s\n", job.ID, job.Spec.Device)
+	case jobRunning:
+		if cancel := d.cancels[id]; cancel != nil {
+			cancel(errJobCancelled)
+		}
+	default:
+		return *job, errJobFinished
+	}
+	return *job, nil
+}
+
+// snapshot returns a copy of the job with the given ID.
+func (d *daemon) snapshot(id string) (daemonJob, error) {
+	job := d.lookup(id)
+	if job == nil {
+		return daemonJob{}, errNoSuchJob
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return *job, nil
+}
+
+// handleList returns all jobs, optionally only those for the disk with the
+// serial number given in the "serial" query parameter.
+func (d *daemon) handleList(w http.ResponseWriter, r *http.Request) {
+	serial := r.URL.Query().Get("serial")
+
+	d.mu.Lock()
+	snapshots := make([]daemonJob, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		if serial == "" || job.Serial == serial {
+			snapshots = append(snapshots, *job)
+		}
+	}
+	d.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+func (d *daemon) handleGet(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := d.snapshot(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// handleCancel cancels a queued or running job. A running wipe stops at the
+// next block, so the returned job may still be running.
+func (d *daemon) handleCancel(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := d.cancel(r.PathValue("id"))
+	switch {
+	case errors.Is(err, errNoSuchJob):
+		writeJSONError(w, http.StatusNotFound, err)
+	case errors.Is(err, errJobFinished):
+		writeJSONError(w, http.StatusConflict, err)
+	default:
+		writeJSON(w, http.StatusAccepted, snapshot)
+	}
+}
+
+// handleCertificate returns the wipe certificate of a finished job.
+func (d *daemon) handleCertificate(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := d.snapshot(r.PathValue("id"))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	cert, err := jobCertificate(snapshot, d.run)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cert)
+}
+
+func (d *daemon) lookup(id string) *daemonJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, job := range d.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// worker runs queued jobs until ctx is cancelled. Jobs still queued at that
+// point are left untouched; jobs cancelled while queued are skipped.
+func (d *daemon) worker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-d.queue:
+			d.process(ctx, job)
+		}
+	}
+}
+
+func (d *daemon) process(ctx context.Context, job *daemonJob) {
+	d.mu.Lock()
+	if job.State == jobCancelled {
+		d.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	d.cancels[job.ID] = cancel
+	started := time.Now().UTC()
+	job.State = jobRunning
+	job.StartedAt = &started
+	job.Serial = deviceSerial(job.Spec.Device)
+	spec := job.Spec
+	resumeFrom := job.ResumeFrom
+	d.save()
+	d.mu.Unlock()
+
+	d.logf("Job %s started: %s", job.ID, spec.Device)
+	d.watchdog.touch()
+
+	// Daemon jobs are confirmed by the act of submitting them, but the
+	// safety checks still apply: mounted disks and devices in use are
+	// refused, as with -assume-yes. Jobs from an older state file may
+	// predate the no_excl check.
+	spec.assumeYes = true
+	err := errDaemonNoExcl
+	if !spec.NoExcl {
+		err = prepareJob(ctx, &spec, false)
+	}
+	var resume *checkpoint
+	if err == nil && resumeFrom != "" {
+		resume, err = loadResumeCheckpoint(resumeFrom, &spec)
+	} else if err == nil {
+		// A leftover checkpoint of an older wipe would be taken for this
+		// one's if the daemon crashed before it wrote its first
+		os.Remove(spec.Checkpoint)
+	}
+	if err == nil {
+		d.mu.Lock()
+		job.Checkpoint = spec.Checkpoint
+		d.save()
+		d.mu.Unlock()
+	}
+	var result Result
+	if err == nil {
+		result, err = runJob(ctx, spec, resume, d.run,
+			teeProgress(func(u Progress) {
+				d.mu.Lock()
+				job.Progress = &u
+				status := d.status()
+				d.mu.Unlock()
+				d.watchdog.touch()
+				sdNotify(status)
+			}, d.activity.progress))
+	}
+
+	d.mu.Lock()
+	delete(d.cancels, job.ID)
+	finished := time.Now().UTC()
+	job.Spec = spec
+	var interrupted *InterruptedError
+	switch {
+	case errors.Is(err, errJobCancelled):
+		// A cancelled job is never resumed, so drop its checkpoint
+		if errors.As(err, &interrupted) && interrupted.Checkpoint != "" {
+			os.Remove(interrupted.Checkpoint)
+		}
+		interrupted = nil
+		job.State = jobCancelled
+		job.Error = errJobCancelled.Error()
+		job.ResumeFrom = ""
+		job.FinishedAt = &finished
+	case errors.Is(err, errMaxDuration):
+		// Out of time: running it again at once would defeat the limit
+		interrupted = nil
+		job.State = jobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finished
+	case errors.As(err, &interrupted) && interrupted.Checkpoint != "":
+		// Stopped by shutdown: run it again, from the checkpoint, next time
+		job.State = jobQueued
+		job.ResumeFrom = interrupted.Checkpoint
+	case interrupted == nil && errors.Is(err, context.Canceled):
+		// Stopped by shutdown while preparing, before anything was written
+		job.State = jobQueued
+	case err != nil:
+		job.State = jobFailed
+		job.Error = err.Error()
+		job.FinishedAt = &finished
+	default:
+		job.State = jobDone
+		job.Result = &result
+		job.ResumeFrom = ""
+		job.FinishedAt = &finished
+	}
+	state := job.State
+	d.save()
+	d.mu.Unlock()
+
+	if state == jobCancelled {
+		d.logf("Job %s cancelled: %s", job.ID, spec.Device)
+	} else if interrupted != nil {
+		d.logf("Job %s interrupted: %s: will resume from %s", job.ID, spec.Device, interrupted.Checkpoint)
+	} else if state == jobQueued {
+		d.logf("Job %s interrupted before writing: %s: will run again", job.ID, spec.Device)
+	} else if err != nil {
+		d.logf("Job %s failed: %s: %v", job.ID, spec.Device, err)
+	} else {
+		d.logf("Job %s done: %s: %s", job.ID, spec.Device, result)
+	}
+}
+
+// idle reports whether no job is running.
+func (d *daemon) idle() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.cancels) == 0
+}
+
+// status summarizes the running jobs as a systemd STATUS= line. d.mu must be
+// held.
+func (d *daemon) status() string {
+	var running []string
+	queued := 0
+	for _, job := range d.jobs {
+		switch {
+		case job.State == jobQueued:
+			queued++
+		case job.State == jobRunning && job.Progress != nil:
+			running = append(running, fmt.Sprintf("job %s %s %.1f%%", job.ID, job.Spec.Device,
+				float64(job.Progress.BytesProcessed)/float64(job.Progress.Total)*100))
+		}
+	}
+	return fmt.Sprintf("STATUS=Wiping: %s; %d queued", strings.Join(running, ", "), queued)
+}
+
+// logf prints a job event and adds it to the -log file.
+func (d *daemon) logf(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
+	d.activity.printf(format, args...)
+}
+
+// load restores the job list from the state file and queues every job that
+// had not finished, including ones that were running when the daemon
+// stopped.
+func (d *daemon) load() error {
+	if d.statePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(d.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.MkdirAll(filepath.Dir(d.statePath), 0o700)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &d.jobs); err != nil {
+		return fmt.Errorf("invalid state file %s: %v", d.statePath, err)
+	}
+
+	for _, job := range d.jobs {
+		if id, err := strconv.Atoi(job.ID); err == nil && id > d.nextID {
+			d.nextID = id
+		}
+		if job.State != jobQueued && job.State != jobRunning {
+			continue
+		}
+		if job.State == jobRunning && job.Checkpoint != "" {
+			// The daemon stopped without interrupting the job, which kept
+			// its checkpoint up to date until then
+			job.ResumeFrom = ""
+			if cp, err := readCheckpoint(job.Checkpoint); err == nil && cp.Device == job.Spec.Device {
+				job.ResumeFrom = job.Checkpoint
+			} else {
+				warnf("Job %s: no usable checkpoint %s, starting over", job.ID, job.Checkpoint)
+			}
+		}
+		job.State = jobQueued
+		job.Progress = nil
+		select {
+		case d.queue <- job:
+			fmt.Printf("Job %s requeued: %s\n", job.ID, job.Spec.Device)
+		default:
+			job.State = jobFailed
+			job.Error = "job queue is full"
+		}
+	}
+	return nil
+}
+
+// save persists the job list. Callers must hold d.mu.
+func (d *daemon) save() {
+	if d.statePath == "" {
+		return
+	}
+	if err := writeJSONFile(d.statePath, d.jobs); err != nil {
+		warnf("Cannot save job state: %v", err)
+	}
+}
+
+// checkRemoteSpec rejects a job submitted over the network that names a
+// file on the daemon's host, which root would read or write. Only clients of
+// the Unix socket may set those fields; over the network "auto" is the only
+// path accepted, for the fields that have it.
+func checkRemoteSpec(sent wipeJob) error {
+	for _, field := range []struct {
+		name, value string
+		allowsAuto  bool
+	}{
+		{"checkpoint", sent.Checkpoint, false},
+		{"pattern_file", sent.PatternFile, false},
+		{"certificate", sent.Certificate, true},
+		{"summary", sent.Summary, true},
+	} {
+		if field.value == "" || field.allowsAuto && field.value == certificateAuto {
+			continue
+		}
+		if field.allowsAuto {
+			return fmt.Errorf("%s names a file on the daemon's host; over the network only %q is accepted", field.name, certificateAuto)
+		}
+		return fmt.Errorf("%s names a file on the daemon's host and can only be set through the Unix socket", field.name)
+	}
+	return nil
+}
+
+// isLoopbackAddr reports whether the TCP listen address addr only accepts
+// connections from this host. An empty host listens on every interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// validToken reports whether the Authorization header value authorization
+// carries token as a bearer token. An empty token accepts everything.
+func validToken(authorization, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(authorization, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// requireToken wraps next so that requests without the bearer token are
+// refused with 401 Unauthorized.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !validToken(r.Header.Get("Authorization"), token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSONError(w, http.StatusUnauthorized, errUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,115 @@
+package wipe
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// dashboard renders concurrent wipes as a block of terminal lines that is
+// redrawn in place: an aggregate header followed by one fixed line per
+// device.
+type dashboard struct {
+	mu      sync.Mutex
+	devices []string
+	latest  map[string]Progress
+	status  map[string]string // final status once a device is finished
+	drawn   bool
+
+	totalETA time.Duration // of the whole run, from the latest update that had it
+}
+
+func newDashboard(devices []string) *dashboard {
+	return &dashboard{
+		devices: devices,
+		latest:  make(map[string]Progress),
+		status:  make(map[string]string),
+	}
+}
+
+// update is a ProgressFunc recording u and redrawing the dashboard.
+func (d *dashboard) update(u Progress) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.latest[u.Device] = u
+	d.totalETA = u.TotalETA
+	d.render()
+}
+
+// finish replaces the device's progress line with a final status and
+// accounts for the bytes processed since its last progress update.
+func (d *dashboard) finish(result Result, status string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if result.Size > 0 {
+		d.latest[result.Device] = Progress{
+			Device:         result.Device,
+			BytesProcessed: result.BytesProcessed,
+			BytesWritten:   result.BytesWritten,
+			Total:          result.Size,
+		}
+	}
+	d.status[result.Device] = status
+	d.render()
+}
+
+// render redraws all lines. Callers must hold d.mu.
+func (d *dashboard) render() {
+	var b strings.Builder
+	if d.drawn {
+		// Move back to the header line
+		fmt.Fprintf(&b, "\033[%dA", len(d.devices)+1)
+	}
+
+	var processed, total int64
+	var speed float64
+	var eta time.Duration
+	for _, device := range d.devices {
+		u, ok := d.latest[device]
+		if !ok {
+			continue
+		}
+		processed += u.BytesProcessed
+		total += u.Total
+		if _, done := d.status[device]; !done {
+			speed += u.Speed
+			eta = max(eta, u.ETA) // devices run concurrently
+		}
+	}
+
+	if d.totalETA > 0 {
+		eta = d.totalETA
+	}
+
+	percent := 0.0
+	if total > 0 {
+		percent = float64(processed) / float64(total) * 100.0
+	}
+	fmt.Fprintf(&b, "\r\033[KTotal: %.2f%% (%s/%s) at %.2f MB/s, ETA: %s\n",
+		percent, FormatBytes(processed), FormatBytes(total), speed/1024/1024, FormatDuration(eta))
+
+	width := 0
+	for _, device := range d.devices {
+		width = max(width, len(device))
+	}
+	for _, device := range d.devices {
+		line := "waiting..."
+		if status, ok := d.status[device]; ok {
+			line = status
+		} else if u, ok := d.latest[device]; ok {
+			line = u.String()
+		}
+		fmt.Fprintf(&b, "\r\033[K%-*s  %s\n", width, device, line)
+	}
+
+	fmt.Print(b.String())
+	d.drawn = true
+}
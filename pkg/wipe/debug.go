@@ -0,0 +1,121 @@
+package wipe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// maxReportedAlignment caps the buffer alignment -debug reports. Mapped
+// buffers start on a page and often far beyond, which direct I/O never
+// needs.
+const maxReportedAlignment = 1 << 20
+
+// openFlagNames names the flags of mode, followed by the direct I/O flag if
+// the page cache was bypassed.
+func openFlagNames(mode int, direct bool) string {
+	var names []string
+	switch mode & (os.O_WRONLY | os.O_RDWR) {
+	case os.O_WRONLY:
+		names = append(names, "O_WRONLY")
+	case os.O_RDWR:
+		names = append(names, "O_RDWR")
+	default:
+		names = append(names, "O_RDONLY")
+	}
+	if mode&syscall.O_EXCL != 0 {
+		names = append(names, "O_EXCL")
+	}
+	if mode&syscall.O_SYNC == syscall.O_SYNC {
+		names = append(names, "O_SYNC")
+	}
+	if direct {
+		names = append(names, directIOFlag)
+	}
+	return strings.Join(names, "|")
+}
+
+// errnoName returns the symbolic name of the errno behind err, such as
+// EINVAL, or what err says if there is none.
+func errnoName(err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if name := unix.ErrnoName(errno); name != "" {
+			return name
+		}
+	}
+	return err.Error()
+}
+
+// memAlignment returns the largest power of two, up to
+// maxReportedAlignment, that the address of buf is a multiple of.
+func memAlignment(buf []byte) int {
+	addr := uintptr(unsafe.Pointer(unsafe.SliceData(buf)))
+	if addr == 0 {
+		return 0
+	}
+	return int(min(addr&-addr, maxReportedAlignment))
+}
+
+// describeWrite reports a write of buf at offset and whatever about it
+// breaks the alignment direct I/O needs with blocks of blockSize.
+func describeWrite(buf []byte, offset int64, blockSize int) string {
+	var problems []string
+	if len(buf)%blockSize != 0 {
+		problems = append(problems, "length")
+	}
+	if offset%int64(blockSize) != 0 {
+		problems = append(problems, "offset")
+	}
+	if memAlignment(buf) < blockSize {
+		problems = append(problems, "buffer address")
+	}
+	alignment := fmt.Sprintf("aligned to %d-byte blocks", blockSize)
+	if len(problems) > 0 {
+		alignment = fmt.Sprintf("%s not aligned to %d-byte blocks", strings.Join(problems, ", "), blockSize)
+	}
+	return fmt.Sprintf("%d bytes at offset %d from %#x (%s)",
+		len(buf), offset, uintptr(unsafe.Pointer(unsafe.SliceData(buf))), alignment)
+}
+
+// debugBlockSize reports the sector sizes path reports and the block size
+// writes to it are aligned to.
+func debugBlockSize(path string, blockSize int) {
+	if !debugEnabled {
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		debugf("%s: cannot read the sector size (%v), aligning to %d bytes", path, err, blockSize)
+		return
+	}
+	defer file.Close()
+	if logical, physical, ok := sectorSizes(file); ok {
+		debugf("%s: %d-byte logical and %d-byte physical sectors, aligning to %d bytes", path, logical, physical, blockSize)
+		return
+	}
+	debugf("%s: no sector size reported, aligning to %d bytes", path, blockSize)
+}
+
+// debugBuffers reports the size and memory alignment of the buffers a wipe
+// of path writes from.
+func debugBuffers(path string, buffers [][]byte) {
+	if !debugEnabled || len(buffers) == 0 {
+		return
+	}
+	alignment := maxReportedAlignment
+	for _, buf := range buffers {
+		alignment = min(alignment, memAlignment(buf))
+	}
+	aligned := fmt.Sprintf("%d bytes", alignment)
+	if alignment == maxReportedAlignment {
+		aligned += " or more"
+	}
+	debugf("%s: %d buffers of %d bytes from %#x, memory aligned to %s",
+		path, len(buffers), len(buffers[0]), uintptr(unsafe.Pointer(unsafe.SliceData(buffers[0]))), aligned)
+}
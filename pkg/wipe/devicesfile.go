@@ -0,0 +1,198 @@
+package wipe
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// deviceListFlag is a flag.Value collecting the devices given with -device,
+// which may be repeated and may hold a comma-separated list.
+type deviceListFlag []string
+
+func (f *deviceListFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *deviceListFlag) Set(value string) error {
+	for _, device := range strings.Split(value, ",") {
+		if device = strings.TrimSpace(device); device != "" {
+			*f = append(*f, device)
+		}
+	}
+	return nil
+}
+
+// readDevicesFile parses a batch file listing one device per line. Each path
+// may be followed by space-separated key=value overrides named after the
+// corresponding flags, e.g.
+//
+//	# tray 1
+//	/dev/sdb
+//	/dev/sdc skip=4 buffer=8388608
+//
+// Blank lines and lines starting with '#' are ignored. Every job starts as a
+// copy of base.
+func readDevicesFile(path string, base wipeJob) ([]wipeJob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var jobs []wipeJob
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		job := base
+		job.Device = fields[0]
+		for _, field := range fields[1:] {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				return nil, fmt.Errorf("%s:%d: expected key=value, got %q", path, lineNo, field)
+			}
+			if err := applyJobOverride(&job, key, value); err != nil {
+				return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+			}
+		}
+		jobs = append(jobs, job)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// applyJobOverride sets a single per-device option by its flag name.
+func applyJobOverride(job *wipeJob, key, value string) error {
+	var err error
+	switch key {
+	case "buffer":
+		job.BufferSize, err = parseBufferSize(value)
+	case "skip":
+		// Another way of choosing the blocks replaces the one given for all
+		// devices
+		job.SkipFactor, err = strconv.Atoi(value)
+		job.Coverage, job.AutoSkip = 0, false
+	case "coverage":
+		job.Coverage, err = parseCoverage(value)
+		job.SkipFactor, job.AutoSkip = 1, false
+	case "skip-mode":
+		job.SkipMode = value
+	case "skip-seed":
+		job.SkipSeed, err = strconv.ParseUint(value, 10, 64)
+	case "pattern":
+		// Another pattern replaces the file given for all devices
+		job.Pattern = value
+		if value != patternFile {
+			job.PatternFile = ""
+		}
+	case "pattern-file":
+		job.Pattern, job.PatternFile = patternFile, value
+	case "verify":
+		job.Verify, err = strconv.ParseBool(value)
+	case "verify-inline":
+		job.VerifyInline, err = strconv.ParseBool(value)
+	case "verify-sample":
+		job.VerifySample, err = strconv.Atoi(value)
+	case "entropy-check":
+		job.EntropyCheck, err = strconv.ParseBool(value)
+	case "passes":
+		job.Passes, err = strconv.Atoi(value)
+	case "scheme":
+		job.Scheme = value
+	case "secure-random-zero":
+		job.SecureRandomZero, err = strconv.ParseBool(value)
+	case "rng":
+		job.RNG = value
+	case "random-refresh":
+		job.RandomRefresh, err = strconv.Atoi(value)
+	case "discard":
+		job.Discard, err = strconv.ParseBool(value)
+	case "discard-first":
+		job.DiscardFirst, err = strconv.ParseBool(value)
+	case "discard-verify":
+		job.DiscardVerify, err = strconv.ParseBool(value)
+	case "auto-skip":
+		job.AutoSkip, err = strconv.ParseBool(value)
+		if job.AutoSkip {
+			job.SkipFactor, job.Coverage = 1, 0
+		}
+	case "fill-gaps":
+		job.FillGaps, err = strconv.ParseBool(value)
+	case "target-hours":
+		job.TargetHours, err = strconv.ParseFloat(value, 64)
+	case "remove-hpa":
+		job.RemoveHPA, err = strconv.ParseBool(value)
+	case "preserve-partition-table":
+		job.PreservePartitionTable, err = strconv.ParseBool(value)
+	case "preserve-table":
+		job.PreserveTable, err = strconv.ParseBool(value)
+	case "smart":
+		job.SMART, err = strconv.ParseBool(value)
+	case "certificate":
+		job.Certificate = value
+	case "summary":
+		job.Summary = value
+	case "stamp":
+		job.Stamp, err = strconv.ParseBool(value)
+	case "digest":
+		job.Digest = value
+	case "mlock":
+		job.Mlock, err = strconv.ParseBool(value)
+	case "pipeline-depth":
+		job.PipelineDepth, err = strconv.Atoi(value)
+	case "expect-size":
+		job.ExpectSize, err = parseSize(value)
+	case "expect-serial":
+		job.ExpectSerial = value
+	case "reopen-wait":
+		job.ReopenWait, err = time.ParseDuration(value)
+	case "benchmark-size":
+		job.BenchmarkSize, err = parseSize(value)
+	case "benchmark-time":
+		job.BenchmarkTime, err = time.ParseDuration(value)
+	case "benchmark-regions":
+		job.BenchmarkRegions = value
+	case "benchmark-cache-age":
+		job.BenchmarkCacheAge, err = time.ParseDuration(value)
+	case "rebenchmark":
+		job.Rebenchmark, err = strconv.ParseBool(value)
+	case "write-zeroes":
+		job.WriteZeroes, err = strconv.ParseBool(value)
+	case "max-duration":
+		job.MaxDuration, err = time.ParseDuration(value)
+	case "nvme-sanitize":
+		job.NVMeSanitize, err = strconv.ParseBool(value)
+	case "ata-secure-erase":
+		job.ATASecureErase, err = strconv.ParseBool(value)
+	case "sync-mode":
+		job.SyncMode = value
+	case "sync-every":
+		job.SyncEvery, err = strconv.Atoi(value)
+	case "no-excl":
+		job.NoExcl, err = strconv.ParseBool(value)
+	case "no-direct":
+		job.NoDirect, err = strconv.ParseBool(value)
+	case "rate":
+		job.Rate, err = parseRate(value)
+	case "retries":
+		job.Retries, err = strconv.Atoi(value)
+	case "check-wiped":
+		job.CheckWiped = value
+	case "checkpoint":
+		job.Checkpoint = value
+	default:
+		return fmt.Errorf("unknown override %q", key)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s", value, key)
+	}
+	return nil
+}
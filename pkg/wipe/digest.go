@@ -0,0 +1,54 @@
+package wipe
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+)
+
+// Block checksums selectable with -digest.
+const (
+	digestSHA256 = "sha256"
+	digestCRC32C = "crc32c" // hardware-accelerated on most CPUs
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// blockDigest is the checksum of a block of random data as it was written.
+// CRC32C sums use the first four bytes of Sum.
+type blockDigest struct {
+	Offset int64
+	Length int
+	Sum    [sha256.Size]byte
+}
+
+// validDigest reports whether name is a known checksum.
+func validDigest(name string) bool {
+	return name == digestSHA256 || name == digestCRC32C
+}
+
+// blockSum checksums one block with the named algorithm.
+func blockSum(algorithm string, data []byte) [sha256.Size]byte {
+	if algorithm == digestCRC32C {
+		var sum [sha256.Size]byte
+		binary.BigEndian.PutUint32(sum[:], crc32.Checksum(data, castagnoli))
+		return sum
+	}
+	return sha256.Sum256(data)
+}
+
+// digestOf condenses the block checksums of a wipe into a single hex digest
+// for the wipe record, using the same algorithm over the concatenated sums.
+func digestOf(algorithm string, digests []blockDigest) string {
+	var h hash.Hash = sha256.New()
+	size := sha256.Size
+	if algorithm == digestCRC32C {
+		h, size = crc32.New(castagnoli), crc32.Size
+	}
+	for _, d := range digests {
+		h.Write(d.Sum[:size])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,217 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// blkDiscard is the BLKDISCARD ioctl, _IO(0x12, 119). Its argument is a
+	// pointer to a {start, length} pair of byte offsets.
+	blkDiscard = 0x1277
+
+	// discardChunkSize is how much is discarded per ioctl, so that progress
+	// can be reported and cancellation honored on large devices.
+	discardChunkSize = 1024 * 1024 * 1024
+
+	// discardSampleCount and discardSampleSize control the read-back check
+	// after a discard.
+	discardSampleCount = 64
+	discardSampleSize  = 4096
+)
+
+// discardSupported reports whether device can be discarded: regular files
+// always can (by punching holes), block devices if the kernel reports a
+// non-zero discard_max_bytes for their disk.
+func discardSupported(device string) bool {
+	if fi, err := os.Stat(device); err == nil && fi.Mode().IsRegular() {
+		return true
+	}
+	queue := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)), "queue")
+	maxBytes := readSysfsString(filepath.Join(queue, "discard_max_bytes"))
+	return maxBytes != "" && maxBytes != "0"
+}
+
+// discardRange discards length bytes at offset. Block devices get
+// BLKDISCARD; regular files have the range punched out, which reads back as
+// zeros just like a deterministic TRIM.
+func discardRange(file *os.File, offset, length int64) error {
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Mode().IsRegular() {
+		return punchHole(file, offset, length)
+	}
+
+	r := [2]uint64{uint64(offset), uint64(length)}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkDiscard, uintptr(unsafe.Pointer(&r)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// discardDevice discards the whole device in chunks, reporting progress
+// after every chunk.
+func discardDevice(ctx context.Context, path string, size int64, report ProgressFunc) (Result, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return Result{}, newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	startTime := time.Now()
+	lastTime := startTime
+	var offset int64
+	for offset < size {
+		if ctx.Err() != nil {
+			return Result{}, &InterruptedError{Offset: offset, Err: context.Cause(ctx)}
+		}
+
+		length := min(int64(discardChunkSize), size-offset)
+		if err := discardRange(file, offset, length); err != nil {
+			return Result{}, newDeviceError("discard", path, err)
+		}
+		offset += length
+
+		now := time.Now()
+		speed := float64(length) / now.Sub(lastTime).Seconds()
+		lastTime = now
+		report(Progress{
+			Device:         path,
+			BytesProcessed: offset,
+			Total:          size,
+			Speed:          speed,
+			ETA:            time.Duration(float64(size-offset)/speed) * time.Second,
+			Coverage:       coverage{Num: 1, Den: 1},
+		})
+	}
+
+	return Result{
+		Device:         path,
+		Size:           size,
+		BytesProcessed: size,
+		Coverage:       coverage{Num: 1, Den: 1},
+		Duration:       time.Since(startTime),
+		Method:         methodDiscard,
+	}, nil
+}
+
+// sampleReadsZero reads back samples from the start, the end and random
+// positions of the device and reports whether they are all zero. If not, it
+// also returns the offset of the first non-zero sample.
+func sampleReadsZero(path string, size int64) (bool, int64, error) {
+	file, err := openDirect(path, os.O_RDONLY)
+	if err != nil {
+		// Fall back to buffered reads; discard invalidates the page cache
+		file, err = os.Open(path)
+		if err != nil {
+			return false, 0, newDeviceError("open", path, err)
+		}
+	}
+	defer file.Close()
+
+	buffer, err := AllocAlignedBuffer(discardSampleSize)
+	if err != nil {
+		return false, 0, err
+	}
+	defer FreeAlignedBuffer(buffer)
+
+	offsets, err := sampleOffsets(size, discardSampleCount, discardSampleSize)
+	if err != nil {
+		return false, 0, err
+	}
+
+	for _, offset := range offsets {
+		if _, err := file.ReadAt(buffer, offset); err != nil {
+			return false, 0, newDeviceError("read", path, err)
+		}
+		for _, b := range buffer {
+			if b != 0 {
+				return false, offset, nil
+			}
+		}
+	}
+	return true, 0, nil
+}
+
+// sampleOffsets picks count block-aligned offsets of sampleSize-byte
+// samples: the first and last block of the device and random ones between.
+func sampleOffsets(size int64, count int, sampleSize int64) ([]int64, error) {
+	blocks := size / sampleSize
+	if blocks == 0 {
+		return nil, fmt.Errorf("device too small to sample")
+	}
+	offsets := []int64{0, (blocks - 1) * sampleSize}
+	for range count - len(offsets) {
+		offsets = append(offsets, rand.Int64N(blocks)*sampleSize)
+	}
+	return offsets, nil
+}
+
+// discardThenVerify discards the whole device and checks by sampling that
+// it now reads back as zeros. If the device does not support discard or
+// does not return zeros afterwards, it falls back to a full overwrite.
+func discardThenVerify(ctx context.Context, job wipeJob, run runInfo, report ProgressFunc) (Result, error) {
+	result, err := discardDevice(ctx, job.Device, job.size, report)
+	if err != nil {
+		var interrupted *InterruptedError
+		if errors.As(err, &interrupted) {
+			return result, err
+		}
+		fmt.Printf("\nDiscard failed (%v), falling back to overwrite\n", err)
+		return wipeDevice(ctx, job, nil, run, report)
+	}
+
+	zero, offset, err := sampleReadsZero(job.Device, job.size)
+	switch {
+	case err != nil:
+		fmt.Printf("\nCannot verify discard (%v), falling back to overwrite\n", err)
+	case !zero:
+		fmt.Printf("\nDevice does not read back zeros after discard (non-zero data at offset %d), falling back to overwrite\n", offset)
+	default:
+		fmt.Printf("\nDiscard verified: %d sampled blocks read back as zeros\n", discardSampleCount)
+		result.Verified = true
+		return result, nil
+	}
+	return wipeDevice(ctx, job, nil, run, report)
+}
+
+// discardOnly discards the whole device instead of overwriting it. Devices
+// that don't support discard are overwritten instead.
+func discardOnly(ctx context.Context, job wipeJob, run runInfo, report ProgressFunc) (Result, error) {
+	if !discardSupported(job.Device) {
+		fmt.Printf("%s does not support discard, overwriting instead\n", job.Device)
+		return wipePasses(ctx, job, nil, run, report)
+	}
+	return discardDevice(ctx, job.Device, job.size, report)
+}
+
+// discardBeforeOverwrite discards the whole device ahead of the overwrite,
+// so that an SSD also drops blocks it has remapped out of reach. Devices
+// that don't support discard, and discard errors, only skip this step.
+func discardBeforeOverwrite(ctx context.Context, job wipeJob, report ProgressFunc) error {
+	if !discardSupported(job.Device) {
+		fmt.Printf("%s does not support discard, skipping it\n", job.Device)
+		return nil
+	}
+	fmt.Printf("Discarding %s before overwriting it\n", job.Device)
+	_, err := discardDevice(ctx, job.Device, job.size, report)
+	var interrupted *InterruptedError
+	if errors.As(err, &interrupted) {
+		return err
+	}
+	if err != nil {
+		fmt.Println()
+		warnf("Discard failed, continuing with the overwrite: %v", err)
+	}
+	return nil
+}
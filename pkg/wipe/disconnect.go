@@ -0,0 +1,99 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+)
+
+// reopenPollInterval is how often reopenDevice checks whether a vanished
+// device has come back.
+const reopenPollInterval = 500 * time.Millisecond
+
+// reopenDevice waits up to opts.ReopenWait for path to reappear after the
+// device was disconnected and opens it for writing again, as openForWipe
+// does with opts. The device must still have the same size and serial
+// number; anything else at the same path is a different disk that happened
+// to get the same name.
+func reopenDevice(ctx context.Context, path string, size int64, serial string, opts Options) (*os.File, error) {
+	wait := opts.ReopenWait
+	deadline := time.Now().Add(wait)
+	for {
+		if _, err := os.Stat(path); err == nil {
+			actual, err := DeviceSize(path)
+			if err == nil && actual != size {
+				return nil, fmt.Errorf("%s came back with size %s instead of %s", path, FormatBytes(actual), FormatBytes(size))
+			}
+			if err == nil && deviceSerial(path) != serial {
+				return nil, fmt.Errorf("%s came back with a different serial number", path)
+			}
+			if err == nil {
+				return openForWipe(path, opts)
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("did not reappear within %s", wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-time.After(reopenPollInterval):
+		}
+	}
+}
+
+// checkStillThere looks at the device behind a failed write. A hot-plugged
+// drive that was pulled often fails with a plain EIO before its node goes;
+// if the node is gone, can't be opened any more or has another size than
+// size, err is wrapped with ErrDeviceGone or ErrDeviceResized.
+func checkStillThere(path string, size int64, err error) error {
+	if errors.Is(err, ErrDeviceGone) || errors.Is(err, ErrUnaligned) {
+		return err
+	}
+	actual, sizeErr := DeviceSize(path)
+	switch {
+	case errors.Is(sizeErr, ErrNotFound), errors.Is(sizeErr, ErrDeviceGone), errors.Is(sizeErr, syscall.EIO),
+		sizeErr == nil && actual == 0:
+		return fmt.Errorf("%w: %w", ErrDeviceGone, err)
+	case sizeErr == nil && actual != size:
+		return fmt.Errorf("%w from %s to %s: %w", ErrDeviceResized, FormatBytes(size), FormatBytes(actual), err)
+	}
+	return err
+}
+
+// writeBlock writes buf at offset, and if the device disappears underneath
+// it waits up to reopenWait for the device to come back and writes the block
+// again. *file is replaced by the re-opened device.
+func writeBlock(ctx context.Context, file **os.File, job wipeJob, serial string, buf []byte, offset int64) (int, error) {
+	offset = job.physical(offset)
+	n, err := writeAligned(*file, job.Device, buf, offset, job.blockSize)
+	if err == nil {
+		return n, nil
+	}
+	size := job.size
+	if job.window != nil {
+		size = job.diskSize
+	}
+	err = checkStillThere(job.Device, size, newWriteError(offset, err))
+	if !errors.Is(err, ErrDeviceGone) || job.ReopenWait <= 0 {
+		return n, err
+	}
+
+	fmt.Println()
+	warnf("%s disappeared at offset %d, waiting up to %s for it to come back", job.Device, offset, job.ReopenWait)
+	(*file).Close()
+	reopened, reopenErr := reopenDevice(ctx, job.Device, size, serial, job.Options)
+	if reopenErr != nil {
+		return 0, fmt.Errorf("%w; %w", err, reopenErr)
+	}
+	*file = reopened
+	fmt.Printf("%s is back, continuing at offset %d\n", job.Device, offset)
+	n, err = writeAligned(reopened, job.Device, buf, offset, job.blockSize)
+	if err != nil {
+		return n, newWriteError(offset, err)
+	}
+	return n, nil
+}
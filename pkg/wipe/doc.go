@@ -0,0 +1,9 @@
+// Package wipe is the engine behind the quickwipe command, for programs that
+// embed it instead of running the binary. Wipe overwrites a device with the
+// same Options the command's flags set, reporting Progress to a ProgressFunc
+// and stopping when its context is cancelled; DeviceSize,
+// BenchmarkWriteSpeed, FormatBytes and FormatDuration are the helpers the
+// command uses around it.
+//
+// Main runs the command itself.
+package wipe
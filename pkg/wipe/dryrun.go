@@ -0,0 +1,92 @@
+package wipe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// printPlan reports what wiping job would do. It is called by prepareJob
+// for -dry-run instead of asking for confirmation. writeSpeed is measured
+// by reading, since benchmarking writes would modify the device.
+func printPlan(job wipeJob, serial string, writeSpeed float64) {
+	cov := job.coverage()
+	patterns := job.passPatterns()
+	written := float64(job.wipedSize()) * float64(cov.Num) / float64(cov.Den)
+
+	fmt.Printf("Dry run for %s:\n", job.Device)
+	fmt.Printf("  Size:        %s (%d bytes)\n", FormatBytes(job.size), job.size)
+	if serial != "" {
+		fmt.Printf("  Serial:      %s\n", serial)
+	}
+	if job.extents != nil {
+		fmt.Printf("  Partitions:  %d (%s), keeping the partition table\n", len(job.extents), FormatBytes(job.wipedSize()))
+	}
+	fmt.Printf("  Buffer:      %s\n", FormatBytes(int64(job.BufferSize)))
+	if cov.full() {
+		fmt.Printf("  Coverage:    whole device\n")
+	} else if job.Coverage > 0 {
+		fmt.Printf("  Coverage:    %s (-coverage %.4g%%)\n", cov.describe(job.SkipMode), job.Coverage*100)
+	} else {
+		fmt.Printf("  Coverage:    %s (skip factor %d)\n", cov.describe(job.SkipMode), job.SkipFactor)
+	}
+
+	if job.NVMeSanitize {
+		if isNVMeNamespace(job.Device) {
+			fmt.Printf("  Method:      NVMe Sanitize or Format NVM if the controller supports them, otherwise overwrite\n")
+		} else {
+			fmt.Printf("  Method:      overwrite (not an NVMe namespace, -nvme-sanitize doesn't apply)\n")
+		}
+	}
+	if job.ATASecureErase && !(job.NVMeSanitize && isNVMeNamespace(job.Device)) {
+		if isATADisk(job.Device) {
+			fmt.Printf("  Method:      ATA Secure Erase if the drive supports it and isn't frozen, otherwise overwrite\n")
+		} else {
+			fmt.Printf("  Method:      overwrite (not a whole ATA disk, -ata-secure-erase doesn't apply)\n")
+		}
+	}
+	if job.Discard {
+		fmt.Printf("  Method:      discard instead of overwrite\n")
+		fmt.Printf("  Verify:      %t\n", job.DiscardVerify)
+		fmt.Printf("  Estimated:   depends on the device's discard speed\n")
+		return
+	}
+
+	description := fmt.Sprintf("%d (%s)", len(patterns), strings.Join(patterns, ", "))
+	if job.Scheme != "" {
+		description += fmt.Sprintf(", %s scheme", schemeNames[job.Scheme])
+	}
+	fmt.Printf("  Passes:      %s\n", description)
+	if job.Pattern == patternRandom || job.SecureRandomZero || job.Scheme != "" {
+		fmt.Printf("  Random data: %s\n", rngDescription(job.RNG))
+	}
+	if job.DiscardFirst {
+		fmt.Printf("  Discard:     before overwriting\n")
+	}
+	if max := writeZeroesMax(job.Device); max > 0 {
+		use := "use with -write-zeroes"
+		if job.WriteZeroes {
+			use = "used for full zero passes"
+		}
+		fmt.Printf("  Zeroing:     offloaded, up to %s per command (%s)\n", FormatBytes(max), use)
+	} else {
+		fmt.Printf("  Zeroing:     no offload, zeros are written\n")
+	}
+	if job.VerifySample > 0 {
+		fmt.Printf("  Verify:      %d sampled blocks\n", job.VerifySample)
+	} else if job.VerifyInline {
+		fmt.Printf("  Verify:      inline, every block is read back as it is written\n")
+	} else {
+		fmt.Printf("  Verify:      %t\n", job.Verify)
+	}
+	fmt.Printf("  Written:     %s per pass\n", FormatBytes(int64(written)))
+	if job.fillsGaps() {
+		fmt.Printf("  Fill gaps:   the remaining %s, within %g hours in total\n", FormatBytes(job.wipedSize()-int64(written)), job.TargetHours)
+	}
+
+	basis := "write speed assumed equal to the read speed"
+	if job.Rate > 0 && writeSpeed == float64(job.Rate) {
+		basis = "write speed limited by -rate"
+	}
+	fmt.Printf("  Estimated:   %s (%s of %.2f MB/s)\n",
+		FormatDuration(job.estimate.total()), basis, writeSpeed/1024/1024)
+}
@@ -0,0 +1,80 @@
+package wipe
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// entropySampleBytes is how much of each read-back block -entropy-check
+	// measures, which keeps the check cheap for large buffers.
+	entropySampleBytes = 64 << 10
+
+	// minEntropy is the Shannon entropy, in bits per byte, below which a
+	// block of random data is suspicious. Random data scores close to 8
+	// even in a 4 KB block; zeros score 0 and text around 4-5.
+	minEntropy = 7.5
+)
+
+// shannonEntropy estimates the entropy of data in bits per byte from its
+// byte frequencies.
+func shannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(data))
+			entropy -= p * math.Log2(p)
+		}
+	}
+	return entropy
+}
+
+// entropyCheck collects the entropy of read-back blocks of random data for
+// -entropy-check. A block that reads back as zeros matches its digest just
+// as well if the random source produced zeros, or if zeros are what the
+// device returns for every read; only its low entropy gives it away.
+type entropyCheck struct {
+	checked  int
+	low      int     // blocks below minEntropy
+	lowest   float64 // lowest entropy seen, in bits per byte
+	lowestAt int64   // offset of the block with the lowest entropy
+}
+
+func newEntropyCheck() *entropyCheck {
+	return &entropyCheck{lowest: 8}
+}
+
+// add measures the block read back at offset.
+func (c *entropyCheck) add(offset int64, block []byte) {
+	entropy := shannonEntropy(block[:min(len(block), entropySampleBytes)])
+	c.checked++
+	if entropy < minEntropy {
+		c.low++
+	}
+	if entropy < c.lowest {
+		c.lowest, c.lowestAt = entropy, offset
+	}
+}
+
+// report prints the outcome of the check on path, warning if any block
+// looked too regular to be random data.
+func (c *entropyCheck) report(path string) {
+	if c.checked == 0 {
+		return
+	}
+	fmt.Println()
+	if c.low == 0 {
+		fmt.Printf("Entropy check of %s: %d blocks, lowest %.3f bits/byte\n", path, c.checked, c.lowest)
+		return
+	}
+	dangerf("WARNING: %d of %d blocks of %s read back with low entropy (lowest %.3f bits/byte at offset %d); "+
+		"the random data may not have reached the device, or the random source failed",
+		c.low, c.checked, path, c.lowest, c.lowestAt)
+}
@@ -0,0 +1,116 @@
+package wipe
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is prepended to the upper-cased flag name to form the
+// environment variable that supplies its default, e.g. -target-hours is
+// read from QUICKWIPE_TARGET_HOURS.
+const envPrefix = "QUICKWIPE_"
+
+// envName returns the environment variable consulted for the named flag.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnvDefaults sets each flag in fs that the command line left unset
+// from its environment variable, if present. It runs after fs.Parse, so that
+// a flag given on the command line replaces the environment's value rather
+// than adding to it, as it would for a repeatable flag such as -device.
+func applyEnvDefaults(fs *flag.FlagSet) error {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var firstErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || given[f.Name] || firstErr != nil {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			firstErr = fmt.Errorf("invalid value %q for %s: %v", value, envName(f.Name), err)
+		}
+	})
+	return firstErr
+}
+
+// flagSource is where a flag got its value from, in increasing order of
+// precedence.
+type flagSource int
+
+const (
+	sourceDefault flagSource = iota
+	sourceConfig
+	sourceEnv
+	sourceCommandLine
+)
+
+// flagSources records where each flag that was set got its value from.
+type flagSources map[string]flagSource
+
+// loadFlags fills in the flags of fs that the command line left unset, from
+// the environment and then from the -config file, and returns where each
+// flag that was set got its value from. Everything derived from the flags,
+// down to the output settings, has to wait for it.
+func loadFlags(fs *flag.FlagSet) (flagSources, error) {
+	sources := flagSources{}
+	sources.record(fs, sourceCommandLine)
+
+	// Environment variables provide defaults; command-line flags override them
+	if err := applyEnvDefaults(fs); err != nil {
+		return nil, err
+	}
+	sources.record(fs, sourceEnv)
+
+	if f := fs.Lookup("config"); f != nil && f.Value.String() != "" {
+		if err := applyConfigFile(f.Value.String(), fs); err != nil {
+			return nil, fmt.Errorf("cannot read config: %v", err)
+		}
+	}
+	sources.record(fs, sourceConfig)
+	return sources, nil
+}
+
+// record marks the flags of fs set since the last call as coming from
+// source.
+func (s flagSources) record(fs *flag.FlagSet, source flagSource) {
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := s[f.Name]; !ok {
+			s[f.Name] = source
+		}
+	})
+}
+
+// settleExclusive makes sure at most one of the mutually exclusive flags
+// names holds a value. Flags from a source of lower precedence than another
+// of them, e.g. an environment variable next to a command-line flag, are
+// reset to their defaults. Two from the command line or the config file are
+// an error; two from the environment are left for validation to reject.
+func settleExclusive(fs *flag.FlagSet, sources flagSources, names ...string) error {
+	top := sourceDefault
+	for _, name := range names {
+		top = max(top, sources[name])
+	}
+	var kept []string
+	for _, name := range names {
+		switch source := sources[name]; {
+		case source == sourceDefault:
+		case source < top:
+			f := fs.Lookup(name)
+			if err := f.Value.Set(f.DefValue); err != nil {
+				return err
+			}
+			sources[name] = sourceDefault
+		default:
+			kept = append(kept, name)
+		}
+	}
+	if len(kept) > 1 && top != sourceEnv {
+		return fmt.Errorf("-%s cannot be combined with -%s; use one of -%s", kept[0], kept[1], strings.Join(names, ", -"))
+	}
+	return nil
+}
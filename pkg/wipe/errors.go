@@ -0,0 +1,182 @@
+package wipe
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// Sentinel errors returned by the wipe functions. They are wrapped inside
+// DeviceError and WriteError, so match them with errors.Is.
+var (
+	// ErrDeviceBusy means the kernel refused access because the device is
+	// in use (mounted, claimed by md/LVM, opened exclusively elsewhere).
+	ErrDeviceBusy = errors.New("device busy")
+
+	// ErrUnaligned means a direct I/O write was rejected because the buffer
+	// address, length or offset does not meet the device's alignment rules.
+	ErrUnaligned = errors.New("unaligned direct I/O")
+
+	// ErrUnsupported means the device or driver does not implement the
+	// requested operation.
+	ErrUnsupported = errors.New("operation not supported")
+
+	// ErrNotBlockDevice means the target is neither a block device nor a
+	// regular file (for example a directory, character device or FIFO).
+	ErrNotBlockDevice = errors.New("not a block device or regular file")
+
+	// ErrDeviceGone means the device went away, typically because a USB
+	// drive was unplugged or its enclosure reset.
+	ErrDeviceGone = errors.New("device disappeared")
+
+	// ErrDeviceResized means the device no longer has the size it had when
+	// the wipe started, as when a card reader gets another card.
+	ErrDeviceResized = errors.New("device changed size")
+
+	// ErrNoSpace means the filesystem holding a disk image ran full, which
+	// happens when a sparse image has its holes filled in.
+	ErrNoSpace = errors.New("no space left on filesystem")
+
+	// ErrNoMedia means the device reports a size of zero, as an empty loop
+	// device or a card reader without a card does.
+	ErrNoMedia = errors.New("device reports zero size, is media present?")
+
+	// ErrPermission means the kernel refused access to the device, usually
+	// because quickwipe isn't running as root.
+	ErrPermission = errors.New("permission denied, try running as root (sudo)")
+
+	// ErrNotFound means the device path doesn't exist.
+	ErrNotFound = errors.New("no such device, check the path")
+)
+
+// DeviceError records a failure to open, inspect or position a device.
+type DeviceError struct {
+	Op   string // operation that failed, e.g. "open" or "seek"
+	Path string
+	Err  error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+}
+
+func (e *DeviceError) Unwrap() error { return e.Err }
+
+// WriteError records a failed write together with the device offset it was
+// issued at.
+type WriteError struct {
+	Offset int64
+	Err    error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("write at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *WriteError) Unwrap() error { return e.Err }
+
+// newDeviceError builds a DeviceError, stripping the redundant *os.PathError
+// layer and attaching the matching sentinel error.
+func newDeviceError(op, path string, err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	return &DeviceError{Op: op, Path: path, Err: classifyErrno(err)}
+}
+
+// newWriteError builds a WriteError for a write issued at offset. EINVAL from
+// a write almost always means the request violated O_DIRECT alignment.
+func newWriteError(offset int64, err error) error {
+	var pe *os.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	if errors.Is(err, syscall.EINVAL) {
+		err = fmt.Errorf("%w: %w", ErrUnaligned, err)
+	} else {
+		err = classifyErrno(err)
+	}
+	return &WriteError{Offset: offset, Err: err}
+}
+
+// classifyErrno wraps err with the sentinel matching its errno, if any.
+func classifyErrno(err error) error {
+	var sentinel error
+	switch {
+	case errors.Is(err, syscall.EBUSY):
+		sentinel = ErrDeviceBusy
+	case errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOTTY):
+		sentinel = ErrUnsupported
+	case errors.Is(err, syscall.ENODEV), errors.Is(err, syscall.ENXIO):
+		sentinel = ErrDeviceGone
+	case errors.Is(err, syscall.ENOSPC):
+		sentinel = ErrNoSpace
+	case errors.Is(err, syscall.EACCES), errors.Is(err, syscall.EPERM):
+		sentinel = ErrPermission
+	case errors.Is(err, syscall.ENOENT):
+		sentinel = ErrNotFound
+	default:
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// InterruptedError is returned when a wipe stops early because its context
+// was cancelled. Offset is where the wipe stopped; Checkpoint names the
+// resume file that was written, if any.
+type InterruptedError struct {
+	Offset     int64
+	Checkpoint string
+	Err        error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("wipe interrupted at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *InterruptedError) Unwrap() error { return e.Err }
+
+// DisconnectError is returned when the device disappears, or changes size,
+// in the middle of a wipe. Offset is how far the wipe had got; Err is the
+// write error, or why the device could not be re-opened. Checkpoint names
+// the resume file that was written, if any.
+type DisconnectError struct {
+	Path       string
+	Offset     int64
+	Size       int64
+	Checkpoint string
+	Err        error
+}
+
+func (e *DisconnectError) Error() string {
+	what := "disappeared"
+	if errors.Is(e.Err, ErrDeviceResized) {
+		what = "was resized"
+	}
+	return fmt.Sprintf("%s %s, wipe incomplete at %.1f%% (%s of %s): %v", e.Path, what,
+		float64(e.Offset)/float64(e.Size)*100, FormatBytes(e.Offset), FormatBytes(e.Size), e.Err)
+}
+
+func (e *DisconnectError) Unwrap() error { return e.Err }
+
+// VerifyError is returned when data read back after a wipe differs from what
+// was written. Offset is the first differing byte; Blocks holds the offset of
+// every block that differs.
+type VerifyError struct {
+	Offset int64
+	Detail string // optional explanation of what was found instead
+	Blocks []int64
+}
+
+func (e *VerifyError) Error() string {
+	msg := fmt.Sprintf("verification failed: data mismatch at offset %d", e.Offset)
+	if len(e.Blocks) > 1 {
+		msg = fmt.Sprintf("verification failed: %d blocks differ, the first at offset %d", len(e.Blocks), e.Offset)
+	}
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
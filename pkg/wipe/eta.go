@@ -0,0 +1,181 @@
+package wipe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// estimateProbeSize is how much of a device is read to estimate its speed
+// when no benchmark has measured it.
+const estimateProbeSize = 64 * 1024 * 1024
+
+// jobEstimate is how long the phases of a job are expected to take, found
+// by prepareJob from the benchmarked or probed speed.
+type jobEstimate struct {
+	passes int           // overwrite passes
+	pass   time.Duration // each overwrite pass
+	gaps   time.Duration // filling the gaps for -fill-gaps
+	verify time.Duration // reading everything written back
+}
+
+// total returns how long the whole job is expected to take.
+func (e jobEstimate) total() time.Duration {
+	return time.Duration(e.passes)*e.pass + e.gaps + e.verify
+}
+
+// estimateJob works out how long job takes at writeSpeed, reading back at
+// verifySpeed, which is 0 if nothing is read back in full. It returns nil
+// if there is no speed to go by.
+func estimateJob(job wipeJob, writeSpeed, verifySpeed float64) *jobEstimate {
+	if writeSpeed <= 0 {
+		return nil
+	}
+	// -verify-inline reads every block back as soon as it is written
+	perByte := 1 / writeSpeed
+	if verifySpeed > 0 && job.VerifyInline {
+		perByte += 1 / verifySpeed
+	}
+	seconds := func(bytes, perByte float64) time.Duration {
+		return time.Duration(bytes * perByte * float64(time.Second))
+	}
+	cov := job.coverage()
+	written := float64(job.wipedSize()) * float64(cov.Num) / float64(cov.Den)
+	e := &jobEstimate{passes: len(job.passPatterns()), pass: seconds(written, perByte)}
+	if job.fillsGaps() {
+		e.gaps = seconds(float64(job.wipedSize())-written, perByte)
+		if job.TargetHours > 0 {
+			limit := time.Duration(job.TargetHours * float64(time.Hour))
+			e.gaps = max(min(e.gaps, limit-time.Duration(e.passes)*e.pass), 0)
+		}
+		written = float64(job.wipedSize())
+	}
+	if verifySpeed > 0 && !job.VerifyInline {
+		e.verify = seconds(written, 1/verifySpeed)
+	}
+	return e
+}
+
+// runEstimate keeps the time left for all devices and passes of a run up to
+// date from their progress updates. Devices wiped one after another add up;
+// devices wiped in parallel finish with the slowest.
+type runEstimate struct {
+	mu        sync.Mutex
+	parallel  bool
+	estimates []*jobEstimate
+	remaining []time.Duration
+	wrote     []bool // a write pass has reported, so a pass-less update is the verify
+	single    bool   // one device with a single phase, whose own ETA says it all
+}
+
+// newRunEstimate prepares the estimate for jobs, or returns nil if any of
+// them has none. A job resumed from a checkpoint only counts what is left.
+func newRunEstimate(jobs []wipeJob, parallel bool) *runEstimate {
+	r := &runEstimate{
+		parallel:  parallel,
+		estimates: make([]*jobEstimate, len(jobs)),
+		remaining: make([]time.Duration, len(jobs)),
+		wrote:     make([]bool, len(jobs)),
+	}
+	if len(jobs) == 1 && jobs[0].estimate != nil {
+		e := jobs[0].estimate
+		r.single = e.passes == 1 && e.gaps == 0 && e.verify == 0
+	}
+	for i, job := range jobs {
+		e := job.estimate
+		if e == nil {
+			return nil
+		}
+		r.estimates[i] = e
+		r.remaining[i] = e.total()
+		if cp := job.resumeFrom; cp != nil && job.size > 0 {
+			done := float64(cp.Offset) / float64(job.size)
+			if cp.FillingGaps {
+				r.remaining[i] = time.Duration(float64(e.gaps)*(1-done)) + e.verify
+			} else {
+				r.remaining[i] = time.Duration(float64(e.pass)*(1-done)) +
+					time.Duration(e.passes-cp.Pass-1)*e.pass + e.gaps + e.verify
+			}
+		}
+	}
+	return r
+}
+
+// left returns the time left for the whole run. Callers must hold r.mu.
+func (r *runEstimate) left() time.Duration {
+	var total time.Duration
+	for _, d := range r.remaining {
+		if r.parallel {
+			total = max(total, d)
+		} else {
+			total += d
+		}
+	}
+	return total
+}
+
+// timeLeft returns the time left for the whole run.
+func (r *runEstimate) timeLeft() time.Duration {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.left()
+}
+
+// progress returns a ProgressFunc for job i that adds the time left for the
+// run to its updates before handing them to report. Without an estimate,
+// report is returned as it is.
+func (r *runEstimate) progress(i int, report ProgressFunc) ProgressFunc {
+	if r == nil || r.single || report == nil {
+		return report
+	}
+	return func(u Progress) {
+		r.mu.Lock()
+		e := r.estimates[i]
+		switch {
+		case u.Stage == 2:
+			r.remaining[i] = u.ETA + e.verify
+		case u.Pass > 0:
+			r.wrote[i] = true
+			r.remaining[i] = u.ETA + time.Duration(u.Passes-u.Pass)*e.pass + e.gaps + e.verify
+		case r.wrote[i]:
+			r.remaining[i] = u.ETA
+		default:
+			// Sanitize, secure erase and discard don't report passes
+			r.remaining[i] = u.ETA + e.verify
+		}
+		u.TotalETA = r.left()
+		r.mu.Unlock()
+		report(u)
+	}
+}
+
+// finish records that job i is done.
+func (r *runEstimate) finish(i int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.remaining[i] = 0
+}
+
+// printRunEstimate prints when the wipe of jobs should be finished if it
+// started now, unless the time of a job is unknown.
+func printRunEstimate(jobs []wipeJob, parallel bool) {
+	left := newRunEstimate(jobs, parallel).timeLeft()
+	if left <= 0 {
+		return
+	}
+	devices := "1 device"
+	if len(jobs) > 1 {
+		devices = fmt.Sprintf("%d devices one after another", len(jobs))
+		if parallel {
+			devices = fmt.Sprintf("%d devices in parallel", len(jobs))
+		}
+	}
+	fmt.Printf("Estimated finish: %s (%s for %s)\n",
+		time.Now().Add(left).UTC().Format(time.RFC3339), FormatDuration(left), devices)
+}
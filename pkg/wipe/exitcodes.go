@@ -0,0 +1,83 @@
+package wipe
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"syscall"
+)
+
+// Process exit codes. Scripts can rely on these to tell apart why a run
+// stopped, so a code is never renumbered: a new reason gets the next free
+// one. Keep the README table in sync when adding new ones.
+const (
+	exitOK           = 0 // wipe completed (or nothing to do)
+	exitUsage        = 2 // invalid or missing command-line arguments
+	exitAborted      = 3 // the user declined a confirmation prompt, a safety check refused under -assume-yes, or -expect-size or -expect-serial didn't match
+	exitDeviceError  = 4 // device could not be opened, sized, benchmarked or written
+	exitVerifyFailed = 5 // read-back verification found mismatching data
+	exitTimeLimit    = 6 // -max-duration stopped the wipe; a checkpoint was written
+	exitNoAccess     = 7 // the device doesn't exist, or may not be opened without root
+	exitDeviceGone   = 8 // the device disappeared or changed size mid-wipe; a checkpoint was written
+
+	// exitSignalBase is added to the signal number when a wipe is stopped by
+	// a signal, following the shell convention (SIGTERM exits with 143).
+	exitSignalBase = 128
+)
+
+// exitCodeFor maps an error returned from preparing or running a job to the
+// process exit code.
+func exitCodeFor(err error) int {
+	var sigErr *SignalError
+	var verifyErr *VerifyError
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &sigErr):
+		if sig, ok := sigErr.Signal.(syscall.Signal); ok {
+			return exitSignalBase + int(sig)
+		}
+		return exitDeviceError
+	case errors.Is(err, errAborted), errors.Is(err, errSizeMismatch), errors.Is(err, errSerialMismatch), errors.Is(err, errNotDevPath):
+		return exitAborted
+	case errors.As(err, &verifyErr):
+		return exitVerifyFailed
+	case errors.Is(err, errMaxDuration):
+		return exitTimeLimit
+	case errors.Is(err, ErrDeviceGone), errors.Is(err, ErrDeviceResized):
+		return exitDeviceGone
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrPermission):
+		return exitNoAccess
+	default:
+		return exitDeviceError
+	}
+}
+
+// exitCodeHelp describes the exit codes for -help, in the words of the
+// README table.
+var exitCodeHelp = []struct {
+	code    string
+	meaning string
+}{
+	{"0", "wipe completed, or nothing to do"},
+	{"2", "invalid or missing command-line arguments"},
+	{"3", "aborted at a confirmation prompt, or refused by -expect-size, -expect-serial or a safety check under -assume-yes"},
+	{"4", "device error: open, size detection, benchmark or write failure"},
+	{"5", "verification found mismatching data"},
+	{"6", "stopped by -max-duration; a checkpoint was written"},
+	{"7", "the device doesn't exist, or permission was denied (try sudo)"},
+	{"8", "the device disappeared or changed size during the wipe; a checkpoint was written"},
+	{"128+N", "stopped by signal N, e.g. 130 for Ctrl-C and 143 for SIGTERM; a checkpoint was written"},
+}
+
+// printUsage is the -help output: the flags, followed by the exit codes so
+// that scripts can be written without the README at hand.
+func printUsage(out io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintf(out, "Usage of %s:\n", flags.Name())
+	flags.PrintDefaults()
+	fmt.Fprintf(out, "\nExit codes:\n")
+	for _, c := range exitCodeHelp {
+		fmt.Fprintf(out, "  %-6s %s\n", c.code, c.meaning)
+	}
+}
@@ -0,0 +1,220 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/f0o/quickwipe/rpc"
+)
+
+// grpcPollInterval is how often StreamProgress samples a job.
+const grpcPollInterval = time.Second
+
+// grpcServer exposes the daemon's job queue over gRPC for remote
+// orchestration. It shares the queue, state file and workers with the Unix
+// socket API. A StartWipe request can only set a few wipe options, so, as
+// for remote HTTP clients, it can't name files on the daemon's host.
+type grpcServer struct {
+	rpc.UnimplementedWiperServer
+	d *daemon
+}
+
+// newGRPCServer serves d's jobs to clients that send token, as for the HTTP
+// API, in an "authorization: Bearer <token>" metadata entry.
+func newGRPCServer(d *daemon, token string) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkGRPCToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkGRPCToken(stream.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	rpc.RegisterWiperServer(server, &grpcServer{d: d})
+	return server
+}
+
+// checkGRPCToken refuses a call whose metadata doesn't carry token.
+func checkGRPCToken(ctx context.Context, token string) error {
+	var authorization string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			authorization = values[0]
+		}
+	}
+	if !validToken(authorization, token) {
+		return status.Error(codes.Unauthenticated, errUnauthorized.Error())
+	}
+	return nil
+}
+
+// StartWipe queues a wipe. Unset options take the daemon's defaults.
+func (s *grpcServer) StartWipe(ctx context.Context, req *rpc.StartWipeRequest) (*rpc.Job, error) {
+	spec := s.d.defaults
+	spec.Checkpoint = ""
+	spec.Device = req.GetDevice()
+	if req.BufferSize != nil {
+		spec.BufferSize = int(req.GetBufferSize())
+	}
+	if req.SkipFactor != nil {
+		spec.SkipFactor = int(req.GetSkipFactor())
+	}
+	if req.Coverage != nil {
+		spec.Coverage = req.GetCoverage()
+	}
+	if req.RandomRefresh != nil {
+		spec.RandomRefresh = int(req.GetRandomRefresh())
+	}
+	if req.DiscardVerify != nil {
+		spec.DiscardVerify = req.GetDiscardVerify()
+	}
+	if req.AutoSkip != nil {
+		spec.AutoSkip = req.GetAutoSkip()
+	}
+	if req.TargetHours != nil {
+		spec.TargetHours = req.GetTargetHours()
+	}
+
+	job, err := s.d.submit(spec)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobToProto(job), nil
+}
+
+func (s *grpcServer) GetJob(ctx context.Context, req *rpc.JobRequest) (*rpc.Job, error) {
+	job, err := s.d.snapshot(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobToProto(job), nil
+}
+
+// StreamProgress sends the job's progress every grpcPollInterval until the
+// job finishes or the client goes away.
+func (s *grpcServer) StreamProgress(req *rpc.JobRequest, stream grpc.ServerStreamingServer[rpc.Progress]) error {
+	ticker := time.NewTicker(grpcPollInterval)
+	defer ticker.Stop()
+
+	for {
+		job, err := s.d.snapshot(req.GetId())
+		if err != nil {
+			return grpcError(err)
+		}
+		if err := stream.Send(progressToProto(job)); err != nil {
+			return err
+		}
+		if job.State != jobQueued && job.State != jobRunning {
+			return nil
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelWipe cancels a queued or running job. A running wipe stops at the
+// next block, so the returned state may still be "running".
+func (s *grpcServer) CancelWipe(ctx context.Context, req *rpc.JobRequest) (*rpc.Job, error) {
+	job, err := s.d.cancel(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return jobToProto(job), nil
+}
+
+func (s *grpcServer) GetCertificate(ctx context.Context, req *rpc.JobRequest) (*rpc.Certificate, error) {
+	job, err := s.d.snapshot(req.GetId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	cert, err := jobCertificate(job, s.d.run)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &rpc.Certificate{
+		JobId:           cert.JobID,
+		Device:          cert.Device,
+		Serial:          cert.Serial,
+		Size:            cert.Size,
+		Method:          cert.Method,
+		Coverage:        float64(cert.Coverage.Num) / float64(cert.Coverage.Den),
+		BytesWritten:    cert.BytesWritten,
+		StartedAt:       cert.StartedAt.Format(time.RFC3339),
+		FinishedAt:      cert.FinishedAt.Format(time.RFC3339),
+		AverageSpeedBps: cert.AverageSpeed,
+		Hostname:        cert.Run.Hostname,
+		Operator:        cert.Run.Operator,
+		Version:         cert.Run.Version,
+	}, nil
+}
+
+// grpcError maps daemon errors onto gRPC status codes.
+func grpcError(err error) error {
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, errNoSuchJob):
+		code = codes.NotFound
+	case errors.Is(err, errQueueFull):
+		code = codes.ResourceExhausted
+	case errors.Is(err, errJobFinished), errors.Is(err, errNotFinished):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+func jobToProto(job daemonJob) *rpc.Job {
+	msg := &rpc.Job{
+		Id:          job.ID,
+		Device:      job.Spec.Device,
+		Serial:      job.Serial,
+		State:       string(job.State),
+		Error:       job.Error,
+		SubmittedAt: job.SubmittedAt.Format(time.RFC3339),
+		StartedAt:   formatOptionalTime(job.StartedAt),
+		FinishedAt:  formatOptionalTime(job.FinishedAt),
+	}
+	if job.Progress != nil {
+		msg.Progress = progressToProto(job)
+	}
+	return msg
+}
+
+func progressToProto(job daemonJob) *rpc.Progress {
+	msg := &rpc.Progress{JobId: job.ID, State: string(job.State)}
+	switch {
+	case job.Result != nil:
+		msg.BytesProcessed = job.Result.BytesProcessed
+		msg.BytesWritten = job.Result.BytesWritten
+		msg.Total = job.Result.Size
+	case job.Progress != nil:
+		msg.BytesProcessed = job.Progress.BytesProcessed
+		msg.BytesWritten = job.Progress.BytesWritten
+		msg.Total = job.Progress.Total
+		msg.SpeedBps = job.Progress.Speed
+		msg.EtaSeconds = int64(job.Progress.ETA.Seconds())
+	}
+	return msg
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
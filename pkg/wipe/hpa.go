@@ -0,0 +1,174 @@
+package wipe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// ATA commands for the Host Protected Area and Device Configuration
+	// Overlay, which hide sectors at the end of a drive
+	ataReadNativeMaxExt = 0x27
+	ataSetMaxAddressExt = 0x37
+	ataDeviceConfig     = 0xb1
+
+	// ataDCOIdentify is the DEVICE CONFIGURATION IDENTIFY feature.
+	ataDCOIdentify = 0xc2
+)
+
+// hiddenAreas describes the sectors of an ATA drive that are hidden from
+// the OS, counted in logical sectors.
+type hiddenAreas struct {
+	Accessible uint64 // sectors the OS can address
+	Native     uint64 // sectors up to the native max address (HPA removed)
+	DCO        uint64 // sectors up to the DCO maximum, or 0 if unknown
+	SectorSize int
+}
+
+// hpa returns the number of sectors hidden by a Host Protected Area.
+func (h hiddenAreas) hpa() uint64 {
+	return h.Native - min(h.Accessible, h.Native)
+}
+
+// dco returns the number of sectors hidden by a Device Configuration
+// Overlay on top of the HPA.
+func (h hiddenAreas) dco() uint64 {
+	return h.DCO - min(h.Native, h.DCO)
+}
+
+// ataLBACommand sends a 48-bit non-data command with count and lba and
+// returns the LBA registers of the result, which the drive reports in the
+// ATA Status Return sense descriptor since CK_COND is set.
+func ataLBACommand(file *os.File, cmd uint8, count uint16, lba uint64) (uint64, error) {
+	cdb := [16]byte{0: 0x85, 1: ataProtocolNonData<<1 | 1, 2: 0x20, 13: 0x40, 14: cmd}
+	cdb[5], cdb[6] = byte(count>>8), byte(count)
+	cdb[7], cdb[8] = byte(lba>>24), byte(lba)
+	cdb[9], cdb[10] = byte(lba>>32), byte(lba>>8)
+	cdb[11], cdb[12] = byte(lba>>40), byte(lba>>16)
+	hdr, sense, err := sgExecute(file, cdb, sgDxferNone, nil, time.Minute)
+	if err != nil {
+		return 0, err
+	}
+	if hdr.HostStatus != 0 {
+		return 0, fmt.Errorf("ATA command %#x failed (host %#x)", cmd, hdr.HostStatus)
+	}
+
+	// Descriptor-format sense data, descriptors from byte 8
+	if sense[0]&0x7f != 0x72 {
+		return 0, fmt.Errorf("%w: no ATA registers returned for command %#x", ErrUnsupported, cmd)
+	}
+	end := min(len(sense), 8+int(sense[7]))
+	for i := 8; i+14 <= end; i += 2 + int(sense[i+1]) {
+		if d := sense[i:]; d[0] == 0x09 {
+			if d[13]&0x01 != 0 {
+				return 0, fmt.Errorf("ATA command %#x aborted (error %#x)", cmd, d[3])
+			}
+			return uint64(d[7]) | uint64(d[9])<<8 | uint64(d[11])<<16 |
+				uint64(d[6])<<24 | uint64(d[8])<<32 | uint64(d[10])<<40, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no ATA registers returned for command %#x", ErrUnsupported, cmd)
+}
+
+// ataDCOMax reads the highest LBA the Device Configuration Overlay allows.
+func ataDCOMax(file *os.File) (uint64, error) {
+	data := make([]byte, 512)
+	cdb := [16]byte{0: 0x85, 1: ataProtocolPIOIn << 1, 2: 0x0e, 4: ataDCOIdentify, 6: 1, 14: ataDeviceConfig}
+	hdr, _, err := sgExecute(file, cdb, sgDxferFromDev, data, time.Minute)
+	if err != nil {
+		return 0, err
+	}
+	if hdr.Status != 0 || hdr.HostStatus != 0 || hdr.DriverStatus != 0 {
+		return 0, errors.New("DEVICE CONFIGURATION IDENTIFY failed")
+	}
+	return binary.LittleEndian.Uint64(data[6:]), nil
+}
+
+// readHiddenAreas compares the sectors an ATA drive exposes with its native
+// max address and DCO maximum. The DCO is optional and left at 0 when the
+// drive doesn't report it.
+func readHiddenAreas(file *os.File) (hiddenAreas, error) {
+	identify := make([]byte, 512)
+	if err := ataCommand(file, ataIdentifyDevice, ataProtocolPIOIn, identify, time.Minute); err != nil {
+		return hiddenAreas{}, fmt.Errorf("%w: IDENTIFY DEVICE through ATA pass-through failed: %w", ErrUnsupported, err)
+	}
+	word := func(n int) uint64 { return uint64(binary.LittleEndian.Uint16(identify[2*n:])) }
+	if word(83)&(1<<10) == 0 {
+		return hiddenAreas{}, fmt.Errorf("%w: the drive has no 48-bit addressing", ErrUnsupported)
+	}
+
+	areas := hiddenAreas{
+		Accessible: word(100) | word(101)<<16 | word(102)<<32 | word(103)<<48,
+		SectorSize: 512,
+	}
+	// Word 106: valid when bits 15:14 are 01; bit 12 means words 117-118
+	// hold the logical sector size in words
+	if w := word(106); w&0xc000 == 0x4000 && w&(1<<12) != 0 {
+		areas.SectorSize = int(word(117)|word(118)<<16) * 2
+	}
+
+	native, err := ataLBACommand(file, ataReadNativeMaxExt, 0, 0)
+	if err != nil {
+		return hiddenAreas{}, err
+	}
+	areas.Native = native + 1
+	if dcoMax, err := ataDCOMax(file); err == nil && dcoMax > 0 {
+		areas.DCO = dcoMax + 1
+	}
+	return areas, nil
+}
+
+// checkHiddenAreas warns about sectors of the ATA drive job.Device that an
+// HPA or DCO hides from the wipe. With RemoveHPA set, and outside a dry
+// run, it sets the max address to the native max until the next power
+// cycle and has the kernel rescan the disk, so that the whole drive is
+// wiped. Devices that aren't ATA disks are skipped silently.
+func checkHiddenAreas(job *wipeJob) error {
+	if !isATADisk(job.Device) {
+		return nil
+	}
+	file, err := os.OpenFile(job.Device, os.O_RDWR, 0)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	areas, err := readHiddenAreas(file)
+	if err != nil {
+		if job.RemoveHPA {
+			warnf("Cannot read the native max address of %s: %v", job.Device, err)
+		}
+		return nil
+	}
+
+	if hidden := areas.hpa(); hidden > 0 {
+		bytes := int64(hidden) * int64(areas.SectorSize)
+		if !job.RemoveHPA || job.dryRun {
+			dangerf("WARNING: a Host Protected Area hides %d sectors (%s) at the end of %s, which won't be wiped; use -remove-hpa to include them",
+				hidden, FormatBytes(bytes), job.Device)
+		} else {
+			fmt.Printf("Removing the Host Protected Area of %s (%d sectors, %s) until the next power cycle\n",
+				job.Device, hidden, FormatBytes(bytes))
+			// SET MAX ADDRESS must immediately follow READ NATIVE MAX ADDRESS
+			if _, err := ataLBACommand(file, ataReadNativeMaxExt, 0, 0); err != nil {
+				return newDeviceError("remove HPA", job.Device, err)
+			}
+			if _, err := ataLBACommand(file, ataSetMaxAddressExt, 0, areas.Native-1); err != nil {
+				return newDeviceError("remove HPA", job.Device, err)
+			}
+			rescan := filepath.Join("/sys/block", blockDeviceName(job.Device), "device/rescan")
+			if err := os.WriteFile(rescan, []byte("1"), 0); err != nil {
+				return newDeviceError("rescan", job.Device, err)
+			}
+		}
+	}
+	if hidden := areas.dco(); hidden > 0 {
+		dangerf("WARNING: a Device Configuration Overlay hides %d sectors (%s) at the end of %s, which won't be wiped; hdparm --dco-restore can remove it",
+			hidden, FormatBytes(int64(hidden)*int64(areas.SectorSize)), job.Device)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package wipe
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// inlineVerifier reads back every block wipeDevice writes for -verify-inline
+// as soon as it is written, and compares it with the buffer it was written
+// from before that buffer is refilled. Random data needs no digests this way.
+type inlineVerifier struct {
+	job       wipeJob
+	file      *os.File
+	got       []byte
+	blockSize int
+	verr      *VerifyError
+}
+
+// newInlineVerifier opens job's device a second time for reading, around the
+// page cache if it can, so that the data comes from the device rather than
+// from the write just made.
+func newInlineVerifier(job wipeJob, bufferSize int) (*inlineVerifier, error) {
+	file, err := openDirect(job.Device, os.O_RDONLY)
+	if err != nil {
+		file, err = os.Open(job.Device)
+		if err != nil {
+			return nil, newDeviceError("open", job.Device, err)
+		}
+	}
+	got, err := AllocAlignedBuffer(bufferSize)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	return &inlineVerifier{job: job, file: file, got: got, blockSize: deviceBlockSize(job.Device)}, nil
+}
+
+// check reads back the block written from data at offset within the job's
+// window. A mismatch is reported at once and recorded; only a failed read
+// is returned as an error.
+func (v *inlineVerifier) check(data []byte, offset int64) error {
+	physical := v.job.physical(offset)
+	n, err := readAligned(v.file, v.got, len(data), physical, v.blockSize)
+	if err != nil {
+		return newDeviceError("read", v.job.Device, err)
+	}
+	if bytes.Equal(v.got[:n], data) {
+		return nil
+	}
+	if v.verr == nil {
+		v.verr = newVerifyError(v.job.Pattern, physical, v.got[:n], data[:n])
+	}
+	v.verr.Blocks = append(v.verr.Blocks, physical)
+	fmt.Println()
+	errorf("%s: block at offset %d did not read back as written", v.job.Device, physical)
+	return nil
+}
+
+// close releases the descriptor and buffer of the verifier.
+func (v *inlineVerifier) close() {
+	v.file.Close()
+	FreeAlignedBuffer(v.got)
+}
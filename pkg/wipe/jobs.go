@@ -0,0 +1,1205 @@
+package wipe
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errAborted is returned when the user declines a confirmation prompt.
+var errAborted = errors.New("operation aborted")
+
+// errSerialMismatch is returned when a device's serial number is not the one
+// given with -expect-serial.
+var errSerialMismatch = errors.New("device serial number does not match -expect-serial")
+
+// errNotDevPath is returned under -assume-yes for a target outside /dev/,
+// which only -force accepts without asking.
+var errNotDevPath = errors.New("doesn't look like a block device (doesn't start with /dev/); -assume-yes doesn't confirm this, use -force")
+
+// errMaxDuration is the cancellation cause of a job stopped by -max-duration.
+var errMaxDuration = errors.New("reached -max-duration")
+
+// wipeJob holds the per-device settings of a wipe. Jobs start as a copy of
+// the command-line flags and may be adjusted per device.
+type wipeJob struct {
+	Device string `json:"device"`
+	Options
+
+	// PreservePartitionTable saves the MBR/GPT before a whole-disk wipe and
+	// writes it back afterwards.
+	PreservePartitionTable bool `json:"preserve_partition_table"`
+
+	// PreserveTable wipes only the partitions the MBR/GPT lists, leaving
+	// the table, and everything else outside the partitions, untouched.
+	PreserveTable bool `json:"preserve_table,omitempty"`
+
+	// RemoveHPA lifts a Host Protected Area until the next power cycle, so
+	// that the sectors it hides are wiped too.
+	RemoveHPA bool `json:"remove_hpa,omitempty"`
+
+	SMART       bool   `json:"smart"`                 // record SMART data before and after
+	Certificate string `json:"certificate,omitempty"` // write a wipe certificate here on success
+	Summary     string `json:"summary,omitempty"`     // write the wipe statistics here on success or interruption
+	Stamp       bool   `json:"stamp,omitempty"`       // write a "DATA WIPED" marker to the first block on success
+
+	ExpectSize   int64  `json:"expect_size,omitempty"`   // refuse devices of another size
+	ExpectSerial string `json:"expect_serial,omitempty"` // refuse devices with another serial number
+
+	CheckWiped string `json:"check_wiped,omitempty"` // "ask" or "skip" if the device already holds the pattern
+
+	size           int64         // detected by prepareJob
+	partitionTable []savedRegion // saved by runJob
+	pass           int           // index of the overwrite pass being run
+	resumeFrom     *checkpoint   // loaded for -resume
+	dryRun         bool          // -dry-run: report the plan and write nothing
+	assumeYes      bool          // -assume-yes: confirm the wipe without asking, but keep the safety checks
+	blockSize      int           // what direct I/O writes are aligned to, set by wipeDevice
+	gaps           *gapFill      // set for the second stage of -fill-gaps
+	patternData    []byte        // contents of PatternFile, read by prepareJob
+	zeroOutMax     int64         // write-zeroes limit of the disk for -write-zeroes, found by prepareJob
+	benchSpeeds    []regionSpeed // measured by prepareJob for -benchmark-regions
+	estimate       *jobEstimate  // how long the job should take, found by prepareJob; nil if unknown
+
+	// With -preserve-table, prepareJob lists the partitions to wipe, and
+	// each is wiped as a window onto the disk: offsets are relative to the
+	// window's start, and size is its length rather than diskSize
+	extents  []partitionExtent
+	window   *partitionExtent
+	diskSize int64
+}
+
+// gapFill carries what the second stage of -fill-gaps takes over from the
+// last pass, which it completes.
+type gapFill struct {
+	written  int64         // bytes the last pass wrote
+	digests  []blockDigest // recorded by the last pass
+	deadline time.Time     // stop filling here; zero means no limit
+}
+
+// validateJob checks the job's settings independently of the device.
+func validateJob(job wipeJob) error {
+	if job.Device == "" {
+		return errors.New("block device path is required")
+	}
+	if job.SkipFactor < 1 && !job.AutoSkip {
+		return errors.New("skip factor must be at least 1")
+	}
+	if job.SkipMode != skipModeStride && job.SkipMode != skipModeRandom {
+		return fmt.Errorf("unknown skip mode %q; use stride or random", job.SkipMode)
+	}
+	if job.SkipSeed != 0 && job.SkipMode != skipModeRandom {
+		return errors.New("-skip-seed only applies to -skip-mode random")
+	}
+	if job.VerifySample < 0 {
+		return errors.New("-verify-sample must be at least 0")
+	}
+	if job.VerifyInline && (job.Verify || job.VerifySample > 0 || job.EntropyCheck) {
+		return errors.New("-verify-inline reads every block back as it is written and cannot be combined with -verify, -verify-sample or -entropy-check")
+	}
+	if job.VerifyInline && job.Discard {
+		return errors.New("-discard writes nothing for -verify-inline to read back")
+	}
+	if job.ETAWindow < 1 {
+		return errors.New("-eta-window must be at least 1")
+	}
+	if !validSyncMode(job.SyncMode) {
+		return fmt.Errorf("unknown sync mode %q; use osync, fdatasync or none", job.SyncMode)
+	}
+	if job.SyncEvery < 1 {
+		return errors.New("-sync-every must be at least 1")
+	}
+	if job.ProgressInterval <= 0 {
+		return errors.New("progress interval must be positive")
+	}
+	if job.Coverage < 0 || job.Coverage > 1 {
+		return errors.New("coverage must be between 0 and 1")
+	}
+	if job.Coverage > 0 && (job.SkipFactor > 1 || job.AutoSkip) {
+		return errors.New("-coverage cannot be combined with -skip or -auto-skip; use one of them")
+	}
+	if job.SkipFactor > 1 && job.AutoSkip {
+		return errors.New("-skip cannot be combined with -auto-skip; use one of them")
+	}
+	if !validPattern(job.Pattern) {
+		return fmt.Errorf("unknown pattern %q", job.Pattern)
+	}
+	if job.PatternFile != "" && job.Pattern != patternFile {
+		return fmt.Errorf("-pattern-file cannot be combined with -pattern %s", job.Pattern)
+	}
+	if job.Pattern == patternFile && job.PatternFile == "" {
+		return errors.New("-pattern file needs -pattern-file")
+	}
+	if _, ok := schemePasses[job.Scheme]; job.Scheme != "" && !ok {
+		return fmt.Errorf("unknown scheme %q (want dod)", job.Scheme)
+	}
+	if job.Scheme != "" && (job.SecureRandomZero || job.DiscardVerify || job.Passes > 1) {
+		return errors.New("-scheme cannot be combined with -passes, -secure-random-zero or -discard-verify")
+	}
+	if job.Discard && (job.DiscardVerify || job.DiscardFirst) {
+		return errors.New("-discard cannot be combined with -discard-verify or -discard-first")
+	}
+	if job.NVMeSanitize && (job.Discard || job.DiscardVerify || job.DiscardFirst) {
+		return errors.New("-nvme-sanitize cannot be combined with -discard, -discard-verify or -discard-first")
+	}
+	if job.ATASecureErase && (job.Discard || job.DiscardVerify || job.DiscardFirst) {
+		return errors.New("-ata-secure-erase cannot be combined with -discard, -discard-verify or -discard-first")
+	}
+	if job.SecureRandomZero && job.DiscardVerify {
+		return errors.New("-secure-random-zero and -discard-verify cannot be combined")
+	}
+	if !validRNG(job.RNG) {
+		return fmt.Errorf("unknown random source %q", job.RNG)
+	}
+	if !validDigest(job.Digest) {
+		return fmt.Errorf("unknown digest %q", job.Digest)
+	}
+	if job.RandomRefresh < 1 {
+		return errors.New("random refresh must be at least 1")
+	}
+	if job.Passes < 1 {
+		return errors.New("number of passes must be at least 1")
+	}
+	if job.ExpectSize < 0 {
+		return errors.New("expected size must be positive")
+	}
+	if job.PipelineDepth < 1 {
+		return errors.New("pipeline depth must be at least 1")
+	}
+	if !validCheckWiped(job.CheckWiped) {
+		return fmt.Errorf("unknown -check-wiped mode %q (want ask or skip)", job.CheckWiped)
+	}
+	if job.ReopenWait < 0 {
+		return errors.New("reopen wait must not be negative")
+	}
+	if job.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if job.Rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+	if job.MaxDuration < 0 {
+		return errors.New("max duration must not be negative")
+	}
+	if job.BenchmarkSize < 0 || job.BenchmarkTime < 0 || job.BenchmarkCacheAge < 0 {
+		return errors.New("benchmark size, time and cache age must not be negative")
+	}
+	if !validBenchmarkRegions(job.BenchmarkRegions) {
+		return fmt.Errorf("unknown -benchmark-regions mode %q (want average or worst)", job.BenchmarkRegions)
+	}
+	if job.PreserveTable && job.PreservePartitionTable {
+		return errors.New("-preserve-table and -preserve-partition-table cannot be combined")
+	}
+	if job.PreserveTable && (job.Discard || job.DiscardVerify || job.DiscardFirst || job.NVMeSanitize || job.ATASecureErase) {
+		return errors.New("-preserve-table overwrites the partitions and cannot be combined with -discard, -discard-verify, -discard-first, -nvme-sanitize or -ata-secure-erase")
+	}
+	if job.PreserveTable && job.CheckWiped != "" {
+		return errors.New("-preserve-table cannot be combined with -check-wiped")
+	}
+	if job.Stamp && (job.PreservePartitionTable || job.PreserveTable) {
+		return errors.New("-stamp writes over the partition table and cannot be combined with -preserve-partition-table or -preserve-table")
+	}
+	return nil
+}
+
+// coverage returns the share of blocks the job writes.
+func (job wipeJob) coverage() coverage {
+	if job.Coverage > 0 {
+		return fractionCoverage(job.Coverage)
+	}
+	return skipCoverage(job.SkipFactor)
+}
+
+// blockSelector returns the selector of the blocks the job's current pass
+// writes. Random selections differ from pass to pass by their seed.
+func (job wipeJob) blockSelector() *blockSelector {
+	if job.SkipMode == skipModeRandom {
+		return newRandomSelector(job.coverage(), job.passSeed(), false)
+	}
+	return newBlockSelector(job.coverage())
+}
+
+// passSeed returns the seed of the random block selection of the job's
+// current pass.
+func (job wipeJob) passSeed() uint64 {
+	return mix64(job.SkipSeed, uint64(job.pass))
+}
+
+// passPatterns returns the pattern of every overwrite pass the job makes.
+// With -secure-random-zero the passes are random and a final zero pass
+// follows them.
+func (job wipeJob) passPatterns() []string {
+	if passes, ok := schemePasses[job.Scheme]; ok {
+		return passes
+	}
+	pattern := job.Pattern
+	if job.SecureRandomZero {
+		pattern = patternRandom
+	}
+	patterns := make([]string, max(job.Passes, 1))
+	for i := range patterns {
+		patterns[i] = pattern
+	}
+	if job.SecureRandomZero {
+		patterns = append(patterns, patternZero)
+	}
+	return patterns
+}
+
+// fillsGaps reports whether the blocks the job's passes skip are written in
+// a second stage.
+func (job wipeJob) fillsGaps() bool {
+	return job.FillGaps && !job.coverage().full()
+}
+
+// wipedSize returns how much of the device the job overwrites at full
+// coverage: all of it, or the partitions found for -preserve-table.
+func (job wipeJob) wipedSize() int64 {
+	if job.extents == nil {
+		return job.size
+	}
+	var size int64
+	for _, e := range job.extents {
+		size += e.Length
+	}
+	return size
+}
+
+// physical maps an offset within the job's window to the device offset.
+func (job wipeJob) physical(offset int64) int64 {
+	if job.window != nil {
+		return job.window.Start + offset
+	}
+	return offset
+}
+
+// passStart returns the offset the job's current pass starts writing at.
+// When only part of the device is covered, each pass is shifted by a share
+// of the gap between written blocks, so that over several passes different
+// blocks are overwritten. Random selections are reshuffled instead.
+func (job wipeJob) passStart() int64 {
+	cov := job.coverage()
+	if cov.full() || job.SkipMode == skipModeRandom {
+		return 0
+	}
+	gap := max(cov.Den/cov.Num, 1)
+	passes := min(int64(len(job.passPatterns())), gap)
+	shift := int64(job.pass) * gap / passes % gap
+	return min(job.size, shift*int64(job.BufferSize))
+}
+
+// checkTarget makes sure device is something we can sensibly write to and
+// that neither it nor one of its partitions is mounted. With force, both
+// problems only produce a warning, except for targets that are not block
+// devices or regular files at all.
+func checkTarget(device string, force bool) error {
+	if err := checkTargetType(device); err != nil {
+		if !force || !errors.Is(err, ErrNotBlockDevice) {
+			return err
+		}
+		warnf("%v; continuing because of -force", err)
+	}
+
+	// Refuse to write to a disk while it or one of its partitions is mounted
+	if err := checkNotMounted(device); err != nil {
+		if !force {
+			return err
+		}
+		warnf("%v; continuing because of -force", err)
+	}
+	return nil
+}
+
+// checkWritable opens device for writing and closes it again, so that a
+// missing device or missing permission is reported before benchmarks and
+// prompts rather than at the first write. Other failures, such as a
+// directory or a device in use, are left to checkTarget and the exclusive
+// open, which explain them better.
+func checkWritable(device string) error {
+	file, err := os.OpenFile(device, os.O_WRONLY, 0)
+	if err != nil {
+		err = newDeviceError("open for writing", device, err)
+		if errors.Is(err, ErrPermission) || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return nil
+	}
+	return file.Close()
+}
+
+// prepareJob sizes the device, resolves the skip factor and asks for
+// confirmation. It must be called for every job before any of them starts
+// wiping so that prompts are never interleaved with progress output. For a
+// dry run it prints the plan instead of asking, and never writes. Cancelling
+// ctx stops the write benchmark.
+func prepareJob(ctx context.Context, job *wipeJob, force bool) error {
+	// The final zero pass, and the last pass of a scheme, is always verified
+	if job.SecureRandomZero || job.Scheme != "" {
+		job.Verify = true
+	}
+	// -verify-sample is a cheaper verify, and -entropy-check is part of one
+	if job.VerifySample > 0 || job.EntropyCheck {
+		job.Verify = true
+	}
+	if patterns := job.passPatterns(); job.EntropyCheck && deterministicPattern(patterns[len(patterns)-1]) {
+		warnf("-entropy-check only applies to random data; the %s pattern is compared byte for byte", patterns[len(patterns)-1])
+	}
+
+	if job.Pattern == patternFile {
+		data, err := readPatternFile(job.PatternFile)
+		if err != nil {
+			return fmt.Errorf("pattern file %s: %w", job.PatternFile, err)
+		}
+		job.patternData = data
+	}
+
+	if !job.dryRun {
+		if err := checkWritable(job.Device); err != nil {
+			return err
+		}
+	}
+	if err := checkTarget(job.Device, force); err != nil {
+		return err
+	}
+
+	// Device paths can change between boots; the serial number doesn't
+	serial := deviceSerial(job.Device)
+	if job.ExpectSerial != "" && serial != job.ExpectSerial {
+		reported := "no serial number"
+		if serial != "" {
+			reported = fmt.Sprintf("serial number %q", serial)
+		}
+		dangerf("WARNING: %s reports %s, expected %q. This is not the disk you meant to wipe.", job.Device, reported, job.ExpectSerial)
+		return fmt.Errorf("%s: %w", job.Device, errSerialMismatch)
+	}
+
+	if job.RNG == rngHardware && !hwRNGAvailable() {
+		warnf("This CPU has no RDRAND instruction, using %s instead", rngDescription(rngChaCha))
+		job.RNG = rngChaCha
+	}
+
+	if job.PreservePartitionTable || job.PreserveTable {
+		if name := blockDeviceName(job.Device); parentDiskName(name) != name {
+			flagName := "-preserve-partition-table"
+			if job.PreserveTable {
+				flagName = "-preserve-table"
+			}
+			return fmt.Errorf("%s is a partition; %s needs a whole disk", job.Device, flagName)
+		}
+	}
+
+	// Sectors hidden by an HPA or DCO aren't part of the size the OS sees
+	if err := checkHiddenAreas(job); err != nil {
+		return err
+	}
+
+	// Get device size
+	deviceSize, err := DeviceSize(job.Device)
+	if err != nil {
+		return fmt.Errorf("error getting device size: %w", err)
+	}
+	if deviceSize <= 0 {
+		return &DeviceError{Op: "get size of", Path: job.Device, Err: ErrNoMedia}
+	}
+	job.size = deviceSize
+
+	// Every block written with direct I/O must be whole sectors
+	aligned := alignBufferSize(job.BufferSize, deviceBlockSize(job.Device))
+	debugf("%s: buffer size %d requested, %d once aligned to %d-byte blocks",
+		job.Device, job.BufferSize, aligned, deviceBlockSize(job.Device))
+	if aligned != job.BufferSize {
+		warnf("Buffer size %d is not a multiple of the %d-byte blocks of %s, using %d",
+			job.BufferSize, deviceBlockSize(job.Device), job.Device, aligned)
+		job.BufferSize = aligned
+	}
+
+	// Zero passes can be left to the disk itself
+	if job.WriteZeroes {
+		job.zeroOutMax = writeZeroesMax(job.Device)
+		switch {
+		case job.zeroOutMax == 0:
+			warnf("%s doesn't offload writing zeros; -write-zeroes falls back to writing them", job.Device)
+		case !slices.Contains(job.passPatterns(), patternZero):
+			warnf("-write-zeroes only applies to zero passes, and %s makes none", job.Device)
+		case job.Rate > 0:
+			warnf("-write-zeroes can't be limited by -rate; writing the zeros instead")
+		}
+	}
+
+	// With -preserve-table only the partitions are wiped, and the estimates
+	// below are for them alone
+	if job.PreserveTable {
+		if err := findExtents(job); err != nil {
+			return err
+		}
+	}
+	wipeSize := job.wipedSize()
+
+	// A disk of the wrong size in a batch of identical ones is most likely
+	// the wrong disk
+	if job.ExpectSize > 0 && !sizeMatches(deviceSize, job.ExpectSize) {
+		mismatch := fmt.Sprintf("%s is %s (%d bytes), expected %s (%d bytes)",
+			job.Device, FormatBytes(deviceSize), deviceSize, FormatBytes(job.ExpectSize), job.ExpectSize)
+		if force || job.assumeYes || job.dryRun {
+			return fmt.Errorf("%s: %w", mismatch, errSizeMismatch)
+		}
+		dangerf("WARNING: %s. This may be the wrong disk.", mismatch)
+		name := filepath.Base(job.Device)
+		fmt.Printf("Type the device name (%s) to wipe it anyway: ", name)
+		var response string
+		fmt.Scanln(&response)
+		if response != name {
+			return errAborted
+		}
+	}
+
+	// Re-processing a batch shouldn't spend hours on disks already done
+	if job.CheckWiped != "" {
+		if err := checkAlreadyWiped(*job, force); err != nil {
+			return err
+		}
+	}
+
+	// A verify pass reads back everything that is written, at read speed,
+	// and -verify-inline does so in every pass. A dry run also uses it to
+	// estimate the write speed.
+	readSpeed := float64(0)
+	fullVerify := (job.Verify && job.VerifySample == 0) || job.VerifyInline
+	if fullVerify || job.dryRun {
+		fmt.Printf("Running read speed benchmark on %s...\n", job.Device)
+		readSpeed, err = benchmarkReadSpeed(job.Device, job.BufferSize, readBenchmarkSize)
+		if err != nil {
+			return fmt.Errorf("error during read benchmark: %w", err)
+		}
+		fmt.Printf("Benchmark complete. Read speed: %.2f MB/s\n", readSpeed/1024/1024)
+	}
+	verifySpeed := float64(0)
+	if fullVerify {
+		verifySpeed = readSpeed
+	}
+	// How often every written block is read back
+	reads := 1.0
+	if job.VerifyInline {
+		reads = float64(len(job.passPatterns()))
+	}
+
+	// The write benchmark overwrites the start of the device, so a dry run
+	// has to make do with the read speed
+	writeSpeed := readSpeed
+	tooSmall := false
+	// A drive benchmarked recently by an earlier run isn't benchmarked again
+	var cached cachedBenchmark
+	var cacheHit bool
+	cachePath, cacheKey := benchmarkCachePath(), ""
+	if job.AutoSkip && !job.dryRun && job.BenchmarkCacheAge > 0 {
+		cacheKey = benchmarkCacheKey(job.Device, job.Options)
+		if !job.Rebenchmark {
+			cached, cacheHit = lookupBenchmark(cachePath, cacheKey, job.BenchmarkCacheAge, time.Now())
+		}
+	}
+	if cacheHit {
+		writeSpeed, job.benchSpeeds = cached.Speed, cached.RegionSpeeds
+		fmt.Printf("Using the write speed of %s measured %s ago: %.2f MB/s (-rebenchmark measures it again)\n",
+			job.Device, time.Since(cached.MeasuredAt).Round(time.Minute), writeSpeed/1024/1024)
+	} else if job.AutoSkip && !job.dryRun {
+		fmt.Printf("Running write speed benchmark on %s...\n", job.Device)
+		start, length := int64(0), deviceSize
+		if job.PreserveTable {
+			// Only write where the wipe will, in the largest partition
+			largest := slices.MaxFunc(job.extents, func(a, b partitionExtent) int { return cmp.Compare(a.Length, b.Length) })
+			start, length = largest.Start, largest.Length
+		}
+		if job.BenchmarkRegions != "" {
+			writeSpeed, job.benchSpeeds, err = benchmarkWriteRegions(ctx, job.Device, job.Options, start, length)
+		} else {
+			writeSpeed, err = benchmarkWriteSpeedAt(ctx, job.Device, job.Options, start, length)
+		}
+		switch {
+		case errors.Is(err, errTooSmallToBenchmark):
+			fmt.Printf("Skipping the write benchmark: %s (%s) is smaller than two %s buffers; using skip factor 1\n",
+				job.Device, FormatBytes(deviceSize), FormatBytes(int64(job.BufferSize)))
+			tooSmall = true
+		case err != nil:
+			return fmt.Errorf("error during benchmark: %w", err)
+		default:
+			fmt.Printf("Benchmark complete. Write speed: %.2f MB/s\n", writeSpeed/1024/1024)
+			measured := cachedBenchmark{Speed: writeSpeed, RegionSpeeds: job.benchSpeeds, MeasuredAt: time.Now().UTC()}
+			if err := storeBenchmark(cachePath, cacheKey, measured, job.BenchmarkCacheAge); err != nil {
+				warnf("Cannot cache the write speed of %s: %v", job.Device, err)
+			}
+		}
+	}
+	// Without a benchmark, a short read stands in for the write speed so
+	// that the finish time of the run can be estimated
+	if writeSpeed == 0 && !tooSmall && (!job.Discard || job.DiscardVerify) && !job.dryRun {
+		fmt.Printf("Probing the read speed of %s to estimate the wipe time...\n", job.Device)
+		if speed, err := benchmarkReadSpeed(job.Device, job.BufferSize, estimateProbeSize); err == nil {
+			writeSpeed = speed
+		} else {
+			warnf("Cannot estimate the wipe time of %s: %v", job.Device, err)
+		}
+	}
+	if job.Rate > 0 && writeSpeed > float64(job.Rate) {
+		writeSpeed = float64(job.Rate)
+	}
+
+	// Auto-determine skip factor if requested
+	if tooSmall {
+		job.SkipFactor = 1
+	} else if job.AutoSkip {
+		// Calculate skip factor to complete in target hours, including the
+		// time to read every written block back when verifying
+		targetSeconds := job.TargetHours * 3600
+		secondsPerByte := float64(len(job.passPatterns())) / writeSpeed
+		if verifySpeed > 0 {
+			secondsPerByte += reads / verifySpeed
+		}
+		calculatedSkip := int(float64(wipeSize) * secondsPerByte / targetSeconds)
+
+		// Ensure minimum skip factor of 1
+		if calculatedSkip < 1 {
+			calculatedSkip = 1
+		}
+
+		job.SkipFactor = calculatedSkip
+		fmt.Printf("Auto-determined skip factor: %d (estimated completion time: %.1f hours)\n",
+			job.SkipFactor, float64(wipeSize)*secondsPerByte/float64(job.SkipFactor)/3600)
+	} else if verifySpeed > 0 && !job.dryRun {
+		cov := job.coverage()
+		written := float64(wipeSize) * float64(cov.Num) / float64(cov.Den)
+		fmt.Printf("Estimated verify time: %s\n", FormatDuration(time.Duration(reads*written/verifySpeed*float64(time.Second))))
+	}
+
+	// The seed is recorded, and printed by wipeDevice, so that which blocks
+	// were written can be worked out again
+	if job.SkipMode == skipModeRandom && !job.coverage().full() {
+		for job.SkipSeed == 0 {
+			job.SkipSeed = rand.Uint64()
+		}
+	}
+
+	job.estimate = estimateJob(*job, writeSpeed, verifySpeed)
+	if job.Discard {
+		// Discarding takes next to no time, unlike reading it all back
+		job.estimate = &jobEstimate{}
+		if job.DiscardVerify && writeSpeed > 0 {
+			job.estimate.verify = time.Duration(float64(job.wipedSize()) / writeSpeed * float64(time.Second))
+		}
+	}
+
+	if job.dryRun {
+		printPlan(*job, serial, writeSpeed)
+		return nil
+	}
+
+	// Safety check - confirm device path
+	if !strings.HasPrefix(job.Device, "/dev/") && !force && job.assumeYes {
+		return fmt.Errorf("%s %w", job.Device, errNotDevPath)
+	}
+	if !strings.HasPrefix(job.Device, "/dev/") && !force {
+		warnf("%s doesn't look like a block device (doesn't start with /dev/)", job.Device)
+		fmt.Println("This operation is destructive and cannot be undone.")
+		fmt.Print("Continue? (y/N): ")
+		var response string
+		fmt.Scanln(&response)
+		if !strings.HasPrefix(strings.ToLower(response), "y") {
+			return errAborted
+		}
+	}
+
+	skipWarning := ""
+	if cov := job.coverage(); !cov.full() {
+		chosen := cov.describe(job.SkipMode)
+		skipWarning = fmt.Sprintf(" (quick wipe: only writing %s)", chosen)
+		if job.FillGaps {
+			skipWarning = fmt.Sprintf(" (quick wipe: writing %s first, then filling the gaps)", chosen)
+		}
+	}
+	if job.Scheme != "" {
+		skipWarning += fmt.Sprintf(" (%s scheme: %s passes, then verify)", schemeNames[job.Scheme], strings.Join(job.passPatterns(), ", "))
+	} else if job.SecureRandomZero {
+		skipWarning += fmt.Sprintf(" (random pass from %s, then zero pass and zero verify)", rngDescription(job.RNG))
+	} else if job.Pattern == patternFile {
+		skipWarning += fmt.Sprintf(" (pattern: %s from %s)", FormatBytes(int64(len(job.patternData))), job.PatternFile)
+	} else if job.Pattern != patternRandom {
+		skipWarning += fmt.Sprintf(" (pattern: %s)", job.Pattern)
+	} else {
+		skipWarning += fmt.Sprintf(" (random source: %s)", rngDescription(job.RNG))
+	}
+	if job.Passes > 1 {
+		skipWarning += fmt.Sprintf(" (%d passes)", job.Passes)
+	}
+	if job.NVMeSanitize {
+		skipWarning += " (NVMe sanitize, or overwrite if unsupported)"
+	}
+	if job.ATASecureErase {
+		skipWarning += " (ATA secure erase, or overwrite if unsupported)"
+	}
+	if job.Discard {
+		skipWarning += " (discard instead of overwrite)"
+	} else if job.DiscardFirst {
+		skipWarning += " (discard before overwriting)"
+	}
+	if job.PreservePartitionTable {
+		skipWarning += " (keeping the partition table)"
+	} else if job.PreserveTable {
+		skipWarning += fmt.Sprintf(" (only the %d partitions, keeping the partition table)", len(job.extents))
+	}
+	if job.WriteZeroes && job.zeroOutMax > 0 && job.Rate == 0 && !job.VerifyInline && slices.Contains(job.passPatterns(), patternZero) {
+		skipWarning += " (zeros written by the device)"
+	}
+	if job.RandomRefresh > 1 && job.Pattern == patternRandom {
+		skipWarning += fmt.Sprintf(" (random data reused for %d writes)", job.RandomRefresh)
+	}
+
+	identity := deviceIdentity(job.Device, deviceSize, serial)
+	fmt.Printf("Starting to wipe device: %s (%s)%s\n", job.Device, identity, skipWarning)
+
+	// Final confirmation
+	if !force {
+		dangerf("WARNING: This will COMPLETELY ERASE all data on %s (%s).", job.Device, identity)
+		dangerf("This operation is IRREVERSIBLE.")
+	}
+	if !force && job.assumeYes {
+		fmt.Printf("Confirmed by -assume-yes\n")
+	} else if !force {
+		if serial != "" {
+			fmt.Printf("Type the device path (%s) or its serial number (%s) to confirm: ", job.Device, serial)
+		} else {
+			fmt.Printf("Type the device path (%s) to confirm: ", job.Device)
+		}
+		var response string
+		fmt.Scanln(&response)
+		if !confirmsDevice(response, job.Device, serial) {
+			return errAborted
+		}
+	}
+
+	if job.Checkpoint == "" {
+		job.Checkpoint = defaultCheckpointPath(job.Device)
+	}
+	if job.Certificate == certificateAuto {
+		job.Certificate = defaultCertificatePath(job.Device)
+	}
+	if job.Summary == certificateAuto {
+		job.Summary = defaultSummaryPath(job.Device)
+	}
+	return nil
+}
+
+// confirmsDevice reports whether the response typed at the final prompt
+// names the device: its exact path, or its serial number if it has one.
+func confirmsDevice(response, device, serial string) bool {
+	response = strings.TrimSpace(response)
+	return response == device || (serial != "" && response == serial)
+}
+
+// deviceIdentity describes the physical disk behind device for the banner
+// and the confirmation prompt, e.g. "Samsung SSD 870, SSD, size: 500.1 GB,
+// serial: S5XYNX0R".
+func deviceIdentity(device string, size int64, serial string) string {
+	var parts []string
+	if model := deviceModel(device); model != "" {
+		parts = append(parts, model)
+	}
+	if kind := deviceType(device); kind != "" {
+		parts = append(parts, kind)
+	}
+	parts = append(parts, fmt.Sprintf("size: %s", FormatBytes(size)))
+	if serial != "" {
+		parts = append(parts, fmt.Sprintf("serial: %s", serial))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// duplicateDevice returns the first device that more than one job targets,
+// also through a different path such as a /dev/disk/by-id symlink, or "".
+func duplicateDevice(jobs []wipeJob) string {
+	seen := make(map[string]bool)
+	for _, job := range jobs {
+		target := job.Device
+		if resolved, err := filepath.EvalSymlinks(target); err == nil {
+			target = resolved
+		}
+		if seen[target] {
+			return job.Device
+		}
+		seen[target] = true
+	}
+	return ""
+}
+
+// expandPartitions replaces every whole-disk job with one job per partition.
+// Disks without a partition table are kept as a single whole-device job.
+func expandPartitions(jobs []wipeJob) []wipeJob {
+	var expanded []wipeJob
+	for _, job := range jobs {
+		partitions, err := listPartitions(job.Device)
+		if err != nil {
+			warnf("Cannot list partitions of %s, wiping the whole device: %v", job.Device, err)
+		}
+		if len(partitions) == 0 {
+			if err == nil {
+				fmt.Printf("No partitions found on %s, wiping the whole device\n", job.Device)
+			}
+			expanded = append(expanded, job)
+			continue
+		}
+
+		fmt.Printf("%s has %d partition(s): %s\n", job.Device, len(partitions), strings.Join(partitions, ", "))
+		for _, partition := range partitions {
+			partJob := job
+			partJob.Device = partition
+			expanded = append(expanded, partJob)
+		}
+	}
+	return expanded
+}
+
+// checkTargetType stats path and rejects anything that is not a block
+// device, a raw disk or a regular file (disk image).
+func checkTargetType(path string) error {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return newDeviceError("stat", path, err)
+	}
+
+	mode := fi.Mode()
+	switch {
+	case mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0:
+		return nil
+	case mode&os.ModeCharDevice != 0 && isRawDisk(path):
+		return nil
+	case mode.IsRegular():
+		return nil
+	}
+
+	kind := "special file"
+	switch {
+	case mode.IsDir():
+		kind = "directory"
+	case mode&os.ModeCharDevice != 0:
+		kind = "character device"
+	case mode&os.ModeNamedPipe != 0:
+		kind = "FIFO"
+	case mode&os.ModeSocket != 0:
+		kind = "socket"
+	}
+	return &DeviceError{Op: "stat", Path: path, Err: fmt.Errorf("%w: it is a %s", ErrNotBlockDevice, kind)}
+}
+
+// isRegularFile reports whether path is a regular file, such as a disk
+// image, rather than a block device.
+func isRegularFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// checkNotMounted fails with ErrDeviceBusy, naming the mount, if the device
+// or any of its partitions is mounted or used as swap. Regular files are
+// not checked.
+func checkNotMounted(device string) error {
+	if fi, err := os.Stat(device); err != nil || fi.Mode().IsRegular() {
+		return nil
+	}
+
+	inUse, err := mountedParts(device)
+	if err != nil {
+		warnf("Cannot check whether %s is mounted: %v", device, err)
+		return nil
+	}
+	if len(inUse) == 0 {
+		return nil
+	}
+
+	details := make([]string, len(inUse))
+	for i, m := range inUse {
+		details[i] = m.String()
+	}
+	return &DeviceError{Op: "check", Path: device, Err: fmt.Errorf("%w: %s", ErrDeviceBusy, strings.Join(details, ", "))}
+}
+
+// jobOutcome pairs a job with the result of running it.
+type jobOutcome struct {
+	Job    wipeJob
+	Result Result
+	Err    error
+}
+
+// runJob wipes a single prepared job using the method it asks for and runs
+// the steps around it that the job asks for: SMART snapshots, reading the
+// written data back, restoring the partition table and writing the
+// certificate.
+func runJob(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	var result Result
+	var err error
+	started := time.Now().UTC()
+
+	// Running out of time stops the job like a signal does, so it syncs and
+	// leaves a checkpoint to continue from in the next window
+	if job.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, job.MaxDuration, errMaxDuration)
+		defer cancel()
+	}
+
+	var smartBefore *smartSummary
+	if job.SMART {
+		smartBefore = takeSMART(job.Device)
+	}
+
+	// The table must be saved before the first write; a resumed wipe has
+	// already destroyed it and uses the copy kept in the checkpoint
+	if job.PreservePartitionTable {
+		if resume != nil {
+			job.partitionTable = resume.PartitionTable
+		} else if job.partitionTable, err = savePartitionTable(job.Device, job.size); err != nil {
+			return result, fmt.Errorf("cannot save partition table: %w", err)
+		}
+		if len(job.partitionTable) == 0 {
+			return result, fmt.Errorf("cannot restore partition table: %w", errNoPartitionTable)
+		}
+	}
+
+	switch {
+	case job.Discard && resume == nil:
+		result, err = discardOnly(ctx, job, run, report)
+	case job.DiscardVerify && resume == nil:
+		result, err = discardThenVerify(ctx, job, run, report)
+	case job.NVMeSanitize && resume == nil && (isNVMeNamespace(job.Device) || !job.ATASecureErase):
+		result, err = nvmeErase(ctx, job, run, report)
+	case job.ATASecureErase && resume == nil:
+		result, err = ataErase(ctx, job, run, report)
+	default:
+		if job.DiscardFirst && resume == nil {
+			err = discardBeforeOverwrite(ctx, job, report)
+		}
+		switch {
+		case err != nil:
+		case job.extents != nil:
+			// Each partition is verified as soon as it is wiped
+			result, err = wipeExtents(ctx, job, resume, run, report)
+		default:
+			result, err = wipePasses(ctx, job, resume, run, report)
+		}
+	}
+	if err == nil && job.Verify && result.Method == methodOverwrite && job.extents == nil {
+		err = verifyWipe(ctx, job, &result, resume != nil, report)
+	}
+	// The marker goes on last, so that the verify reads back the wipe alone
+	if err == nil && job.Stamp {
+		if err = stampDevice(job, result, run); err == nil {
+			result.Stamped = true
+			fmt.Printf("\nWrote the wipe marker to the first block of %s\n", job.Device)
+		}
+	}
+	if err == nil && job.PreservePartitionTable {
+		if err = restorePartitionTable(job.Device, job.partitionTable); err == nil {
+			fmt.Printf("\nRestored the partition table of %s\n", job.Device)
+		}
+	}
+	result.StartedAt, result.FinishedAt = started, time.Now().UTC()
+	if err != nil {
+		var interrupted *InterruptedError
+		var gone *DisconnectError
+		if job.Summary != "" && (errors.As(err, &interrupted) || errors.As(err, &gone)) {
+			status := summaryInterrupted
+			if errors.Is(err, errMaxDuration) {
+				status = summaryTimeLimit
+			} else if gone != nil {
+				status = summaryDisconnect
+			}
+			if err := writeJSONFile(job.Summary, newWipeSummary(status, job, result)); err != nil {
+				warnf("Cannot write summary: %v", err)
+			}
+		}
+		return result, err
+	}
+
+	if job.SMART {
+		result.SMARTBefore, result.SMARTAfter = smartBefore, takeSMART(job.Device)
+	}
+	if job.Certificate != "" {
+		cert := newCertificate(job, result, result.StartedAt, result.FinishedAt, run)
+		if err := writeCertificate(job.Certificate, cert); err != nil {
+			return result, fmt.Errorf("cannot write certificate: %w", err)
+		}
+		result.Certificate = job.Certificate
+	}
+	if job.Summary != "" {
+		if err := writeJSONFile(job.Summary, newWipeSummary(summaryCompleted, job, result)); err != nil {
+			return result, fmt.Errorf("cannot write summary: %w", err)
+		}
+	}
+
+	// A finished wipe has nothing left to resume
+	if job.Checkpoint != "" {
+		if err := os.Remove(job.Checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+			warnf("Cannot remove checkpoint: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// verifyWipe reads back what the overwrite passes of job left on the device,
+// which is only the last pass, and records the outcome in result. A resumed
+// wipe can't verify the random data written before it was interrupted.
+func verifyWipe(ctx context.Context, job wipeJob, result *Result, resumed bool, report ProgressFunc) error {
+	patterns := job.passPatterns()
+	verifyJob := job
+	verifyJob.Pattern, verifyJob.pass = patterns[len(patterns)-1], len(patterns)-1
+	if result.Coverage.full() {
+		// -fill-gaps wrote the rest of the device
+		verifyJob.SkipFactor, verifyJob.Coverage = 1, 0
+	}
+	if phases := len(patterns) + 1; len(patterns) > 1 || job.fillsGaps() {
+		if job.fillsGaps() {
+			phases++
+		}
+		fmt.Printf("\nPhase %d/%d: verifying the %s pass of %s\n", phases, phases, verifyJob.Pattern, job.Device)
+	}
+	if resumed && !deterministicPattern(verifyJob.Pattern) {
+		fmt.Println()
+		warnf("Random data written before the wipe was interrupted cannot be verified")
+	}
+	var err error
+	if job.VerifySample > 0 {
+		result.VerifiedSamples, err = verifySample(ctx, verifyJob, *result)
+	} else {
+		err = verifyDevice(ctx, verifyJob, *result, report)
+	}
+	result.Verified = err == nil
+	return err
+}
+
+// wipeExtents wipes, and verifies if asked to, each partition found for
+// -preserve-table in turn, as a window onto the disk, leaving everything
+// outside them alone. A resumed job continues with the partition its
+// checkpoint was written in. The result adds up the partitions, with the
+// bad blocks and block digests at their offsets on the disk.
+func wipeExtents(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	first := 0
+	if resume != nil {
+		first = slices.IndexFunc(job.extents, func(e partitionExtent) bool { return e.Number == resume.Partition })
+		if first < 0 {
+			return Result{}, fmt.Errorf("partition %d of the checkpoint is no longer in the partition table of %s", resume.Partition, job.Device)
+		}
+	}
+
+	total := Result{Device: job.Device, Size: job.wipedSize(), Method: methodOverwrite, Verified: job.Verify || job.VerifyInline}
+	var digests []blockDigest
+	start := time.Now()
+	for i, e := range job.extents {
+		if i < first {
+			// Finished before the interruption
+			total.BytesProcessed += e.Length
+			total.ResumedAt += e.Length
+			continue
+		}
+		fmt.Printf("\nPartition %d/%d of %s: %s\n", i+1, len(job.extents), job.Device, e)
+		extentJob := job
+		extentJob.window, extentJob.diskSize, extentJob.size = &job.extents[i], job.size, e.Length
+
+		result, err := wipePasses(ctx, extentJob, resume, run, report)
+		if err == nil && job.Verify {
+			err = verifyWipe(ctx, extentJob, &result, resume != nil, report)
+			total.Verified = total.Verified && result.Verified
+			total.VerifiedSamples += result.VerifiedSamples
+		} else if job.VerifyInline {
+			total.Verified = total.Verified && result.Verified
+		}
+		if resume != nil {
+			total.ResumedAt += result.ResumedAt
+			resume = nil
+		}
+		total.BytesProcessed += result.BytesProcessed
+		total.BytesWritten += result.BytesWritten
+		total.TotalWritten += result.TotalWritten
+		total.Passes, total.Coverage = result.Passes, result.Coverage
+		total.BadBlocks = append(total.BadBlocks, result.BadBlocks...)
+		for _, d := range result.digests {
+			d.Offset = extentJob.physical(d.Offset)
+			digests = append(digests, d)
+		}
+		if err != nil {
+			// Report where on the disk the wipe stopped; the checkpoint
+			// keeps the offset within the partition
+			var interrupted *InterruptedError
+			if errors.As(err, &interrupted) {
+				interrupted.Offset = extentJob.physical(interrupted.Offset)
+			}
+			total.Duration = time.Since(start)
+			return total, err
+		}
+	}
+
+	total.Duration, total.digests = time.Since(start), digests
+	if digests != nil {
+		total.Digest, total.DigestAlgorithm = digestOf(job.Digest, digests), job.Digest
+	}
+	return total, nil
+}
+
+// wipePasses runs the job's overwrite passes one after another, announcing
+// each when there are several, and then fills the gaps of the last one if
+// the job asks for it. A resumed job continues with the pass or stage its
+// checkpoint was written in. The result is that of the last pass, with the
+// wall-clock time and the bytes written by all passes.
+func wipePasses(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	patterns := job.passPatterns()
+	phases := len(patterns)
+	if job.Verify {
+		phases++
+	}
+	if job.fillsGaps() {
+		phases++
+	}
+
+	first := 0
+	if resume != nil {
+		first = resume.Pass
+		if resume.FillingGaps {
+			first = len(patterns)
+		}
+	}
+	var result Result
+	var written int64
+	var badBlocks []int64
+	start := time.Now()
+	for i := first; i < len(patterns); i++ {
+		passJob := job
+		passJob.Pattern, passJob.pass = patterns[i], i
+		if i < len(patterns)-1 {
+			// Earlier passes are overwritten, so there is nothing to verify
+			// or certify
+			passJob.Verify, passJob.Certificate = false, ""
+		}
+		if len(patterns) > 1 || job.fillsGaps() {
+			fmt.Printf("\nPhase %d/%d: %s pass over %s\n", i+1, phases, patterns[i], job.Device)
+		}
+
+		var err error
+		result, err = writePass(ctx, passJob, resume, run, report)
+		resume = nil
+		written += result.BytesWritten
+		badBlocks = append(badBlocks, result.BadBlocks...)
+		if err != nil {
+			// An interrupted pass counts, so that the summary file adds up
+			result.Duration, result.Passes, result.TotalWritten = time.Since(start), i+1, written
+			return result, err
+		}
+	}
+
+	if job.fillsGaps() {
+		gaps := &gapFill{written: result.BytesWritten, digests: result.digests}
+		if job.TargetHours > 0 {
+			gaps.deadline = start.Add(time.Duration(job.TargetHours * float64(time.Hour)))
+		}
+		last := len(patterns) - 1
+		if resume == nil && !gaps.deadline.IsZero() && time.Now().After(gaps.deadline) {
+			fmt.Printf("\nNo time left within -target-hours to fill the gaps of %s\n", job.Device)
+		} else {
+			gapJob := job
+			gapJob.Pattern, gapJob.pass, gapJob.gaps = patterns[last], last, gaps
+			fmt.Printf("\nPhase %d/%d: filling the gaps of the %s pass over %s\n", len(patterns)+1, phases, patterns[last], job.Device)
+			gapResult, err := wipeDevice(ctx, gapJob, resume, run, report)
+			written += gapResult.BytesWritten - gaps.written
+			badBlocks = append(badBlocks, gapResult.BadBlocks...)
+			result = gapResult
+			if err != nil {
+				result.Duration, result.Passes, result.TotalWritten = time.Since(start), len(patterns), written
+				return result, err
+			}
+			if !result.Coverage.full() {
+				fmt.Printf("\nReached -target-hours while filling gaps: %s of %s overwritten\n",
+					FormatBytes(result.BytesWritten), FormatBytes(result.Size))
+			}
+		}
+	}
+	slices.Sort(badBlocks)
+	result.BadBlocks = slices.Compact(badBlocks)
+	result.Duration = time.Since(start)
+	result.Passes = len(patterns)
+	result.TotalWritten = written
+	return result, nil
+}
+
+// runJobs wipes every prepared job, one after another or all at once.
+// Sequential runs stop starting new jobs once ctx is cancelled. Progress is
+// shown on screen, or passed to display instead if it is non-nil, and every
+// update is also passed to observe if it is non-nil.
+func runJobs(ctx context.Context, jobs []wipeJob, parallel bool, run runInfo, display, observe ProgressFunc) []jobOutcome {
+	outcomes := make([]jobOutcome, len(jobs))
+	estimate := newRunEstimate(jobs, parallel)
+
+	if !parallel {
+		report := inPlaceProgress
+		if display != nil {
+			report = display
+		}
+		for i, job := range jobs {
+			if ctx.Err() != nil {
+				outcomes[i] = jobOutcome{Job: job, Err: &InterruptedError{Err: context.Cause(ctx)}}
+				continue
+			}
+			result, err := runJob(ctx, job, job.resumeFrom, run, estimate.progress(i, teeProgress(report, observe)))
+			estimate.finish(i)
+			fmt.Println()
+			if err == nil {
+				fmt.Println(result)
+			}
+			outcomes[i] = jobOutcome{Job: job, Result: result, Err: err}
+		}
+		return outcomes
+	}
+
+	// On a terminal every device gets its own line that is updated in place;
+	// otherwise fall back to periodic per-device lines
+	var board *dashboard
+	report := lineProgress
+	if display != nil {
+		report = display
+	} else if isTerminal(os.Stdout) {
+		devices := make([]string, len(jobs))
+		for i, job := range jobs {
+			devices[i] = job.Device
+		}
+		board = newDashboard(devices)
+		report = board.update
+	}
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := runJob(ctx, job, job.resumeFrom, run, estimate.progress(i, teeProgress(report, observe)))
+			estimate.finish(i)
+			if board != nil {
+				status := "done"
+				if err != nil {
+					status = "failed: " + err.Error()
+				}
+				result.Device = job.Device
+				board.finish(result, status)
+			}
+			outcomes[i] = jobOutcome{Job: job, Result: result, Err: err}
+		}()
+	}
+	wg.Wait()
+
+	for _, outcome := range outcomes {
+		if outcome.Err == nil {
+			fmt.Printf("[%s] %s\n", outcome.Job.Device, strings.ReplaceAll(outcome.Result.String(), "\n", "\n["+outcome.Job.Device+"] "))
+		}
+	}
+	return outcomes
+}
@@ -0,0 +1,103 @@
+package wipe
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonStream writes the newline-delimited JSON events of -json: one
+// "progress" object per progress update, one "result" object per device and
+// a final "summary". A nil *jsonStream writes nothing.
+type jsonStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONStream(w io.Writer) *jsonStream {
+	return &jsonStream{enc: json.NewEncoder(w)}
+}
+
+func (s *jsonStream) emit(v any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.enc.Encode(v)
+}
+
+type jsonProgressEvent struct {
+	Type           string  `json:"type"`
+	Device         string  `json:"device"`
+	BytesProcessed int64   `json:"bytes_processed"`
+	BytesWritten   int64   `json:"bytes_written"`
+	Total          int64   `json:"total"`
+	Percent        float64 `json:"percent"`
+	Speed          float64 `json:"speed_bps"`
+	ETA            float64 `json:"eta_seconds"`
+	Pass           int     `json:"pass"`
+	Passes         int     `json:"passes"`
+	Stage          int     `json:"stage,omitempty"`
+}
+
+// progress is a ProgressFunc emitting a "progress" event.
+func (s *jsonStream) progress(u Progress) {
+	s.emit(jsonProgressEvent{
+		Type:           "progress",
+		Device:         u.Device,
+		BytesProcessed: u.BytesProcessed,
+		BytesWritten:   u.BytesWritten,
+		Total:          u.Total,
+		Percent:        float64(u.BytesProcessed) / float64(u.Total) * 100,
+		Speed:          u.Speed,
+		ETA:            u.ETA.Seconds(),
+		Pass:           max(u.Pass, 1),
+		Passes:         max(u.Passes, 1),
+		Stage:          u.Stage,
+	})
+}
+
+type jsonResultEvent struct {
+	Type   string  `json:"type"`
+	Device string  `json:"device"`
+	Result *Result `json:"result,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// result emits the "result" event of one device.
+func (s *jsonStream) result(outcome jobOutcome) {
+	event := jsonResultEvent{Type: "result", Device: outcome.Job.Device}
+	if outcome.Err != nil {
+		event.Error = outcome.Err.Error()
+	} else {
+		event.Result = &outcome.Result
+	}
+	s.emit(event)
+}
+
+type jsonSummaryEvent struct {
+	Type         string  `json:"type"`
+	Devices      int     `json:"devices"`
+	Failed       int     `json:"failed"`
+	BytesWritten int64   `json:"bytes_written"` // by all passes on all devices
+	Duration     float64 `json:"duration_seconds"`
+	ExitCode     int     `json:"exit_code"`
+}
+
+// summary emits the final "summary" event for outcomes.
+func (s *jsonStream) summary(outcomes []jobOutcome, duration time.Duration, exitCode int) {
+	event := jsonSummaryEvent{Type: "summary", Devices: len(outcomes), Duration: duration.Seconds(), ExitCode: exitCode}
+	for _, outcome := range outcomes {
+		if outcome.Err != nil {
+			event.Failed++
+		}
+		written := outcome.Result.BytesWritten
+		if outcome.Result.TotalWritten > 0 {
+			written = outcome.Result.TotalWritten
+		}
+		event.BytesWritten += written
+	}
+	s.emit(event)
+}
@@ -0,0 +1,118 @@
+package wipe
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// sysBlock lists the whole disks, without their partitions.
+const sysBlock = "/sys/block"
+
+// virtualDiskPrefixes are the kernel names of block devices that are not
+// physical disks and are left out of -list.
+var virtualDiskPrefixes = []string{"loop", "ram", "zram", "dm-", "md", "sr", "fd", "nbd"}
+
+// candidateDisk is a disk found by listDisks.
+type candidateDisk struct {
+	Path   string
+	Model  string
+	Serial string
+	Size   int64
+	Type   string      // "HDD", "SSD" or ""
+	InUse  []mountInfo // the disk or its partitions mounted or used as swap
+}
+
+// listDisks returns the physical disks in /sys/block, ordered by name.
+func listDisks() ([]candidateDisk, error) {
+	entries, err := os.ReadDir(sysBlock)
+	if err != nil {
+		return nil, err
+	}
+	var disks []candidateDisk
+	for _, entry := range entries {
+		name := entry.Name()
+		if isVirtualDisk(name) {
+			continue
+		}
+		sectors, _ := strconv.ParseInt(readSysfsString(filepath.Join(sysBlock, name, "size")), 10, 64)
+		if sectors == 0 {
+			// Card readers and the like without media
+			continue
+		}
+		path := filepath.Join("/dev", name)
+		inUse, err := mountedParts(path)
+		if err != nil {
+			return nil, err
+		}
+		disks = append(disks, candidateDisk{
+			Path:   path,
+			Model:  deviceModel(path),
+			Serial: deviceSerial(path),
+			Size:   sectors * 512, // sysfs counts 512-byte sectors whatever the block size
+			Type:   deviceType(path),
+			InUse:  inUse,
+		})
+	}
+	sort.Slice(disks, func(i, j int) bool { return disks[i].Path < disks[j].Path })
+	return disks, nil
+}
+
+func isVirtualDisk(name string) bool {
+	for _, prefix := range virtualDiskPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// printDisks writes disks to w as a table.
+func printDisks(w io.Writer, disks []candidateDisk) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEVICE\tMODEL\tSERIAL\tSIZE\tTYPE\tIN USE")
+	for _, d := range disks {
+		inUse := make([]string, len(d.InUse))
+		for i, m := range d.InUse {
+			if m.Swap {
+				inUse[i] = m.Device + " (swap)"
+			} else {
+				inUse[i] = m.Device + " on " + m.MountPoint
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Path, orDash(d.Model), orDash(d.Serial),
+			FormatBytes(d.Size), orDash(d.Type), orDash(strings.Join(inUse, ", ")))
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// runList prints the disks that could be wiped and returns the exit code.
+// It only reads from sysfs and /proc.
+func runList() int {
+	disks, err := listDisks()
+	if err != nil {
+		errorf("Cannot list block devices: %v", err)
+		return exitDeviceError
+	}
+	if len(disks) == 0 {
+		fmt.Println("No disks found.")
+		return exitOK
+	}
+	if err := printDisks(os.Stdout, disks); err != nil {
+		errorf("%v", err)
+		return exitDeviceError
+	}
+	return exitOK
+}
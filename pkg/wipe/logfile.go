@@ -0,0 +1,180 @@
+package wipe
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFile is an append-only log file that is rolled over once it
+// reaches maxSize: path becomes path.1, path.1 becomes path.2 and so on, and
+// only keep old files are kept. It never splits a single write.
+type rotatingFile struct {
+	path    string
+	maxSize int64
+	keep    int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, keep int) (*rotatingFile, error) {
+	f := &rotatingFile{path: path, maxSize: maxSize, keep: keep}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, fi.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate shifts the old files up by one, dropping the oldest, and starts a
+// new file at path.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(fmt.Sprintf("%s.%d", f.path, f.keep)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	for i := f.keep - 1; i >= 1; i-- {
+		if err := os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	if f.keep > 0 {
+		if err := os.Rename(f.path, f.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(f.path); err != nil {
+		return err
+	}
+	return f.open()
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
+
+// activityLog writes the -log file: one JSON object per line, each with
+// the time, level and message of an event and its details as attributes, so
+// that the record of many wipes can be queried. A nil *activityLog discards
+// everything, so callers need not check whether logging is enabled.
+type activityLog struct {
+	file   *rotatingFile
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	phases map[string][2]int // pass and stage last reported for each device
+}
+
+func openActivityLog(path string, maxSize int64, keep int) (*activityLog, error) {
+	file, err := openRotatingFile(path, maxSize, keep)
+	if err != nil {
+		return nil, err
+	}
+	handler := slog.NewJSONHandler(file, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				a.Value = slog.TimeValue(a.Value.Time().UTC())
+			}
+			return a
+		},
+	})
+	return &activityLog{file: file, logger: slog.New(handler), phases: make(map[string][2]int)}, nil
+}
+
+// printf logs a free-form message; multi-line messages such as a wipe
+// summary are joined with semicolons.
+func (l *activityLog) printf(format string, args ...any) {
+	l.event(strings.ReplaceAll(fmt.Sprintf(format, args...), "\n", "; "))
+}
+
+// event logs msg with attrs, given as alternating keys and values.
+func (l *activityLog) event(msg string, attrs ...any) {
+	if l != nil {
+		l.logger.Info(msg, attrs...)
+	}
+}
+
+// failure logs a failed job.
+func (l *activityLog) failure(msg string, attrs ...any) {
+	if l != nil {
+		l.logger.Error(msg, attrs...)
+	}
+}
+
+// progress is a ProgressFunc logging one line per update, preceded by a
+// "pass started", "gap filling started" or "verify started" event whenever
+// a device moves on to the next phase.
+func (l *activityLog) progress(u Progress) {
+	if l == nil {
+		return
+	}
+	phase := [2]int{u.Pass, u.Stage}
+	l.mu.Lock()
+	last, seen := l.phases[u.Device]
+	l.phases[u.Device] = phase
+	l.mu.Unlock()
+	if !seen || last != phase {
+		switch {
+		case u.Stage == 2:
+			l.event("gap filling started", "device", u.Device)
+		case u.Pass > 0:
+			l.event("pass started", "device", u.Device, "pass", u.Pass, "passes", u.Passes)
+		case seen:
+			l.event("verify started", "device", u.Device)
+		}
+	}
+
+	l.event("progress",
+		"device", u.Device,
+		"percent", math.Round(float64(u.BytesProcessed)/float64(u.Total)*10000)/100,
+		"bytes_processed", u.BytesProcessed,
+		"bytes_written", u.BytesWritten,
+		"total", u.Total,
+		"speed_bps", math.Round(u.Speed),
+		"eta", u.ETA.Round(time.Second).String(),
+	)
+}
+
+func (l *activityLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
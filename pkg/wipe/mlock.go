@@ -0,0 +1,22 @@
+package wipe
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+)
+
+// lockBuffer locks buf into RAM so that its contents are never written to
+// swap, and returns a function that unlocks it again. Failing to lock is
+// not fatal: it prints a warning and the wipe goes on unlocked.
+func lockBuffer(buf []byte) (unlock func()) {
+	if err := syscall.Mlock(buf); err != nil {
+		hint := ""
+		if errors.Is(err, syscall.ENOMEM) || errors.Is(err, syscall.EPERM) {
+			hint = fmt.Sprintf("; %s exceeds RLIMIT_MEMLOCK, raise it with ulimit -l or run as root", FormatBytes(int64(len(buf))))
+		}
+		warnf("Cannot lock the write buffers into memory: %v%s", err, hint)
+		return func() {}
+	}
+	return func() { syscall.Munlock(buf) }
+}
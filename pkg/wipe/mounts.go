@@ -0,0 +1,68 @@
+package wipe
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mountInfo describes a device that is currently in use.
+type mountInfo struct {
+	Device     string // /dev path of the disk or partition
+	MountPoint string // empty for swap
+	Swap       bool
+}
+
+func (m mountInfo) String() string {
+	if m.Swap {
+		return fmt.Sprintf("%s is in use as swap", m.Device)
+	}
+	return fmt.Sprintf("%s is mounted on %s", m.Device, m.MountPoint)
+}
+
+// unescapeMountField decodes the octal escapes (\040 for space, ...) used in
+// /proc/mounts.
+func unescapeMountField(field string) string {
+	var b strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+3 < len(field) {
+			var c byte
+			if _, err := fmt.Sscanf(field[i+1:i+4], "%03o", &c); err == nil {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(field[i])
+	}
+	return b.String()
+}
+
+// mountedParts returns the device itself and any of its partitions that are
+// currently mounted or used as swap.
+func mountedParts(device string) ([]mountInfo, error) {
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+	swaps, err := readSwaps()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{device}
+	if partitions, err := diskPartitions(device); err == nil {
+		candidates = append(candidates, partitions...)
+	}
+
+	var inUse []mountInfo
+	for _, candidate := range candidates {
+		name := blockDeviceName(candidate)
+		if mountPoint, ok := mounts[name]; ok {
+			inUse = append(inUse, mountInfo{Device: candidate, MountPoint: mountPoint})
+		}
+		if swaps[name] {
+			inUse = append(inUse, mountInfo{Device: candidate, Swap: true})
+		}
+	}
+	return inUse, nil
+}
@@ -0,0 +1,39 @@
+package wipe
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// readMounts maps the name of every mounted disk or slice to its mount
+// point, from the kernel's list of mounted filesystems.
+func readMounts() (map[string]string, error) {
+	n, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]unix.Statfs_t, n)
+	if n, err = unix.Getfsstat(stats, unix.MNT_NOWAIT); err != nil {
+		return nil, err
+	}
+
+	mounts := make(map[string]string)
+	for _, st := range stats[:n] {
+		source := unix.ByteSliceToString(st.Mntfromname[:])
+		if !strings.HasPrefix(source, "/dev/") {
+			continue
+		}
+		name := blockDeviceName(source)
+		if _, seen := mounts[name]; !seen {
+			mounts[name] = unix.ByteSliceToString(st.Mntonname[:])
+		}
+	}
+	return mounts, nil
+}
+
+// readSwaps returns no devices: macOS swaps to files on the boot volume,
+// which readMounts already reports.
+func readSwaps() (map[string]bool, error) {
+	return map[string]bool{}, nil
+}
@@ -0,0 +1,63 @@
+package wipe
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+const (
+	// procMounts lists the mounted filesystems of the current mount namespace.
+	procMounts = "/proc/mounts"
+
+	// procSwaps lists the active swap areas.
+	procSwaps = "/proc/swaps"
+)
+
+// readMounts maps the kernel name of every mounted block device to its
+// mount point. Sources given as symlinks (/dev/disk/by-uuid/...) are
+// resolved.
+func readMounts() (map[string]string, error) {
+	file, err := os.Open(procMounts)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	mounts := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		name := blockDeviceName(fields[0])
+		if _, seen := mounts[name]; !seen {
+			mounts[name] = unescapeMountField(fields[1])
+		}
+	}
+	return mounts, scanner.Err()
+}
+
+// readSwaps returns the kernel names of the block devices that are active
+// swap areas. Swap files are skipped; they live on a mounted filesystem,
+// which readMounts already reports.
+func readSwaps() (map[string]bool, error) {
+	file, err := os.Open(procSwaps)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	swaps := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[1] != "partition" {
+			continue
+		}
+		swaps[blockDeviceName(unescapeMountField(fields[0]))] = true
+	}
+	return swaps, scanner.Err()
+}
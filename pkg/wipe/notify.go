@@ -0,0 +1,55 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// notifyTimeout bounds how long we wait for the notification helper.
+const notifyTimeout = 5 * time.Second
+
+// sendNotification shows a desktop notification using notify-send on
+// freedesktop systems or osascript on macOS. Failures are ignored: a missing
+// helper or notification daemon must never affect the wipe result.
+func sendNotification(title, message string, failed bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	default:
+		urgency := "normal"
+		if failed {
+			urgency = "critical"
+		}
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=quickwipe", "--urgency="+urgency, title, message)
+	}
+	if _, err := exec.LookPath(cmd.Path); err != nil {
+		return
+	}
+
+	// Under sudo, deliver the notification to the invoking user's session
+	// rather than root's, which usually has no notification daemon.
+	uid, uidErr := strconv.Atoi(os.Getenv("SUDO_UID"))
+	gid, gidErr := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if os.Geteuid() == 0 && uidErr == nil && gidErr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
+		}
+		cmd.Env = os.Environ()
+		if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("DBUS_SESSION_BUS_ADDRESS=unix:path=/run/user/%d/bus", uid))
+		}
+	}
+
+	cmd.Run()
+}
@@ -0,0 +1,269 @@
+package wipe
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// nvmeIoctlAdminCmd is NVME_IOCTL_ADMIN_CMD, _IOWR('N', 0x41, struct
+	// nvme_admin_cmd), and nvmeIoctlID is NVME_IOCTL_ID, _IO('N', 0x40),
+	// which returns the namespace ID of a namespace block device.
+	nvmeIoctlAdminCmd = 0xc0484e41
+	nvmeIoctlID       = 0x4e40
+
+	// Admin command opcodes
+	nvmeAdminGetLogPage = 0x02
+	nvmeAdminIdentify   = 0x06
+	nvmeAdminFormatNVM  = 0x80
+	nvmeAdminSanitize   = 0x84
+
+	// nvmeLogSanitizeStatus is the log page reporting sanitize progress.
+	nvmeLogSanitizeStatus = 0x81
+
+	// Sanitize actions (SANACT) and Format NVM secure erase settings (SES)
+	nvmeSanitizeBlockErase  = 2
+	nvmeSanitizeCryptoErase = 4
+	nvmeFormatUserDataErase = 1
+	nvmeFormatCryptoErase   = 2
+
+	// Sanitize status (SSTAT) values
+	nvmeSanitizeCompleted      = 1
+	nvmeSanitizeInProgress     = 2
+	nvmeSanitizeFailed         = 3
+	nvmeSanitizeCompletedNoDea = 4
+
+	// nvmeFormatTimeout is how long Format NVM, which the kernel runs
+	// synchronously, may take.
+	nvmeFormatTimeout = time.Hour
+
+	// nvmeSanitizePollInterval is how often the sanitize status log is read.
+	nvmeSanitizePollInterval = time.Second
+)
+
+// Wipe methods recorded in Result.Method for NVMe devices.
+const (
+	methodNVMeSanitize = "nvme-sanitize"
+	methodNVMeFormat   = "nvme-format"
+)
+
+// nvmeAdminCmd mirrors struct nvme_passthru_cmd from <linux/nvme_ioctl.h>.
+type nvmeAdminCmd struct {
+	Opcode      uint8
+	Flags       uint8
+	Rsvd1       uint16
+	NSID        uint32
+	Cdw2        uint32
+	Cdw3        uint32
+	Metadata    uint64
+	Addr        uint64
+	MetadataLen uint32
+	DataLen     uint32
+	Cdw10       uint32
+	Cdw11       uint32
+	Cdw12       uint32
+	Cdw13       uint32
+	Cdw14       uint32
+	Cdw15       uint32
+	TimeoutMs   uint32
+	Result      uint32
+}
+
+// isNVMeNamespace reports whether device is a whole NVMe namespace such as
+// /dev/nvme0n1, rather than one of its partitions or another kind of disk.
+func isNVMeNamespace(device string) bool {
+	name := blockDeviceName(device)
+	return strings.HasPrefix(name, "nvme") && parentDiskName(name) == name && !isRegularFile(device)
+}
+
+// nvmeAdmin issues an admin command through file. A non-zero NVMe status is
+// returned as an error; Invalid Opcode and Invalid Field mean the controller
+// doesn't support what was asked and wrap ErrUnsupported.
+func nvmeAdmin(file *os.File, cmd *nvmeAdminCmd) error {
+	status, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(cmd)))
+	if errno != 0 {
+		return classifyErrno(errno)
+	}
+	switch code := status & 0x7ff; code {
+	case 0:
+		return nil
+	case 0x01, 0x02:
+		return fmt.Errorf("%w: NVMe status %#x", ErrUnsupported, code)
+	default:
+		return fmt.Errorf("NVMe status %#x", code)
+	}
+}
+
+// nvmeIdentify reads the 4 KB Identify data structure selected by cns.
+func nvmeIdentify(file *os.File, nsid, cns uint32) ([]byte, error) {
+	data := make([]byte, 4096)
+	err := nvmeAdmin(file, &nvmeAdminCmd{
+		Opcode:  nvmeAdminIdentify,
+		NSID:    nsid,
+		Addr:    uint64(uintptr(unsafe.Pointer(&data[0]))),
+		DataLen: uint32(len(data)),
+		Cdw10:   cns,
+	})
+	runtime.KeepAlive(data)
+	return data, err
+}
+
+// nvmeSanitizeStatus reads the sanitize status log and returns the progress
+// as a fraction and the SSTAT status.
+func nvmeSanitizeStatus(file *os.File) (float64, int, error) {
+	data := make([]byte, 512)
+	err := nvmeAdmin(file, &nvmeAdminCmd{
+		Opcode:  nvmeAdminGetLogPage,
+		NSID:    0xffffffff,
+		Addr:    uint64(uintptr(unsafe.Pointer(&data[0]))),
+		DataLen: uint32(len(data)),
+		Cdw10:   uint32(len(data)/4-1)<<16 | nvmeLogSanitizeStatus,
+	})
+	runtime.KeepAlive(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	progress := float64(binary.LittleEndian.Uint16(data[0:])) / 65536
+	return progress, int(binary.LittleEndian.Uint16(data[2:]) & 0x7), nil
+}
+
+// nvmeErase erases an NVMe namespace with the controller's own erase
+// commands: Sanitize with crypto or block erase if the controller supports
+// it, otherwise Format NVM with secure erase. Sanitize also reaches blocks
+// that are over-provisioned or remapped, and covers every namespace of the
+// controller. Devices that are not NVMe, or support neither, fall back to
+// the normal overwrite.
+func nvmeErase(ctx context.Context, job wipeJob, run runInfo, report ProgressFunc) (Result, error) {
+	result, err := nvmeEraseNamespace(ctx, job, report)
+	if errors.Is(err, ErrUnsupported) {
+		fmt.Printf("%s: %v, overwriting instead\n", job.Device, err)
+		return wipePasses(ctx, job, nil, run, report)
+	}
+	return result, err
+}
+
+func nvmeEraseNamespace(ctx context.Context, job wipeJob, report ProgressFunc) (Result, error) {
+	if !isNVMeNamespace(job.Device) {
+		return Result{}, fmt.Errorf("%w: not a whole NVMe namespace", ErrUnsupported)
+	}
+	file, err := os.Open(job.Device)
+	if err != nil {
+		return Result{}, newDeviceError("open", job.Device, err)
+	}
+	defer file.Close()
+
+	ctrl, err := nvmeIdentify(file, 0, 1)
+	if err != nil {
+		return Result{}, newDeviceError("identify", job.Device, err)
+	}
+	sanicap := binary.LittleEndian.Uint32(ctrl[328:])
+	result := Result{
+		Device:         job.Device,
+		Size:           job.size,
+		BytesProcessed: job.size,
+		Coverage:       coverage{Num: 1, Den: 1},
+	}
+	start := time.Now()
+
+	switch {
+	case sanicap&0x1 != 0:
+		err = nvmeSanitize(ctx, file, job, nvmeSanitizeCryptoErase, report)
+		result.Method = methodNVMeSanitize
+	case sanicap&0x2 != 0:
+		err = nvmeSanitize(ctx, file, job, nvmeSanitizeBlockErase, report)
+		result.Method = methodNVMeSanitize
+	default:
+		ses := uint32(nvmeFormatUserDataErase)
+		if ctrl[524]&0x4 != 0 {
+			ses = nvmeFormatCryptoErase
+		}
+		err = nvmeFormat(file, job, ses)
+		result.Method = methodNVMeFormat
+	}
+	result.Duration = time.Since(start)
+	return result, err
+}
+
+// nvmeSanitize starts a sanitize with action and waits for the controller to
+// finish it. A sanitize can't be stopped once started, so cancelling ctx
+// only stops waiting.
+func nvmeSanitize(ctx context.Context, file *os.File, job wipeJob, action uint32, report ProgressFunc) error {
+	name := map[uint32]string{nvmeSanitizeCryptoErase: "crypto erase", nvmeSanitizeBlockErase: "block erase"}[action]
+	fmt.Printf("Starting NVMe sanitize (%s) of %s; this erases every namespace of the controller\n", name, job.Device)
+	if err := nvmeAdmin(file, &nvmeAdminCmd{Opcode: nvmeAdminSanitize, Cdw10: action}); err != nil {
+		return newDeviceError("sanitize", job.Device, err)
+	}
+
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stopped waiting for the sanitize of %s, which carries on in the controller: %w", job.Device, context.Cause(ctx))
+		case <-time.After(nvmeSanitizePollInterval):
+		}
+
+		progress, status, err := nvmeSanitizeStatus(file)
+		if err != nil {
+			return newDeviceError("sanitize status", job.Device, err)
+		}
+		switch status {
+		case nvmeSanitizeCompleted, nvmeSanitizeCompletedNoDea:
+			return nil
+		case nvmeSanitizeFailed:
+			return newDeviceError("sanitize", job.Device, errors.New("the controller reports that the sanitize failed"))
+		case nvmeSanitizeInProgress:
+			elapsed := time.Since(start)
+			var eta time.Duration
+			if progress > 0 {
+				eta = time.Duration(float64(elapsed) * (1 - progress) / progress)
+			}
+			processed := int64(progress * float64(job.size))
+			report(Progress{
+				Device:         job.Device,
+				BytesProcessed: processed,
+				Total:          job.size,
+				Speed:          float64(processed) / elapsed.Seconds(),
+				ETA:            eta,
+				Coverage:       coverage{Num: 1, Den: 1},
+			})
+		}
+	}
+}
+
+// nvmeFormat formats the namespace with the secure erase setting ses,
+// keeping its current LBA format. The kernel waits for the command to
+// complete.
+func nvmeFormat(file *os.File, job wipeJob, ses uint32) error {
+	nsid, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), nvmeIoctlID, 0)
+	if errno != 0 {
+		return newDeviceError("namespace id", job.Device, errno)
+	}
+	ns, err := nvmeIdentify(file, uint32(nsid), 0)
+	if err != nil {
+		return newDeviceError("identify", job.Device, err)
+	}
+	// FLBAS: format index in bits 3:0 and 6:5, metadata setting in bit 4
+	flbas := uint32(ns[26])
+	cdw10 := flbas&0xf | (flbas>>4&0x1)<<4 | ses<<9 | (flbas>>5&0x3)<<12
+
+	name := map[uint32]string{nvmeFormatCryptoErase: "crypto erase", nvmeFormatUserDataErase: "user data erase"}[ses]
+	fmt.Printf("Formatting %s with NVMe Format NVM (%s)...\n", job.Device, name)
+	err = nvmeAdmin(file, &nvmeAdminCmd{
+		Opcode:    nvmeAdminFormatNVM,
+		NSID:      uint32(nsid),
+		Cdw10:     cdw10,
+		TimeoutMs: uint32(nvmeFormatTimeout / time.Millisecond),
+	})
+	if err != nil {
+		return newDeviceError("format", job.Device, err)
+	}
+	return nil
+}
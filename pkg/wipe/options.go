@@ -0,0 +1,199 @@
+package wipe
+
+import "time"
+
+// Defaults used for unset Options fields and by the command-line flags.
+const (
+	defaultBufferSize       = 4 * 1024 * 1024
+	defaultPipelineDepth    = 2
+	defaultTargetHours      = 20.0
+	defaultProgressInterval = time.Second
+	defaultETAWindow        = 30
+	defaultBenchmarkSize    = 10 << 30
+)
+
+// Options holds every tunable of how a device is overwritten, so that a
+// caller fills in one value instead of passing a long list of parameters.
+// The zero value is valid and means a full single-pass wipe with random data
+// from a ChaCha8 stream in 4 MB buffers. The context and the progress
+// callback are passed alongside it to Wipe, as usual in Go.
+type Options struct {
+	BufferSize int     `json:"buffer"`
+	SkipFactor int     `json:"skip"`
+	Coverage   float64 `json:"coverage,omitempty"` // fraction of blocks to write, instead of a SkipFactor
+
+	// SkipMode is how a partial wipe picks the blocks it writes: "stride"
+	// (the default) or "random". SkipSeed seeds the random choice;
+	// zero picks a seed when the job is prepared.
+	SkipMode      string  `json:"skip_mode,omitempty"`
+	SkipSeed      uint64  `json:"skip_seed,omitempty"`
+	Pattern       string  `json:"pattern"`
+	Verify        bool    `json:"verify"`                  // read written blocks back after the wipe
+	RNG           string  `json:"rng"`                     // random data source for the random pattern
+	RandomRefresh int     `json:"random_refresh"`          // regenerate random data every Nth write
+	DiscardVerify bool    `json:"discard_verify"`          // discard first, overwrite only if it doesn't read back as zeros
+	Discard       bool    `json:"discard,omitempty"`       // discard instead of overwriting
+	DiscardFirst  bool    `json:"discard_first,omitempty"` // discard, then overwrite as usual
+	AutoSkip      bool    `json:"auto_skip"`
+	TargetHours   float64 `json:"target_hours"`
+
+	SecureRandomZero bool `json:"secure_random_zero"` // random pass, zero pass, then verify zeros
+	Passes           int  `json:"passes,omitempty"`   // overwrite passes with the pattern
+
+	// Scheme names a standard sequence of passes, such as "dod", that
+	// replaces Pattern and Passes.
+	Scheme string `json:"scheme,omitempty"`
+
+	// PatternFile holds the data written by the file pattern, repeated
+	// across the device; "-" reads it from standard input.
+	PatternFile string `json:"pattern_file,omitempty"`
+
+	Digest        string `json:"digest"`         // block checksum for random data
+	Mlock         bool   `json:"mlock"`          // keep the write buffers out of swap
+	PipelineDepth int    `json:"pipeline_depth"` // buffers filled ahead of the writer
+
+	// ReopenWait is how long to wait for a device that disappears mid-wipe
+	// to come back before giving up; zero fails at once.
+	ReopenWait time.Duration `json:"reopen_wait,omitempty"`
+
+	// Retries is how often a block that fails to write is retried before it
+	// is skipped; zero fails the wipe at the first write error.
+	Retries int `json:"retries,omitempty"`
+
+	// NVMeSanitize erases NVMe namespaces with the controller's Sanitize or
+	// Format NVM command instead of overwriting them.
+	NVMeSanitize bool `json:"nvme_sanitize,omitempty"`
+
+	// ATASecureErase erases SATA drives with ATA SECURITY ERASE UNIT instead
+	// of overwriting them.
+	ATASecureErase bool `json:"ata_secure_erase,omitempty"`
+
+	// VerifySample reads back this many randomly chosen written blocks
+	// instead of all of them when verifying; zero verifies every block.
+	VerifySample int `json:"verify_sample,omitempty"`
+
+	// VerifyInline reads every block back as soon as it is written and
+	// compares it with the buffer it was written from, instead of verifying
+	// in a separate pass afterwards.
+	VerifyInline bool `json:"verify_inline,omitempty"`
+
+	// EntropyCheck measures the entropy of random data as it is read back
+	// and warns about blocks that look too regular to be random.
+	EntropyCheck bool `json:"entropy_check,omitempty"`
+
+	// NoExcl opens block devices without O_EXCL, so that the kernel doesn't
+	// refuse devices that are mounted or claimed by md or LVM.
+	NoExcl bool `json:"no_excl,omitempty"`
+
+	// NoDirect writes through the page cache instead of trying direct I/O
+	// first, for storage stacks where O_DIRECT misbehaves.
+	NoDirect bool `json:"no_direct,omitempty"`
+
+	// ProgressInterval is how often progress is reported while wiping and
+	// verifying.
+	ProgressInterval time.Duration `json:"progress_interval,omitempty"`
+
+	// ETAWindow is how many progress updates the speed behind the ETA is
+	// averaged over.
+	ETAWindow int `json:"eta_window,omitempty"`
+
+	// FillGaps goes back over the blocks a partial wipe skipped once its
+	// passes are done, for as long as TargetHours allows.
+	FillGaps bool `json:"fill_gaps,omitempty"`
+
+	// SyncMode is how writes are made durable: "osync" (the default),
+	// "fdatasync" or "none". SyncEvery is the number of buffers between
+	// flushes for "fdatasync".
+	SyncMode  string `json:"sync_mode,omitempty"`
+	SyncEvery int    `json:"sync_every,omitempty"`
+
+	// Rate caps the write throughput in bytes per second; zero is unlimited.
+	Rate int64 `json:"rate,omitempty"`
+
+	// MaxDuration stops the job once it has run this long, like an
+	// interruption; zero is unlimited.
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+
+	// BenchmarkSize is how much the -auto-skip write benchmark writes, at
+	// most a quarter of small devices; zero means 10 GB.
+	// With BenchmarkTime the benchmark instead writes until that much time
+	// has passed, or BenchmarkSize if that is set and comes first.
+	BenchmarkSize int64         `json:"benchmark_size,omitempty"`
+	BenchmarkTime time.Duration `json:"benchmark_time,omitempty"`
+
+	// BenchmarkRegions runs the write benchmark at the start, middle and
+	// end of the device and bases auto-skip on their "average" or "worst";
+	// empty only benchmarks the start.
+	BenchmarkRegions string `json:"benchmark_regions,omitempty"`
+
+	// BenchmarkCacheAge is how long a write speed measured for a drive is
+	// reused by later runs instead of benchmarking it again; zero doesn't
+	// use the cache. Rebenchmark measures anyway and refreshes the cache.
+	BenchmarkCacheAge time.Duration `json:"benchmark_cache_age,omitempty"`
+	Rebenchmark       bool          `json:"rebenchmark,omitempty"`
+
+	// WriteZeroes leaves whole-device zero passes to the disk with
+	// BLKZEROOUT when it supports write-zeroes offload.
+	WriteZeroes bool `json:"write_zeroes,omitempty"`
+
+	// Checkpoint is the file kept up to date while wiping, which an
+	// interrupted wipe can be resumed from. The command defaults it to
+	// quickwipe-<device>.checkpoint in the working directory; Wipe writes
+	// none unless it is set.
+	Checkpoint string `json:"checkpoint,omitempty"`
+
+	// Force makes Wipe skip its safety checks, as -force does: devices that
+	// are mounted, in use or swap, and paths outside /dev/ such as image
+	// files, are wiped with a warning instead of refused. It is never read
+	// from a job submitted to the daemon.
+	Force bool `json:"-"`
+}
+
+// withDefaults returns o with every unset field replaced by its default.
+func (o Options) withDefaults() Options {
+	if o.BufferSize == 0 {
+		o.BufferSize = defaultBufferSize
+	}
+	if o.SkipFactor == 0 {
+		o.SkipFactor = 1
+	}
+	if o.SkipMode == "" {
+		o.SkipMode = skipModeStride
+	}
+	if o.Passes == 0 {
+		o.Passes = 1
+	}
+	if o.Pattern == "" && o.PatternFile != "" {
+		o.Pattern = patternFile
+	} else if o.Pattern == "" {
+		o.Pattern = patternRandom
+	}
+	if o.RNG == "" {
+		o.RNG = rngChaCha
+	}
+	if o.RandomRefresh == 0 {
+		o.RandomRefresh = 1
+	}
+	if o.TargetHours == 0 {
+		o.TargetHours = defaultTargetHours
+	}
+	if o.Digest == "" {
+		o.Digest = digestSHA256
+	}
+	if o.PipelineDepth == 0 {
+		o.PipelineDepth = defaultPipelineDepth
+	}
+	if o.ProgressInterval == 0 {
+		o.ProgressInterval = defaultProgressInterval
+	}
+	if o.ETAWindow == 0 {
+		o.ETAWindow = defaultETAWindow
+	}
+	if o.SyncMode == "" {
+		o.SyncMode = syncOSync
+	}
+	if o.SyncEvery == 0 {
+		o.SyncEvery = defaultSyncEvery
+	}
+	return o
+}
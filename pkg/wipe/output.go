@@ -0,0 +1,86 @@
+package wipe
+
+import (
+	"fmt"
+	"os"
+)
+
+// Color modes selectable with -color.
+const (
+	colorAuto   = "auto"   // color when stdout is a terminal and NO_COLOR is unset
+	colorAlways = "always" // color even when redirected
+	colorNever  = "never"
+)
+
+// ANSI escape sequences for the few colors quickwipe uses.
+const (
+	ansiRed    = "\033[31m"
+	ansiYellow = "\033[33m"
+	ansiGreen  = "\033[32m"
+	ansiReset  = "\033[0m"
+)
+
+// colorEnabled is set once by setColorMode before any output is colored.
+var colorEnabled bool
+
+// debugEnabled is set by -debug to print what the I/O path is set up with.
+var debugEnabled bool
+
+// setColorMode decides whether messages are colored. In auto mode they are
+// only on a terminal and only if NO_COLOR (https://no-color.org) is unset or
+// empty, so escape codes never end up in redirected logs.
+func setColorMode(mode string) error {
+	switch mode {
+	case colorAuto:
+		colorEnabled = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	case colorAlways:
+		colorEnabled = true
+	case colorNever:
+		colorEnabled = false
+	default:
+		return fmt.Errorf("unknown color mode %q (want auto, always or never)", mode)
+	}
+	return nil
+}
+
+// paint wraps s in an ANSI color if color is enabled.
+func paint(color, s string) string {
+	if !colorEnabled {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// warnf prints a warning line, in yellow on a color terminal.
+func warnf(format string, args ...any) {
+	fmt.Println(paint(ansiYellow, "Warning: "+fmt.Sprintf(format, args...)))
+}
+
+// errorf prints an error line, in red on a color terminal.
+func errorf(format string, args ...any) {
+	fmt.Println(paint(ansiRed, "Error: "+fmt.Sprintf(format, args...)))
+}
+
+// dangerf prints a line warning about data loss, in red on a color terminal.
+func dangerf(format string, args ...any) {
+	fmt.Println(paint(ansiRed, fmt.Sprintf(format, args...)))
+}
+
+// successf prints a line announcing success, in green on a color terminal.
+func successf(format string, args ...any) {
+	fmt.Println(paint(ansiGreen, fmt.Sprintf(format, args...)))
+}
+
+// debugf prints a -debug line to stderr, so that it can be kept apart from
+// the rest of the output. On a terminal it first clears the progress line it
+// interrupts; the next update redraws it.
+func debugf(format string, args ...any) {
+	if !debugEnabled {
+		return
+	}
+	prefix := ""
+	if isTerminal(os.Stderr) {
+		prefix = "\r\033[K"
+	}
+	fmt.Fprintln(os.Stderr, prefix+"Debug: "+fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,360 @@
+package wipe
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"slices"
+	"syscall"
+)
+
+// blkrrpart is the BLKRRPART ioctl, which makes the kernel re-read a disk's
+// partition table.
+const blkrrpart = 0x125f
+
+// gptSignature starts every GPT header.
+var gptSignature = []byte("EFI PART")
+
+// errNoPartitionTable is returned when -preserve-partition-table is given
+// for a disk without an MBR or GPT.
+var errNoPartitionTable = errors.New("no MBR or GPT partition table found")
+
+// savedRegion is a byte range of the device kept across a wipe.
+type savedRegion struct {
+	Offset int64  `json:"offset"`
+	Data   []byte `json:"data"`
+}
+
+// savePartitionTable reads the partition table of the disk at path: the MBR
+// and, on GPT disks, the primary and backup GPT headers with their entry
+// arrays. Nothing else is saved, so no partition contents survive the wipe.
+func savePartitionTable(path string, size int64) ([]savedRegion, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	mbr := make([]byte, 512)
+	if _, err := file.ReadAt(mbr, 0); err != nil {
+		return nil, newDeviceError("read", path, err)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return nil, errNoPartitionTable
+	}
+
+	// The primary GPT header is in LBA 1; probe the common sector sizes
+	for _, sectorSize := range []int64{512, 4096} {
+		header := make([]byte, 512)
+		if _, err := file.ReadAt(header, sectorSize); err != nil || !bytes.HasPrefix(header, gptSignature) {
+			continue
+		}
+		if !gptHeaderValid(header) {
+			return nil, fmt.Errorf("primary GPT header of %s is corrupt", path)
+		}
+
+		primary, err := readGPT(file, header, sectorSize, size)
+		if err != nil {
+			return nil, newDeviceError("read", path, err)
+		}
+		// Keep everything from the MBR to the end of the entry array
+		head := make([]byte, primary.end)
+		if _, err := file.ReadAt(head, 0); err != nil {
+			return nil, newDeviceError("read", path, err)
+		}
+
+		alternate := int64(binary.LittleEndian.Uint64(header[32:])) * sectorSize
+		backupHeader := make([]byte, 512)
+		if _, err := file.ReadAt(backupHeader, alternate); err != nil {
+			return nil, newDeviceError("read", path, err)
+		}
+		if !bytes.HasPrefix(backupHeader, gptSignature) || !gptHeaderValid(backupHeader) {
+			warnf("Backup GPT header of %s is missing or corrupt, only the primary table is preserved", path)
+			return []savedRegion{{Offset: 0, Data: head}}, nil
+		}
+		backup, err := readGPT(file, backupHeader, sectorSize, size)
+		if err != nil {
+			return nil, newDeviceError("read", path, err)
+		}
+		return []savedRegion{{Offset: 0, Data: head}, backup.region}, nil
+	}
+
+	// Plain MBR
+	return []savedRegion{{Offset: 0, Data: mbr}}, nil
+}
+
+// gptTable locates one copy of a GPT: the header sector and its entry array.
+type gptTable struct {
+	region savedRegion // covering both header and entries
+	end    int64       // offset just past the entry array
+}
+
+// readGPT reads the entry array described by header together with the
+// header sector itself and returns the smallest region covering both.
+func readGPT(file *os.File, header []byte, sectorSize, size int64) (gptTable, error) {
+	headerOffset := int64(binary.LittleEndian.Uint64(header[24:])) * sectorSize
+	entriesOffset := int64(binary.LittleEndian.Uint64(header[72:])) * sectorSize
+	entriesSize := int64(binary.LittleEndian.Uint32(header[80:])) * int64(binary.LittleEndian.Uint32(header[84:]))
+	entriesEnd := entriesOffset + (entriesSize+sectorSize-1)/sectorSize*sectorSize
+
+	start, end := min(headerOffset, entriesOffset), max(headerOffset+sectorSize, entriesEnd)
+	if start < 0 || end > size || end-start > 1<<20 {
+		return gptTable{}, fmt.Errorf("GPT at offset %d describes an implausible layout", headerOffset)
+	}
+
+	data := make([]byte, end-start)
+	if _, err := file.ReadAt(data, start); err != nil {
+		return gptTable{}, err
+	}
+	entries := data[entriesOffset-start : entriesOffset-start+entriesSize]
+	if crc32.ChecksumIEEE(entries) != binary.LittleEndian.Uint32(header[88:]) {
+		return gptTable{}, fmt.Errorf("GPT entry array at offset %d fails its checksum", entriesOffset)
+	}
+	return gptTable{region: savedRegion{Offset: start, Data: data}, end: end}, nil
+}
+
+// gptHeaderValid checks the header's own CRC32.
+func gptHeaderValid(header []byte) bool {
+	headerSize := int(binary.LittleEndian.Uint32(header[12:]))
+	if headerSize < 92 || headerSize > len(header) {
+		return false
+	}
+	check := bytes.Clone(header[:headerSize])
+	clear(check[16:20])
+	return crc32.ChecksumIEEE(check) == binary.LittleEndian.Uint32(header[16:])
+}
+
+// restorePartitionTable writes the saved regions back, reads them again to
+// make sure they landed, and asks the kernel to re-read the partition table.
+func restorePartitionTable(path string, regions []savedRegion) error {
+	file, err := os.OpenFile(path, os.O_RDWR|syscall.O_SYNC, 0)
+	if err != nil {
+		return newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	for _, region := range regions {
+		if _, err := file.WriteAt(region.Data, region.Offset); err != nil {
+			return newWriteError(region.Offset, err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return newDeviceError("sync", path, err)
+	}
+
+	for _, region := range regions {
+		readBack := make([]byte, len(region.Data))
+		if _, err := file.ReadAt(readBack, region.Offset); err != nil {
+			return newDeviceError("read", path, err)
+		}
+		if !bytes.Equal(readBack, region.Data) {
+			return fmt.Errorf("restored partition table does not read back correctly at offset %d", region.Offset)
+		}
+	}
+
+	if fi, err := file.Stat(); err == nil && fi.Mode()&os.ModeDevice != 0 {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkrrpart, 0); errno != 0 {
+			warnf("Kernel did not re-read the partition table of %s: %v", path, errno)
+		}
+	}
+	return nil
+}
+
+// partitionExtent is where the contents of one partition lie on its disk,
+// as the partition table describes them.
+type partitionExtent struct {
+	Number int   // as in /dev/sdb1; logical MBR partitions count from 5
+	Start  int64 // in bytes
+	Length int64
+}
+
+func (e partitionExtent) String() string {
+	return fmt.Sprintf("partition %d (%s at offset %d)", e.Number, FormatBytes(e.Length), e.Start)
+}
+
+// mbrExtendedTypes are the MBR partition types of extended partitions,
+// which hold a chain of extended boot records (EBRs), one in front of each
+// logical partition.
+var mbrExtendedTypes = map[byte]bool{0x05: true, 0x0f: true, 0x85: true}
+
+// maxLogicalPartitions bounds the EBR chain, which a corrupt disk could
+// turn into a loop.
+const maxLogicalPartitions = 128
+
+// readPartitionExtents lists the partitions in the GPT or MBR of the disk
+// at path, ordered by their start. Only the partition entries are read:
+// the extents never include the table itself, the EBRs in front of logical
+// partitions or the unpartitioned space between them.
+func readPartitionExtents(path string, size int64) ([]partitionExtent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	mbr := make([]byte, 512)
+	if _, err := file.ReadAt(mbr, 0); err != nil {
+		return nil, newDeviceError("read", path, err)
+	}
+	if mbr[510] != 0x55 || mbr[511] != 0xaa {
+		return nil, errNoPartitionTable
+	}
+
+	var extents []partitionExtent
+	gpt := false
+	for _, sectorSize := range []int64{512, 4096} {
+		header := make([]byte, 512)
+		if _, err := file.ReadAt(header, sectorSize); err != nil || !bytes.HasPrefix(header, gptSignature) {
+			continue
+		}
+		if !gptHeaderValid(header) {
+			return nil, fmt.Errorf("primary GPT header of %s is corrupt", path)
+		}
+		table, err := readGPT(file, header, sectorSize, size)
+		if err != nil {
+			return nil, newDeviceError("read", path, err)
+		}
+		if extents, err = gptExtents(header, table, sectorSize); err != nil {
+			return nil, fmt.Errorf("GPT of %s: %w", path, err)
+		}
+		gpt = true
+		break
+	}
+	if !gpt {
+		sectorSize := int64(512)
+		if logical, _, ok := sectorSizes(file); ok && logical > 0 {
+			sectorSize = int64(logical)
+		}
+		if extents, err = mbrExtents(file, mbr, sectorSize); err != nil {
+			return nil, fmt.Errorf("MBR of %s: %w", path, err)
+		}
+	}
+
+	slices.SortFunc(extents, func(a, b partitionExtent) int { return cmp.Compare(a.Start, b.Start) })
+	for i, e := range extents {
+		if e.Start < 512 || e.Start+e.Length > size {
+			return nil, fmt.Errorf("%s of %s lies outside the disk", e, path)
+		}
+		if i > 0 && extents[i-1].Start+extents[i-1].Length > e.Start {
+			return nil, fmt.Errorf("partitions %d and %d of %s overlap", extents[i-1].Number, e.Number, path)
+		}
+	}
+	return extents, nil
+}
+
+// gptExtents lists the used entries of the GPT read by readGPT, which must
+// lie within the usable area the header gives.
+func gptExtents(header []byte, table gptTable, sectorSize int64) ([]partitionExtent, error) {
+	firstUsable := int64(binary.LittleEndian.Uint64(header[40:])) * sectorSize
+	lastUsable := (int64(binary.LittleEndian.Uint64(header[48:])) + 1) * sectorSize
+	entriesOffset := int64(binary.LittleEndian.Uint64(header[72:])) * sectorSize
+	count := int(binary.LittleEndian.Uint32(header[80:]))
+	entrySize := int(binary.LittleEndian.Uint32(header[84:]))
+	if entrySize < 128 {
+		return nil, fmt.Errorf("invalid entry size %d", entrySize)
+	}
+
+	entries := table.region.Data[entriesOffset-table.region.Offset:]
+	var extents []partitionExtent
+	for i := range count {
+		entry := entries[i*entrySize : (i+1)*entrySize]
+		if bytes.Equal(entry[:16], make([]byte, 16)) {
+			continue // unused: no partition type
+		}
+		first := int64(binary.LittleEndian.Uint64(entry[32:]))
+		last := int64(binary.LittleEndian.Uint64(entry[40:]))
+		e := partitionExtent{Number: i + 1, Start: first * sectorSize, Length: (last - first + 1) * sectorSize}
+		if last < first || e.Start < firstUsable || e.Start+e.Length > lastUsable {
+			return nil, fmt.Errorf("partition %d lies outside the usable area", e.Number)
+		}
+		extents = append(extents, e)
+	}
+	return extents, nil
+}
+
+// mbrExtents lists the primary partitions of mbr and the logical ones in
+// its extended partition.
+func mbrExtents(file *os.File, mbr []byte, sectorSize int64) ([]partitionExtent, error) {
+	var extents []partitionExtent
+	for i := range 4 {
+		entry := mbr[446+16*i:]
+		kind := entry[4]
+		start := int64(binary.LittleEndian.Uint32(entry[8:]))
+		count := int64(binary.LittleEndian.Uint32(entry[12:]))
+		switch {
+		case kind == 0 || count == 0:
+			continue
+		case kind == 0xee:
+			return nil, errors.New("protective MBR without a GPT header")
+		case mbrExtendedTypes[kind]:
+			logical, err := ebrExtents(file, start, sectorSize)
+			if err != nil {
+				return nil, err
+			}
+			extents = append(extents, logical...)
+		default:
+			extents = append(extents, partitionExtent{Number: i + 1, Start: start * sectorSize, Length: count * sectorSize})
+		}
+	}
+	return extents, nil
+}
+
+// ebrExtents follows the chain of EBRs of the extended partition starting
+// at LBA extended. Each EBR describes the logical partition after it,
+// relative to itself, and links to the next EBR, relative to the extended
+// partition.
+func ebrExtents(file *os.File, extended, sectorSize int64) ([]partitionExtent, error) {
+	var extents []partitionExtent
+	ebr := make([]byte, 512)
+	for lba := extended; len(extents) < maxLogicalPartitions; {
+		if _, err := file.ReadAt(ebr, lba*sectorSize); err != nil {
+			return nil, err
+		}
+		if ebr[510] != 0x55 || ebr[511] != 0xaa {
+			return nil, fmt.Errorf("invalid EBR at offset %d", lba*sectorSize)
+		}
+		start := int64(binary.LittleEndian.Uint32(ebr[446+8:]))
+		count := int64(binary.LittleEndian.Uint32(ebr[446+12:]))
+		if ebr[446+4] != 0 && count > 0 {
+			if start == 0 {
+				return nil, fmt.Errorf("logical partition %d overlaps its EBR", len(extents)+5)
+			}
+			extents = append(extents, partitionExtent{Number: len(extents) + 5, Start: (lba + start) * sectorSize, Length: count * sectorSize})
+		}
+		next := int64(binary.LittleEndian.Uint32(ebr[462+8:]))
+		if ebr[462+4] == 0 || next == 0 {
+			return extents, nil
+		}
+		lba = extended + next
+	}
+	return nil, fmt.Errorf("more than %d logical partitions", maxLogicalPartitions)
+}
+
+// findExtents reads the partitions -preserve-table wipes into job.extents
+// and lists them. Direct I/O needs each one to start on a block boundary.
+func findExtents(job *wipeJob) error {
+	extents, err := readPartitionExtents(job.Device, job.size)
+	if err != nil {
+		return fmt.Errorf("cannot read partition table: %w", err)
+	}
+	if len(extents) == 0 {
+		return fmt.Errorf("the partition table of %s lists no partitions to wipe", job.Device)
+	}
+	blockSize := int64(deviceBlockSize(job.Device))
+	for _, e := range extents {
+		if e.Start%blockSize != 0 {
+			return fmt.Errorf("%s of %s is not aligned to its %d-byte blocks", e, job.Device, blockSize)
+		}
+	}
+
+	fmt.Printf("Wiping %d partitions of %s, keeping the partition table:\n", len(extents), job.Device)
+	for _, e := range extents {
+		fmt.Printf("  %s\n", e)
+	}
+	job.extents = extents
+	return nil
+}
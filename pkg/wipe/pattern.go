@@ -0,0 +1,206 @@
+package wipe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Wipe patterns selectable with -pattern.
+const (
+	patternRandom  = "random"  // fresh random data, the default
+	patternZero    = "zero"    // all zero bytes
+	patternOne     = "one"     // all one bits (0xFF bytes)
+	patternCounter = "counter" // every sector holds its own LBA, for diagnostics
+	patternPRBS7   = "prbs7"   // PRBS-7 test sequence, x^7 + x^6 + 1
+	patternPRBS15  = "prbs15"  // PRBS-15 test sequence, x^15 + x^14 + 1
+	patternPRBS    = "prbs"    // alias for prbs15
+	patternFile    = "file"    // the contents of -pattern-file, repeated
+)
+
+// Named overwrite schemes selectable with -scheme, as the patterns of their
+// passes in order. Schemes are always verified after the last pass.
+const schemeDoD = "dod" // DoD 5220.22-M: a byte, its complement, then random data
+
+var schemePasses = map[string][]string{
+	schemeDoD: {patternZero, patternOne, patternRandom},
+}
+
+// schemeNames describes the schemes for messages.
+var schemeNames = map[string]string{
+	schemeDoD: "DoD 5220.22-M",
+}
+
+// counterSectorSize is the unit the counter pattern stamps with its LBA. It
+// is the smallest logical block size in use, so the stamp is meaningful
+// whatever the device reports.
+const counterSectorSize = 512
+
+// validPattern reports whether name is a known pattern or a fixed byte.
+func validPattern(name string) bool {
+	switch name {
+	case patternRandom, patternZero, patternOne, patternCounter, patternPRBS7, patternPRBS15, patternPRBS, patternFile:
+		return true
+	}
+	_, ok := fixedByte(name)
+	return ok
+}
+
+// fixedByte returns the byte value of a hex byte pattern such as 0xAA.
+func fixedByte(name string) (byte, bool) {
+	if len(name) != 4 || (name[:2] != "0x" && name[:2] != "0X") {
+		return 0, false
+	}
+	b, err := strconv.ParseUint(name[2:], 16, 8)
+	return byte(b), err == nil
+}
+
+// deterministicPattern reports whether the pattern can be regenerated from
+// the offset alone, which is what makes read-back verification possible.
+func deterministicPattern(name string) bool {
+	return name != patternRandom
+}
+
+// staticPattern reports whether every block of the pattern has the same
+// content, so that the buffer only needs to be filled once.
+func staticPattern(name string) bool {
+	_, fixed := fixedByte(name)
+	return name == patternZero || name == patternOne || fixed
+}
+
+// fillPattern fills buf with the content job's pattern has at offset off,
+// which is relative to the job's window, if any; the pattern itself follows
+// device offsets. Random data comes from the job's random source.
+func fillPattern(job wipeJob, buf []byte, off int64) error {
+	off = job.physical(off)
+	switch job.Pattern {
+	case patternRandom:
+		return fillRandom(job.RNG, buf)
+	case patternZero:
+		clear(buf)
+	case patternOne:
+		fillByte(buf, 0xff)
+	case patternCounter:
+		fillCounter(buf, off)
+	case patternPRBS7:
+		fillPeriodic(buf, off, prbs7Table())
+	case patternPRBS15, patternPRBS:
+		fillPeriodic(buf, off, prbs15Table())
+	case patternFile:
+		if len(job.patternData) == 0 {
+			return fmt.Errorf("pattern file %s not loaded", job.PatternFile)
+		}
+		fillPeriodic(buf, off, job.patternData)
+	default:
+		b, ok := fixedByte(job.Pattern)
+		if !ok {
+			return fmt.Errorf("unknown pattern %q", job.Pattern)
+		}
+		fillByte(buf, b)
+	}
+	return nil
+}
+
+// fillByte sets every byte of buf to b.
+func fillByte(buf []byte, b byte) {
+	if len(buf) == 0 {
+		return
+	}
+	buf[0] = b
+	for filled := 1; filled < len(buf); filled *= 2 {
+		copy(buf[filled:], buf[:filled])
+	}
+}
+
+// fillCounter repeats each sector's LBA as a little-endian 64-bit word
+// throughout the sector. A sector that reads back with another LBA reveals a
+// misdirected write or an address-decoding fault.
+func fillCounter(buf []byte, off int64) {
+	var word [8]byte
+	for i := 0; i < len(buf); {
+		pos := off + int64(i)
+		binary.LittleEndian.PutUint64(word[:], uint64(pos/counterSectorSize))
+		end := min(len(buf), i+int(counterSectorSize-pos%counterSectorSize))
+		for ; i < end; i++ {
+			buf[i] = word[(off+int64(i))%8]
+		}
+	}
+}
+
+// PRBS byte tables, one full period each. The sequences are generated
+// continuously across the device: the byte at offset off is table[off %
+// len(table)]. A maximal n-bit LFSR repeats every 2^n-1 bits, which is odd,
+// so packing it into bytes repeats every 2^n-1 bytes.
+var (
+	prbs7Table  = sync.OnceValue(func() []byte { return prbsSequence(7, 6) })
+	prbs15Table = sync.OnceValue(func() []byte { return prbsSequence(15, 14) })
+)
+
+// prbsSequence returns one period of the PRBS generated by the Fibonacci
+// LFSR with feedback taps at bits order and tap, packed MSB first. The
+// register starts with all ones, as is customary for test equipment.
+func prbsSequence(order, tap uint) []byte {
+	period := 1<<order - 1
+	mask := uint32(period)
+	state := mask
+
+	seq := make([]byte, period)
+	for i := range seq {
+		var b byte
+		for range 8 {
+			bit := (state>>(order-1) ^ state>>(tap-1)) & 1
+			state = (state<<1 | bit) & mask
+			b = b<<1 | byte(bit)
+		}
+		seq[i] = b
+	}
+	return seq
+}
+
+// fillPeriodic fills buf with the repeating table, aligned so that the data
+// depends only on the device offset.
+func fillPeriodic(buf []byte, off int64, table []byte) {
+	start := int(off % int64(len(table)))
+	n := copy(buf, table[start:])
+	for n < len(buf) {
+		n += copy(buf[n:], table)
+	}
+}
+
+// maxPatternFileSize caps -pattern-file, which is held in memory.
+const maxPatternFileSize = 256 << 20
+
+// stdinPattern reads standard input for "-pattern-file -" once, so that
+// every device of a run gets the same data.
+var stdinPattern = sync.OnceValues(func() ([]byte, error) {
+	return io.ReadAll(io.LimitReader(os.Stdin, maxPatternFileSize+1))
+})
+
+// readPatternFile reads the pattern repeated by patternFile from path, or
+// from standard input if path is "-".
+func readPatternFile(path string) ([]byte, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = stdinPattern()
+	} else {
+		var file *os.File
+		if file, err = os.Open(path); err == nil {
+			data, err = io.ReadAll(io.LimitReader(file, maxPatternFileSize+1))
+			file.Close()
+		}
+	}
+	switch {
+	case err != nil:
+		return nil, err
+	case len(data) == 0:
+		return nil, errors.New("is empty")
+	case len(data) > maxPatternFileSize:
+		return nil, fmt.Errorf("is larger than %s", FormatBytes(maxPatternFileSize))
+	}
+	return data, nil
+}
@@ -0,0 +1,99 @@
+package wipe
+
+import "context"
+
+// bufferRing is a fixed set of aligned buffers cycled between the producer,
+// which fills them, and the writer. Memory stays at depth buffers however far
+// the producer could run ahead, and nothing is allocated per block.
+type bufferRing struct {
+	slab    []byte   // all buffers in one allocation, for -mlock
+	buffers [][]byte // in the order they are first handed out
+	free    chan []byte
+}
+
+func newBufferRing(depth, size int) (*bufferRing, error) {
+	slab, err := AllocAlignedBuffer(depth * size)
+	if err != nil {
+		return nil, err
+	}
+	ring := &bufferRing{slab: slab, free: make(chan []byte, depth)}
+	for i := range depth {
+		buf := slab[i*size : (i+1)*size : (i+1)*size]
+		ring.buffers = append(ring.buffers, buf)
+		ring.free <- buf
+	}
+	return ring, nil
+}
+
+// release frees the buffers once nothing uses them any more.
+func (r *bufferRing) release() {
+	FreeAlignedBuffer(r.slab)
+}
+
+// pipelineBlock is one write prepared by the producer.
+type pipelineBlock struct {
+	buf    []byte // ring buffer holding the data; buf[:length] is written
+	length int64
+	offset int64
+	sum    [32]byte // digest of the data, when digests are recorded
+	err    error
+
+	// Where the wipe stands once the block is written: the offset of the
+	// following block, past any skipped blocks, and the selector state
+	// matching it. The writer checkpoints these.
+	next          int64
+	selectorState int64
+}
+
+// produceBlocks fills ring buffers for the blocks from offset to the end of
+// the device and sends them to blocks, in order. It closes blocks when done,
+// or early when ctx is cancelled or a fill fails; a failed block carries the
+// error and is the last one sent.
+func produceBlocks(ctx context.Context, job wipeJob, ring *bufferRing, offset int64, selector *blockSelector, recordDigests bool, blocks chan<- pipelineBlock) {
+	defer close(blocks)
+
+	cov, size := selector.coverage, job.size
+	for writes := 0; offset < size; writes++ {
+		var buf []byte
+		select {
+		case buf = <-ring.free:
+		case <-ctx.Done():
+			return
+		}
+
+		block := pipelineBlock{buf: buf, length: min(int64(job.BufferSize), size-offset), offset: offset}
+		data := buf[:block.length]
+
+		// Static patterns were filled when the ring was set up. Random data
+		// is reused between refreshes, but every buffer is filled on its
+		// first use. Anything else depends on the offset.
+		switch {
+		case staticPattern(job.Pattern):
+		case job.Pattern == patternRandom:
+			if writes%job.RandomRefresh == 0 || writes < len(ring.buffers) {
+				block.err = fillPattern(job, data, offset)
+			}
+		default:
+			block.err = fillPattern(job, data, offset)
+		}
+		if recordDigests && block.err == nil {
+			block.sum = blockSum(job.Digest, data)
+		}
+
+		// Skip blocks if only part of the device is to be covered
+		offset += block.length
+		if !cov.full() && offset < size {
+			offset = min(size, offset+int64(job.BufferSize)*selector.skipRun())
+		}
+		block.next, block.selectorState = offset, selector.acc
+
+		select {
+		case blocks <- block:
+		case <-ctx.Done():
+			return
+		}
+		if block.err != nil {
+			return
+		}
+	}
+}
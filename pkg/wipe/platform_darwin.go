@@ -0,0 +1,120 @@
+package wipe
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// dkiocGetBlockSize, dkiocGetBlockCount and dkiocGetPhysicalBlockSize
+	// are the DKIOCGETBLOCKSIZE, DKIOCGETBLOCKCOUNT and
+	// DKIOCGETPHYSICALBLOCKSIZE ioctls from <sys/disk.h>.
+	dkiocGetBlockSize         = 0x40046418
+	dkiocGetBlockCount        = 0x40086419
+	dkiocGetPhysicalBlockSize = 0x4004644d
+
+	// directIOFlag names how openDirect bypasses the buffer cache.
+	directIOFlag = "F_NOCACHE"
+)
+
+// openDirect opens path with flag and turns on F_NOCACHE, macOS's
+// equivalent of O_DIRECT, so that reads and writes bypass the buffer cache.
+func openDirect(path string, flag int) (*os.File, error) {
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_NOCACHE, 1); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// fdatasync flushes the data written to file. macOS has no fdatasync, so
+// this is a full fsync.
+func fdatasync(file *os.File) error {
+	return file.Sync()
+}
+
+// deviceSize returns the size of the disk or image file open as file.
+// Seeking to the end of a disk device yields 0 on macOS, so disks are asked
+// for their block count and size instead.
+func deviceSize(file *os.File) (int64, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Mode().IsRegular() {
+		return file.Seek(0, io.SeekEnd)
+	}
+
+	var blockSize uint32
+	var blockCount uint64
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), dkiocGetBlockSize, uintptr(unsafe.Pointer(&blockSize))); errno != 0 {
+		return 0, errno
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), dkiocGetBlockCount, uintptr(unsafe.Pointer(&blockCount))); errno != 0 {
+		return 0, errno
+	}
+	return int64(blockCount) * int64(blockSize), nil
+}
+
+// sectorSizes returns the logical and physical sector size of the disk
+// open as file, or false if it doesn't report them.
+func sectorSizes(file *os.File) (int, int, bool) {
+	var logical, physical uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), dkiocGetBlockSize, uintptr(unsafe.Pointer(&logical))); errno != 0 {
+		return 0, 0, false
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), dkiocGetPhysicalBlockSize, uintptr(unsafe.Pointer(&physical))); errno != 0 {
+		physical = 0
+	}
+	return int(logical), int(physical), true
+}
+
+// fpunchhole mirrors struct fpunchhole from <sys/fcntl.h>.
+type fpunchhole struct {
+	Flags    uint32
+	reserved uint32
+	Offset   int64
+	Length   int64
+}
+
+// punchHole deallocates length bytes at offset of a regular file, which
+// then read back as zeros. APFS only frees whole filesystem blocks.
+func punchHole(file *os.File, offset, length int64) error {
+	arg := fpunchhole{Offset: offset, Length: length}
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, file.Fd(), unix.F_PUNCHHOLE, uintptr(unsafe.Pointer(&arg))); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// isRawDisk reports whether path is a raw disk such as /dev/rdisk2, the
+// character device macOS pairs with every /dev/disk2 block device. Raw
+// disks skip the buffer cache and are much faster to wipe.
+func isRawDisk(path string) bool {
+	return strings.HasPrefix(filepath.Base(path), "rdisk")
+}
+
+// diskPartitions returns the /dev paths of the slices of device, e.g.
+// /dev/disk2s1 for /dev/disk2 or /dev/rdisk2, and the block device paired
+// with a raw disk, since that is what the mount table names.
+func diskPartitions(device string) ([]string, error) {
+	disk := strings.TrimPrefix(blockDeviceName(device), "r")
+	slices, err := filepath.Glob(filepath.Join("/dev", disk+"s*"))
+	if err != nil {
+		return nil, err
+	}
+	if isRawDisk(device) {
+		slices = append([]string{filepath.Join("/dev", disk)}, slices...)
+	}
+	return slices, nil
+}
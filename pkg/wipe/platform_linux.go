@@ -0,0 +1,71 @@
+package wipe
+
+import (
+	"io"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// blkSSZGet and blkPBSZGet are the BLKSSZGET and BLKPBSZGET ioctls,
+	// returning a block device's logical and physical sector size.
+	blkSSZGet  = 0x1268
+	blkPBSZGet = 0x127b
+
+	// fallocate modes used to emulate discard on regular files
+	fallocKeepSize  = 0x01
+	fallocPunchHole = 0x02
+
+	// directIOFlag names how openDirect bypasses the page cache.
+	directIOFlag = "O_DIRECT"
+)
+
+// openDirect opens path with flag and O_DIRECT, so that reads and writes
+// bypass the page cache.
+func openDirect(path string, flag int) (*os.File, error) {
+	return os.OpenFile(path, flag|syscall.O_DIRECT, 0)
+}
+
+// fdatasync flushes the data written to file, without the metadata that
+// fsync also writes.
+func fdatasync(file *os.File) error {
+	return syscall.Fdatasync(int(file.Fd()))
+}
+
+// deviceSize returns the size of the block device or image file open as
+// file. Seeking to the end works for both.
+func deviceSize(file *os.File) (int64, error) {
+	return file.Seek(0, io.SeekEnd)
+}
+
+// sectorSizes returns the logical and physical sector size of the block
+// device open as file, or false if it doesn't report them.
+func sectorSizes(file *os.File) (int, int, bool) {
+	var logical int32
+	var physical uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkSSZGet, uintptr(unsafe.Pointer(&logical))); errno != 0 {
+		return 0, 0, false
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkPBSZGet, uintptr(unsafe.Pointer(&physical))); errno != 0 {
+		physical = 0
+	}
+	return int(logical), int(physical), true
+}
+
+// punchHole deallocates length bytes at offset of a regular file, which
+// then read back as zeros.
+func punchHole(file *os.File, offset, length int64) error {
+	return syscall.Fallocate(int(file.Fd()), fallocPunchHole|fallocKeepSize, offset, length)
+}
+
+// isRawDisk reports whether path is a character device that gives raw
+// access to a disk. Linux has none; disks are always block devices.
+func isRawDisk(path string) bool {
+	return false
+}
+
+// diskPartitions returns the /dev paths of the partitions of device.
+func diskPartitions(device string) ([]string, error) {
+	return listPartitions(device)
+}
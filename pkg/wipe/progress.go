@@ -0,0 +1,310 @@
+package wipe
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Progress is a snapshot of a running wipe handed to progress reporters
+// once per update interval.
+type Progress struct {
+	Device         string        `json:"device"`
+	BytesProcessed int64         `json:"bytes_processed"` // written plus skipped bytes
+	BytesWritten   int64         `json:"bytes_written"`
+	Total          int64         `json:"total"`
+	Speed          float64       `json:"speed_bps"` // instantaneous speed in bytes per second
+	ETA            time.Duration `json:"eta_ns"`    // based on the average speed over the ETA window
+	Coverage       coverage      `json:"coverage"`
+	Pass           int           `json:"pass,omitempty"`   // 1-based pass number, when there are several
+	Passes         int           `json:"passes,omitempty"` // number of overwrite passes
+	Stage          int           `json:"stage,omitempty"`  // with -fill-gaps: 1 while coarsely covering, 2 while filling the gaps
+
+	// TotalETA is the estimated time left for all devices and passes of
+	// the run, set when there is more to it than the current pass.
+	TotalETA time.Duration `json:"total_eta_ns,omitempty"`
+}
+
+// stageNames describes the stages of a -fill-gaps wipe.
+var stageNames = map[int]string{1: "coarse", 2: "filling gaps"}
+
+// label returns the "Pass 2/3, stage 1/2 (coarse): " prefix of the update,
+// or "" for a single-pass wipe.
+func (u Progress) label() string {
+	var parts []string
+	if u.Passes > 1 {
+		parts = append(parts, fmt.Sprintf("Pass %d/%d", u.Pass, u.Passes))
+	}
+	if u.Stage > 0 {
+		parts = append(parts, fmt.Sprintf("stage %d/2 (%s)", u.Stage, stageNames[u.Stage]))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	label := strings.Join(parts, ", ") + ": "
+	return strings.ToUpper(label[:1]) + label[1:]
+}
+
+func (u Progress) String() string {
+	percentComplete := float64(u.BytesProcessed) / float64(u.Total) * 100.0
+
+	progressInfo := fmt.Sprintf("Progress: %.2f%% (%s/%s) at %.2f MB/s, ETA: %s",
+		percentComplete,
+		FormatBytes(u.BytesProcessed),
+		FormatBytes(u.Total),
+		u.Speed/1024/1024, // Show current speed for reference
+		FormatDuration(u.ETA))
+
+	if u.TotalETA > 0 {
+		progressInfo += fmt.Sprintf(", total ETA: %s", FormatDuration(u.TotalETA))
+	}
+	if !u.Coverage.full() {
+		coveragePercent := float64(u.BytesWritten) / float64(u.Total) * 100.0
+		progressInfo += fmt.Sprintf(" (%.1f%% of bytes actually overwritten)", coveragePercent)
+	}
+	return u.label() + progressInfo
+}
+
+// speedWindow averages the throughput of the last few progress updates. The
+// ETA is based on it because, unlike a moving average that weights recent
+// samples most, it follows a drive's speed across regions without swinging
+// on every transient dip.
+type speedWindow struct {
+	bytes   []int64
+	elapsed []time.Duration
+	next    int // slot the next sample goes into once the window is full
+}
+
+// newSpeedWindow returns a window over the last size samples, at least one.
+func newSpeedWindow(size int) *speedWindow {
+	size = max(size, 1)
+	return &speedWindow{bytes: make([]int64, 0, size), elapsed: make([]time.Duration, 0, size)}
+}
+
+// add records that bytes were processed in elapsed, dropping the oldest
+// sample once the window is full.
+func (w *speedWindow) add(bytes int64, elapsed time.Duration) {
+	if len(w.bytes) < cap(w.bytes) {
+		w.bytes, w.elapsed = append(w.bytes, bytes), append(w.elapsed, elapsed)
+		return
+	}
+	w.bytes[w.next], w.elapsed[w.next] = bytes, elapsed
+	w.next = (w.next + 1) % len(w.bytes)
+}
+
+// speed returns the average speed over the window in bytes per second.
+func (w *speedWindow) speed() float64 {
+	var bytes int64
+	var elapsed time.Duration
+	for i := range w.bytes {
+		bytes += w.bytes[i]
+		elapsed += w.elapsed[i]
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(bytes) / elapsed.Seconds()
+}
+
+// ProgressFunc receives progress updates from Wipe.
+type ProgressFunc func(Progress)
+
+// teeProgress returns a ProgressFunc that passes every update to each of
+// the non-nil reporters in turn.
+func teeProgress(reporters ...ProgressFunc) ProgressFunc {
+	return func(u Progress) {
+		for _, report := range reporters {
+			if report != nil {
+				report(u)
+			}
+		}
+	}
+}
+
+// inPlaceProgress rewrites a single terminal line on every update.
+func inPlaceProgress(u Progress) {
+	fmt.Printf("\r\033[K\r%s", u)
+}
+
+// barProgress rewrites a single terminal line with a progress bar sized to
+// the terminal's current width, followed by the usual figures. The last
+// column is left free so that the terminal doesn't wrap the line.
+func barProgress(u Progress) {
+	fmt.Printf("\r\033[K\r%s", u.bar(terminalWidth(os.Stdout)-1))
+}
+
+// newDisplay picks how progress is shown: as a JSON stream to stdout with
+// -json, not at all with -quiet, or as a bar with -bar unless several
+// devices are wiped in parallel. A nil display leaves the choice to runJobs.
+func newDisplay(jsonOutput, quiet, bar, parallel bool) (*jsonStream, ProgressFunc) {
+	var stream *jsonStream
+	var display ProgressFunc
+	if jsonOutput {
+		stream = newJSONStream(os.Stdout)
+		display = stream.progress
+	}
+	if quiet {
+		display = func(Progress) {}
+	} else if bar && display == nil && !parallel {
+		display = barProgress
+	}
+	return stream, display
+}
+
+// minBarWidth is the narrowest bar drawn; on narrower terminals the line is
+// cut off instead.
+const minBarWidth = 10
+
+// bar renders u as "[#####-----] 50.00% (...) at ... MB/s, ETA: ..." in at
+// most width columns.
+func (u Progress) bar(width int) string {
+	fraction := min(max(float64(u.BytesProcessed)/float64(u.Total), 0), 1)
+	figures := fmt.Sprintf(" %.2f%% (%s/%s) at %.2f MB/s, ETA: %s",
+		fraction*100, FormatBytes(u.BytesProcessed), FormatBytes(u.Total), u.Speed/1024/1024, FormatDuration(u.ETA))
+	if u.TotalETA > 0 {
+		figures += fmt.Sprintf(", total ETA: %s", FormatDuration(u.TotalETA))
+	}
+	prefix := u.label()
+
+	barWidth := max(width-len(prefix)-len(figures)-2, minBarWidth)
+	filled := int(fraction * float64(barWidth))
+	line := prefix + "[" + strings.Repeat("#", filled) + strings.Repeat("-", barWidth-filled) + "]" + figures
+	if len(line) > width {
+		line = line[:width]
+	}
+	return line
+}
+
+// terminalWidth returns the number of columns of the terminal f is
+// connected to, or 80 if it can't be determined.
+func terminalWidth(f *os.File) int {
+	ws, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
+
+// lineProgress prints every update on its own line tagged with the device,
+// so that concurrent wipes don't overwrite each other's output.
+func lineProgress(u Progress) {
+	fmt.Printf("[%s] %s\n", u.Device, u)
+}
+
+// Result summarizes a finished (or interrupted) wipe.
+type Result struct {
+	Device          string        `json:"device"`
+	Size            int64         `json:"size"`
+	BytesProcessed  int64         `json:"bytes_processed"`
+	BytesWritten    int64         `json:"bytes_written"`
+	Coverage        coverage      `json:"coverage"`
+	Duration        time.Duration `json:"duration_ns"`
+	ResumedAt       int64         `json:"resumed_at,omitempty"` // offset this run started from
+	Method          string        `json:"method"`
+	Passes          int           `json:"passes,omitempty"`        // overwrite passes made
+	TotalWritten    int64         `json:"total_written,omitempty"` // bytes written by all passes
+	Digest          string        `json:"digest,omitempty"`        // over the block digests of random data
+	DigestAlgorithm string        `json:"digest_algorithm,omitempty"`
+	Verified        bool          `json:"verified,omitempty"`         // read back and checked after the wipe
+	VerifiedSamples int           `json:"verified_samples,omitempty"` // blocks checked by -verify-sample; 0 means all
+	Stamped         bool          `json:"stamped,omitempty"`          // the wipe marker of -stamp was written
+	BadBlocks       []int64       `json:"bad_blocks,omitempty"`       // offsets of blocks skipped after -retries
+	SkipSeed        uint64        `json:"skip_seed,omitempty"`        // seed of a -skip-mode random selection
+	SMARTBefore     *smartSummary `json:"smart_before,omitempty"`
+	SMARTAfter      *smartSummary `json:"smart_after,omitempty"`
+	Certificate     string        `json:"certificate,omitempty"` // file the wipe certificate was written to
+	StartedAt       time.Time     `json:"started_at"`            // UTC, including verification
+	FinishedAt      time.Time     `json:"finished_at"`
+
+	digests []blockDigest // recorded for verifying random data
+}
+
+// Wipe methods recorded in Result.Method.
+const (
+	methodOverwrite = "overwrite"
+	methodDiscard   = "discard"
+)
+
+// firmwareMethodNames describes the methods in which the drive erases
+// itself.
+var firmwareMethodNames = map[string]string{
+	methodNVMeSanitize: "NVMe Sanitize",
+	methodNVMeFormat:   "NVMe Format NVM",
+
+	methodATASecureErase:         "ATA Secure Erase",
+	methodATAEnhancedSecureErase: "ATA Enhanced Secure Erase",
+}
+
+func (r Result) String() string {
+	var summaryMsg string
+	if r.Method == methodDiscard {
+		summaryMsg = fmt.Sprintf("Completed: Discarded %s in %s",
+			FormatBytes(r.BytesProcessed), FormatDuration(r.Duration))
+	} else if name, ok := firmwareMethodNames[r.Method]; ok {
+		summaryMsg = fmt.Sprintf("Completed: Erased %s with %s in %s",
+			FormatBytes(r.Size), name, FormatDuration(r.Duration))
+	} else {
+		summaryMsg = r.overwriteSummary()
+	}
+
+	if !r.StartedAt.IsZero() {
+		summaryMsg += fmt.Sprintf("\nStarted: %s, finished: %s",
+			r.StartedAt.Format(time.RFC3339), r.FinishedAt.Format(time.RFC3339))
+	}
+	if r.Verified && r.VerifiedSamples > 0 {
+		summaryMsg += fmt.Sprintf("\nVerification: passed (%d sampled blocks)", r.VerifiedSamples)
+	} else if r.Verified {
+		summaryMsg += "\nVerification: passed"
+	}
+	if r.Stamped {
+		summaryMsg += "\nMarker: written to the first block"
+	}
+	if r.SMARTBefore != nil {
+		summaryMsg += fmt.Sprintf("\nSMART before: %s", r.SMARTBefore)
+	}
+	if r.SMARTAfter != nil {
+		summaryMsg += fmt.Sprintf("\nSMART after: %s", r.SMARTAfter)
+	}
+	if r.Certificate != "" {
+		summaryMsg += fmt.Sprintf("\nCertificate written to %s", r.Certificate)
+	}
+	return summaryMsg
+}
+
+// averageSpeed returns the bytes processed per second by this run, across
+// all passes.
+func (r Result) averageSpeed() float64 {
+	processed := r.BytesProcessed - r.ResumedAt
+	if r.Passes > 1 {
+		processed += int64(r.Passes-1) * r.Size
+	}
+	return float64(processed) / r.Duration.Seconds()
+}
+
+func (r Result) overwriteSummary() string {
+	passes := ""
+	if r.Passes > 1 {
+		passes = fmt.Sprintf("%d passes, ", r.Passes)
+	}
+	summaryMsg := fmt.Sprintf("Completed: Processed %s in %s (%saverage speed: %.2f MB/s)",
+		FormatBytes(r.BytesProcessed),
+		FormatDuration(r.Duration),
+		passes,
+		r.averageSpeed()/1024/1024)
+
+	if !r.Coverage.full() {
+		coveragePercent := float64(r.BytesWritten) / float64(r.Size) * 100.0
+		summaryMsg += fmt.Sprintf("\nActually overwritten: %s (%.1f%% of device)",
+			FormatBytes(r.BytesWritten), coveragePercent)
+	}
+	if r.Passes > 1 {
+		summaryMsg += fmt.Sprintf("\nWritten in total: %s over %d passes", FormatBytes(r.TotalWritten), r.Passes)
+	}
+	if len(r.BadBlocks) > 0 {
+		summaryMsg += "\n" + badBlockSummary(r.BadBlocks)
+	}
+	return summaryMsg
+}
@@ -0,0 +1,62 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// maxPacerDebt is how far a pacer may fall behind its schedule, for example
+// while the device stalls, before it stops trying to catch up. Without it a
+// long stall would be followed by a burst at full speed.
+const maxPacerDebt = time.Second
+
+// parseRate parses a throughput limit such as "50M" or "50M/s". The units
+// are binary, like the MB/s in the progress display.
+func parseRate(value string) (int64, error) {
+	rate, err := parseSizeUnits(strings.TrimSuffix(strings.TrimSpace(value), "/s"), bufferUnits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", value)
+	}
+	return rate, nil
+}
+
+// pacer spaces out writes so that they stay below a throughput limit. A nil
+// pacer doesn't limit anything.
+type pacer struct {
+	rate  float64 // bytes per second
+	start time.Time
+	bytes int64
+}
+
+// newPacer returns a pacer for rate bytes per second, or nil if rate is 0.
+func newPacer(rate int64) *pacer {
+	if rate <= 0 {
+		return nil
+	}
+	return &pacer{rate: float64(rate), start: time.Now()}
+}
+
+// wait accounts for n more bytes written and sleeps until writing them is
+// within the limit, or until ctx is done.
+func (p *pacer) wait(ctx context.Context, n int) {
+	if p == nil {
+		return
+	}
+	p.bytes += int64(n)
+	due := p.start.Add(time.Duration(float64(p.bytes) / p.rate * float64(time.Second)))
+	delay := time.Until(due)
+	if delay < -maxPacerDebt {
+		// Far behind: start a new schedule from here
+		p.start, p.bytes = time.Now(), 0
+		return
+	}
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+	}
+}
@@ -0,0 +1,9 @@
+package wipe
+
+const rdrandSupported = true
+
+// rdrandFill fills buf with RDRAND output. It retries each word a few times,
+// as Intel recommends, and reports false if the generator stays exhausted.
+//
+//go:noescape
+func rdrandFill(buf []uint64) bool
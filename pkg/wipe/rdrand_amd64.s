@@ -0,0 +1,29 @@
+#include "textflag.h"
+
+// func rdrandFill(buf []uint64) bool
+TEXT ·rdrandFill(SB), NOSPLIT, $0-25
+	MOVQ buf_base+0(FP), DI
+	MOVQ buf_len+8(FP), SI
+
+next:
+	TESTQ SI, SI
+	JZ    done
+	MOVL  $10, CX
+
+retry:
+	RDRANDQ AX
+	JCS     store
+	DECL    CX
+	JNZ     retry
+	MOVB    $0, ret+24(FP)
+	RET
+
+store:
+	MOVQ AX, (DI)
+	ADDQ $8, DI
+	DECQ SI
+	JMP  next
+
+done:
+	MOVB $1, ret+24(FP)
+	RET
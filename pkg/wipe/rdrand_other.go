@@ -0,0 +1,7 @@
+//go:build !amd64
+
+package wipe
+
+const rdrandSupported = false
+
+func rdrandFill(buf []uint64) bool { return false }
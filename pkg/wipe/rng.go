@@ -0,0 +1,199 @@
+package wipe
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	mathrand "math/rand/v2"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Random data sources selectable with -rng.
+const (
+	rngChaCha   = "chacha8" // ChaCha8 stream seeded from crypto/rand, the default
+	rngCrypto   = "crypto"  // crypto/rand, the kernel CSPRNG
+	rngSecure   = "secure"  // same as rngCrypto
+	rngHardware = "hw"      // the CPU's RDRAND instruction
+	rngAES      = "aes"     // AES-256-CTR keystream under a per-run random key
+)
+
+// hwRNGAvailable reports whether the CPU has RDRAND. CPUID is only queried
+// once.
+var hwRNGAvailable = sync.OnceValue(func() bool {
+	return cpu.X86.HasRDRAND && rdrandSupported
+})
+
+// rngDescription names the random source for the banner and log output.
+func rngDescription(source string) string {
+	switch source {
+	case rngHardware:
+		return "RDRAND (hardware)"
+	case rngAES:
+		return "AES-CTR keystream (per-run random key)"
+	case rngChaCha:
+		return "ChaCha8 stream (seeded from crypto/rand)"
+	}
+	return "crypto/rand"
+}
+
+// validRNG reports whether name is a known random source.
+func validRNG(name string) bool {
+	switch name {
+	case rngChaCha, rngCrypto, rngSecure, rngHardware, rngAES:
+		return true
+	}
+	return false
+}
+
+// randomChunkSize is the share of a buffer that one goroutine fills at a
+// time. Chunk boundaries depend only on the buffer length, not on the number
+// of CPUs, so which bytes come from which call is the same on every machine.
+const randomChunkSize = 1 << 20
+
+// fillRandom fills buf from the named random source. Generating random data
+// is CPU-bound, so large buffers are split into chunks that are filled
+// concurrently, one goroutine per CPU, each drawing from the source on its
+// own.
+func fillRandom(source string, buf []byte) error {
+	chunks := (len(buf) + randomChunkSize - 1) / randomChunkSize
+	workers := min(chunks, runtime.GOMAXPROCS(0))
+	if workers <= 1 {
+		return fillRandomChunk(source, buf)
+	}
+
+	var (
+		next atomic.Int64
+		wg   sync.WaitGroup
+		errs = make([]error, workers)
+	)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= chunks {
+					return
+				}
+				chunk := buf[i*randomChunkSize : min(len(buf), (i+1)*randomChunkSize)]
+				if err := fillRandomChunk(source, chunk); err != nil {
+					errs[w] = err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fillRandomChunk fills buf from the named random source in the calling
+// goroutine.
+func fillRandomChunk(source string, buf []byte) error {
+	switch source {
+	case rngHardware:
+		if !rdrandBytes(buf) {
+			return errors.New("failed to generate random data: RDRAND returned no data")
+		}
+		return nil
+	case rngAES:
+		return aesCTRBytes(buf)
+	case rngChaCha:
+		return chachaBytes(buf)
+	}
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("failed to generate random data: %w", err)
+	}
+	return nil
+}
+
+// rdrandBytes fills buf with RDRAND output, eight bytes per instruction.
+func rdrandBytes(buf []byte) bool {
+	words := len(buf) / 8
+	if words > 0 && !rdrandFill(unsafe.Slice((*uint64)(unsafe.Pointer(&buf[0])), words)) {
+		return false
+	}
+	if tail := buf[words*8:]; len(tail) > 0 {
+		var last [1]uint64
+		if !rdrandFill(last[:]) {
+			return false
+		}
+		copy(tail, unsafe.Slice((*byte)(unsafe.Pointer(&last[0])), 8))
+	}
+	return true
+}
+
+// aesCTR is the AES-256 key and nonce of the AES-CTR source, drawn from
+// crypto/rand the first time it is used. The key never leaves memory, so
+// the keystream cannot be reproduced after the run.
+var aesCTR = sync.OnceValues(func() (aesCTRState, error) {
+	var secret [32 + 8]byte
+	if _, err := rand.Read(secret[:]); err != nil {
+		return aesCTRState{}, err
+	}
+	block, err := aes.NewCipher(secret[:32])
+	if err != nil {
+		return aesCTRState{}, err
+	}
+	return aesCTRState{block: block, nonce: binary.BigEndian.Uint64(secret[32:])}, nil
+})
+
+type aesCTRState struct {
+	block cipher.Block
+	nonce uint64
+}
+
+// aesCTRCounter hands out keystream positions in AES blocks, so chunks filled
+// concurrently never reuse part of the keystream.
+var aesCTRCounter atomic.Uint64
+
+// aesCTRBytes fills buf with the next len(buf) bytes of AES-CTR keystream.
+func aesCTRBytes(buf []byte) error {
+	state, err := aesCTR()
+	if err != nil {
+		return fmt.Errorf("failed to generate random data: %w", err)
+	}
+	blocks := uint64(len(buf)+aes.BlockSize-1) / aes.BlockSize
+	start := aesCTRCounter.Add(blocks) - blocks
+
+	var iv [aes.BlockSize]byte
+	binary.BigEndian.PutUint64(iv[:8], state.nonce)
+	binary.BigEndian.PutUint64(iv[8:], start)
+	clear(buf)
+	cipher.NewCTR(state.block, iv[:]).XORKeyStream(buf, buf)
+	return nil
+}
+
+// chachaGenerators holds ChaCha8 generators for reuse. Each is seeded from
+// crypto/rand when it is created and only ever used by one goroutine at a
+// time, so concurrently filled chunks draw from independent streams.
+var chachaGenerators sync.Pool
+
+// chachaBytes fills buf with ChaCha8 output.
+func chachaBytes(buf []byte) error {
+	gen, _ := chachaGenerators.Get().(*mathrand.ChaCha8)
+	if gen == nil {
+		var seed [32]byte
+		if _, err := rand.Read(seed[:]); err != nil {
+			return fmt.Errorf("failed to generate random data: %w", err)
+		}
+		gen = mathrand.NewChaCha8(seed)
+	}
+	gen.Read(buf)
+	chachaGenerators.Put(gen)
+	return nil
+}
@@ -0,0 +1,63 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// startTimeLayouts are the accepted formats for -at. Layouts without a date
+// refer to the next occurrence of that clock time.
+var startTimeLayouts = []struct {
+	layout   string
+	timeOnly bool
+}{
+	{time.RFC3339, false},
+	{"2006-01-02 15:04:05", false},
+	{"2006-01-02 15:04", false},
+	{"15:04:05", true},
+	{"15:04", true},
+}
+
+// parseStartTime interprets value as a wall-clock start time in the local
+// time zone, relative to now.
+func parseStartTime(value string, now time.Time) (time.Time, error) {
+	for _, l := range startTimeLayouts {
+		t, err := time.ParseInLocation(l.layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+		if l.timeOnly {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+			if !t.After(now) {
+				t = t.AddDate(0, 0, 1)
+			}
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid start time %q (use HH:MM, HH:MM:SS, \"YYYY-MM-DD HH:MM\" or RFC3339)", value)
+}
+
+// waitUntil sleeps until start while printing a countdown. It returns the
+// context's error if ctx is cancelled first.
+func waitUntil(ctx context.Context, start time.Time) error {
+	fmt.Printf("Wipe scheduled to start at %s\n", start.Format("2006-01-02 15:04:05 MST"))
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		remaining := time.Until(start)
+		if remaining <= 0 {
+			fmt.Printf("\r\033[K\r")
+			return nil
+		}
+		fmt.Printf("\r\033[K\rStarting in %s", FormatDuration(remaining))
+
+		select {
+		case <-ctx.Done():
+			fmt.Println()
+			return context.Cause(ctx)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// watchdogStallLimit is how long a wipe may go without progress before the
+// watchdog is no longer fed and systemd is left to restart the service.
+// Benchmarks and SMART queries report no progress, so it is generous.
+const watchdogStallLimit = 10 * time.Minute
+
+// sdNotify sends a state string such as "READY=1" to the service manager,
+// following the sd_notify protocol: one datagram to the Unix socket named
+// by $NOTIFY_SOCKET. Outside systemd the variable is unset and sdNotify
+// does nothing. Errors are ignored, as for sd_notify(3).
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	// A leading @ names a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state))
+}
+
+// watchdogInterval returns how often to ping systemd's watchdog: half of
+// $WATCHDOG_USEC, if the watchdog is enabled for this process.
+func watchdogInterval() (time.Duration, bool) {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond / 2, true
+}
+
+// serviceWatchdog feeds systemd's watchdog for as long as the wipe makes
+// progress, so that a wiper hung on a dead device gets restarted.
+type serviceWatchdog struct {
+	lastActivity atomic.Int64 // UnixNano
+}
+
+func newServiceWatchdog() *serviceWatchdog {
+	w := &serviceWatchdog{}
+	w.touch()
+	return w
+}
+
+// touch records that the wipe is making progress.
+func (w *serviceWatchdog) touch() {
+	w.lastActivity.Store(time.Now().UnixNano())
+}
+
+// run pings the watchdog until ctx is done. While idle reports true, for
+// example when the daemon has no running jobs, it pings regardless of
+// progress. It returns at once if the watchdog is not enabled.
+func (w *serviceWatchdog) run(ctx context.Context, idle func() bool) {
+	interval, ok := watchdogInterval()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if idle() || time.Since(time.Unix(0, w.lastActivity.Load())) < watchdogStallLimit {
+			sdNotify("WATCHDOG=1")
+		}
+	}
+}
+
+// progressStatus formats u as a systemd STATUS= line.
+func progressStatus(u Progress) string {
+	return fmt.Sprintf("STATUS=%s: %.1f%% at %.1f MB/s, ETA %s", u.Device,
+		float64(u.BytesProcessed)/float64(u.Total)*100, u.Speed/1024/1024, FormatDuration(u.ETA))
+}
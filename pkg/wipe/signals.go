@@ -0,0 +1,69 @@
+package wipe
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// forceExitWindow is how soon a stop signal must be repeated after the
+// previous delivery of the same signal to end the process at once instead of stopping cleanly.
+const forceExitWindow = 5 * time.Second
+
+// SignalError is the cancellation cause recorded when a wipe is stopped by a
+// signal.
+type SignalError struct {
+	Signal os.Signal
+}
+
+func (e *SignalError) Error() string {
+	return "received signal: " + e.Signal.String()
+}
+
+// Unwrap makes a wipe stopped by a signal match context.Canceled, like any
+// other cancelled wipe.
+func (e *SignalError) Unwrap() error { return context.Canceled }
+
+// signalContext returns a context that is cancelled with a *SignalError cause
+// when one of sigs is delivered. The same signal again within
+// forceExitWindow exits the process immediately, without waiting for the
+// wipe to stop; another of sigs, such as a SIGTERM after Ctrl-C, doesn't.
+// The returned stop function releases the signal handler.
+func signalContext(sigs ...os.Signal) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+
+	go func() {
+		var last os.Signal
+		var lastAt time.Time
+		for {
+			select {
+			case sig := <-ch:
+				cause := &SignalError{Signal: sig}
+				if sig == last && time.Since(lastAt) < forceExitWindow {
+					fmt.Println("\nExiting immediately.")
+					os.Exit(exitCodeFor(cause))
+				}
+				last, lastAt = sig, time.Now()
+				cancel(cause)
+				fmt.Printf("\nReceived %s, stopping cleanly; repeat within %s to exit immediately.\n", sig, forceExitWindow)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(ch)
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+		cancel(nil)
+	}
+}
@@ -0,0 +1,90 @@
+package wipe
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// expectSizeTolerance is how far, as a fraction of the expected size, a
+// device may be off from -expect-size before it counts as a different disk.
+// Drives sold as the same capacity differ by far less than this.
+const expectSizeTolerance = 0.01
+
+// errSizeMismatch is returned when a device's size is too far from
+// -expect-size and the mismatch can't be confirmed interactively.
+var errSizeMismatch = errors.New("device size does not match -expect-size")
+
+// sizeUnits maps the suffixes accepted by parseSize to their multipliers.
+// Single letters and SI suffixes are decimal, as on drive labels; the IEC
+// suffixes are binary.
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1e3, "kb": 1e3, "kib": 1 << 10,
+	"m": 1e6, "mb": 1e6, "mib": 1 << 20,
+	"g": 1e9, "gb": 1e9, "gib": 1 << 30,
+	"t": 1e12, "tb": 1e12, "tib": 1 << 40,
+	"p": 1e15, "pb": 1e15, "pib": 1 << 50,
+}
+
+// bufferUnits are the suffixes accepted for buffer sizes. They are binary
+// whichever way they are written, as in the output of FormatBytes, so that
+// -buffer 4M is the default 4194304 bytes.
+var bufferUnits = map[string]float64{
+	"": 1, "b": 1,
+	"k": 1 << 10, "kb": 1 << 10, "kib": 1 << 10,
+	"m": 1 << 20, "mb": 1 << 20, "mib": 1 << 20,
+	"g": 1 << 30, "gb": 1 << 30, "gib": 1 << 30,
+}
+
+// parseSize parses a human-readable size such as "500G", "1.5 TB",
+// "931.5GiB" or a plain number of bytes.
+func parseSize(value string) (int64, error) {
+	return parseSizeUnits(value, sizeUnits)
+}
+
+// parseBufferSize parses a buffer size such as "8M", "512K" or a plain
+// number of bytes.
+func parseBufferSize(value string) (int, error) {
+	n, err := parseSizeUnits(value, bufferUnits)
+	if err != nil || n > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid buffer size %q", value)
+	}
+	return int(n), nil
+}
+
+// bufferSizeFlag is a flag.Value for buffer sizes given with parseBufferSize
+// suffixes. It prints as a plain number of bytes.
+type bufferSizeFlag int
+
+func (f *bufferSizeFlag) String() string { return strconv.Itoa(int(*f)) }
+
+func (f *bufferSizeFlag) Set(value string) error {
+	n, err := parseBufferSize(value)
+	*f = bufferSizeFlag(n)
+	return err
+}
+
+func parseSizeUnits(value string, units map[string]float64) (int64, error) {
+	s := strings.TrimSpace(value)
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	number, unit := s[:i], strings.ToLower(strings.TrimSpace(s[i:]))
+
+	n, err := strconv.ParseFloat(number, 64)
+	multiplier, ok := units[unit]
+	if err != nil || !ok || n <= 0 || n*multiplier > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * multiplier), nil
+}
+
+// sizeMatches reports whether actual is within expectSizeTolerance of
+// expected.
+func sizeMatches(actual, expected int64) bool {
+	return math.Abs(float64(actual-expected)) <= float64(expected)*expectSizeTolerance
+}
@@ -0,0 +1,124 @@
+package wipe
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// smartSummary is the part of a drive's SMART data that matters for a wipe
+// record. Fields the drive doesn't report are left nil.
+type smartSummary struct {
+	TakenAt      time.Time `json:"taken_at"`
+	Passed       *bool     `json:"passed,omitempty"`
+	PowerOnHours *int64    `json:"power_on_hours,omitempty"`
+	Temperature  *int64    `json:"temperature_c,omitempty"`
+	Reallocated  *int64    `json:"reallocated_sectors,omitempty"` // ATA attribute 5
+	MediaErrors  *int64    `json:"media_errors,omitempty"`        // NVMe
+}
+
+func (s smartSummary) String() string {
+	health := "unknown"
+	if s.Passed != nil {
+		health = "FAILED"
+		if *s.Passed {
+			health = "PASSED"
+		}
+	}
+	msg := "health " + health
+	if s.Reallocated != nil {
+		msg += fmt.Sprintf(", %d reallocated sectors", *s.Reallocated)
+	}
+	if s.MediaErrors != nil {
+		msg += fmt.Sprintf(", %d media errors", *s.MediaErrors)
+	}
+	if s.Temperature != nil {
+		msg += fmt.Sprintf(", %d°C", *s.Temperature)
+	}
+	return msg
+}
+
+// takeSMART reads the SMART summary of device, printing a warning and
+// returning nil if it is not available.
+func takeSMART(device string) *smartSummary {
+	summary, err := readSMART(device)
+	if err != nil {
+		warnf("No SMART data for %s: %v", device, err)
+	}
+	return summary
+}
+
+// readSMART runs smartctl on the disk holding device and summarizes its
+// output. Disk images have no SMART data and yield ErrUnsupported.
+func readSMART(device string) (*smartSummary, error) {
+	fi, err := os.Stat(device)
+	if err != nil {
+		return nil, newDeviceError("stat", device, err)
+	}
+	if fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %s is a regular file", ErrUnsupported, device)
+	}
+
+	disk := filepath.Join("/dev", parentDiskName(blockDeviceName(device)))
+	out, err := exec.Command("smartctl", "--json", "--all", disk).Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, fmt.Errorf("%w: smartctl is not installed", ErrUnsupported)
+	}
+
+	// smartctl encodes drive warnings in its exit status, so judge the
+	// output rather than the error
+	var data struct {
+		SmartStatus *struct {
+			Passed bool `json:"passed"`
+		} `json:"smart_status"`
+		PowerOnTime *struct {
+			Hours int64 `json:"hours"`
+		} `json:"power_on_time"`
+		Temperature *struct {
+			Current int64 `json:"current"`
+		} `json:"temperature"`
+		ATAAttributes *struct {
+			Table []struct {
+				ID  int `json:"id"`
+				Raw struct {
+					Value int64 `json:"value"`
+				} `json:"raw"`
+			} `json:"table"`
+		} `json:"ata_smart_attributes"`
+		NVMeHealth *struct {
+			MediaErrors int64 `json:"media_errors"`
+		} `json:"nvme_smart_health_information_log"`
+	}
+	if jsonErr := json.Unmarshal(out, &data); jsonErr != nil {
+		if err != nil {
+			return nil, fmt.Errorf("smartctl %s: %w", disk, err)
+		}
+		return nil, fmt.Errorf("smartctl %s: invalid output: %v", disk, jsonErr)
+	}
+	if data.SmartStatus == nil {
+		return nil, fmt.Errorf("%w: %s reports no SMART status", ErrUnsupported, disk)
+	}
+
+	summary := &smartSummary{TakenAt: time.Now().UTC(), Passed: &data.SmartStatus.Passed}
+	if data.PowerOnTime != nil {
+		summary.PowerOnHours = &data.PowerOnTime.Hours
+	}
+	if data.Temperature != nil {
+		summary.Temperature = &data.Temperature.Current
+	}
+	if data.ATAAttributes != nil {
+		for _, attr := range data.ATAAttributes.Table {
+			if attr.ID == 5 {
+				summary.Reallocated = &attr.Raw.Value
+			}
+		}
+	}
+	if data.NVMeHealth != nil {
+		summary.MediaErrors = &data.NVMeHealth.MediaErrors
+	}
+	return summary, nil
+}
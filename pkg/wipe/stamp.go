@@ -0,0 +1,118 @@
+package wipe
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// stampMagic opens the marker -stamp leaves in the first block of a wiped
+// device.
+const stampMagic = "QUICKWIPE: DATA WIPED"
+
+// wipeStamp renders the marker for a finished wipe as lines of text,
+// zero-padded to one block of size bytes and cut off if they don't fit.
+func wipeStamp(job wipeJob, result Result, run runInfo, size int) []byte {
+	lines := []string{
+		stampMagic,
+		"Tool: quickwipe " + run.Version,
+		"Device: " + job.Device,
+	}
+	if serial := deviceSerial(job.Device); serial != "" {
+		lines = append(lines, "Serial: "+serial)
+	}
+	method := result.Method
+	if method == "" {
+		method = methodOverwrite
+	}
+	covered := "whole device"
+	if !result.Coverage.full() {
+		covered = result.Coverage.describe(job.SkipMode)
+		if result.SkipSeed != 0 {
+			covered += fmt.Sprintf(" (seed %d)", result.SkipSeed)
+		}
+	}
+	lines = append(lines,
+		"Method: "+method,
+		"Passes: "+strings.Join(job.passPatterns(), ", "),
+		"Coverage: "+covered,
+		fmt.Sprintf("Verified: %t", result.Verified),
+		"Finished: "+time.Now().UTC().Format(time.RFC3339),
+	)
+	if run.Hostname != "" {
+		lines = append(lines, "Host: "+run.Hostname)
+	}
+	if run.Operator != "" {
+		lines = append(lines, "Operator: "+run.Operator)
+	}
+	block := make([]byte, size)
+	copy(block, strings.Join(lines, "\n")+"\n")
+	return block
+}
+
+// stampDevice writes the marker of a finished wipe to the first block of
+// the device and reads it back.
+func stampDevice(job wipeJob, result Result, run runInfo) error {
+	file, err := os.OpenFile(job.Device, os.O_RDWR|syscall.O_SYNC, 0)
+	if err != nil {
+		return newDeviceError("open", job.Device, err)
+	}
+	defer file.Close()
+
+	stamp := wipeStamp(job, result, run, deviceBlockSize(job.Device))
+	if _, err := file.WriteAt(stamp, 0); err != nil {
+		return newWriteError(0, err)
+	}
+	if err := file.Sync(); err != nil {
+		return newDeviceError("sync", job.Device, err)
+	}
+	readBack := make([]byte, len(stamp))
+	if _, err := file.ReadAt(readBack, 0); err != nil {
+		return newDeviceError("read", job.Device, err)
+	}
+	if !bytes.Equal(readBack, stamp) {
+		return fmt.Errorf("wipe marker does not read back correctly from %s", job.Device)
+	}
+	return nil
+}
+
+// isStamped reports whether block, read from the start of a device, begins
+// with the marker of an earlier wipe.
+func isStamped(block []byte) bool {
+	return bytes.HasPrefix(block, []byte(stampMagic))
+}
+
+// readStamp reads the first block of path and returns the marker of an
+// earlier -stamp wipe, as text, or "" if there is none.
+func readStamp(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	block := make([]byte, deviceBlockSize(path))
+	n, err := file.ReadAt(block, 0)
+	if err != nil && n < len(stampMagic) {
+		return "", newDeviceError("read", path, err)
+	}
+	if !isStamped(block[:n]) {
+		return "", nil
+	}
+	text, _, _ := bytes.Cut(block[:n], []byte{0})
+	return string(text), nil
+}
+
+// stampField returns the value of the line starting with key in the marker
+// text stamp, or "" if there is no such line.
+func stampField(stamp, key string) string {
+	for _, line := range strings.Split(stamp, "\n") {
+		if value, ok := strings.CutPrefix(line, key+": "); ok {
+			return value
+		}
+	}
+	return ""
+}
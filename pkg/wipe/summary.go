@@ -0,0 +1,69 @@
+package wipe
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+)
+
+// Statuses recorded in a summary file.
+const (
+	summaryCompleted   = "completed"
+	summaryInterrupted = "interrupted"
+	summaryTimeLimit   = "time_limit"   // stopped by -max-duration
+	summaryDisconnect  = "disconnected" // the device disappeared or changed size
+)
+
+// wipeSummary is the small record written by -summary for scripts that
+// want the statistics of a wipe without parsing its output.
+type wipeSummary struct {
+	Status          string  `json:"status"` // summaryCompleted, summaryInterrupted, summaryTimeLimit or summaryDisconnect
+	Device          string  `json:"device"`
+	Method          string  `json:"method"`
+	BytesProcessed  int64   `json:"bytes_processed"`
+	BytesWritten    int64   `json:"bytes_written"`
+	CoveragePercent float64 `json:"coverage_percent"`    // of the device overwritten by the last pass
+	SkipSeed        uint64  `json:"skip_seed,omitempty"` // of a -skip-mode random wipe
+	Passes          int     `json:"passes"`
+	Pattern         string  `json:"pattern"` // the patterns of all passes, comma-separated
+	ElapsedSeconds  float64 `json:"elapsed_seconds"`
+	AverageSpeed    float64 `json:"average_speed"` // bytes per second
+
+	// BenchmarkSpeeds are the write speeds -benchmark-regions measured
+	// across the device before the wipe.
+	BenchmarkSpeeds []regionSpeed `json:"benchmark_speeds,omitempty"`
+}
+
+// defaultSummaryPath derives a summary file name in the working directory
+// from the device name, e.g. quickwipe-sdb.summary.json.
+func defaultSummaryPath(device string) string {
+	return fmt.Sprintf("quickwipe-%s.summary.json", filepath.Base(device))
+}
+
+// newWipeSummary summarizes the result of job, which ended with status.
+func newWipeSummary(status string, job wipeJob, result Result) wipeSummary {
+	summary := wipeSummary{
+		Status:         status,
+		Device:         job.Device,
+		Method:         result.Method,
+		BytesProcessed: result.BytesProcessed,
+		BytesWritten:   result.BytesWritten,
+		SkipSeed:       result.SkipSeed,
+		Passes:         max(result.Passes, 1),
+		Pattern:        strings.Join(job.passPatterns(), ","),
+		ElapsedSeconds: result.FinishedAt.Sub(result.StartedAt).Seconds(),
+
+		BenchmarkSpeeds: job.benchSpeeds,
+	}
+	if summary.Method == "" {
+		summary.Method = methodOverwrite
+	}
+	if job.size > 0 {
+		summary.CoveragePercent = float64(result.BytesWritten) / float64(job.size) * 100.0
+	}
+	if speed := result.averageSpeed(); !math.IsNaN(speed) && !math.IsInf(speed, 0) {
+		summary.AverageSpeed = speed
+	}
+	return summary
+}
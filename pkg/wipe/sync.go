@@ -0,0 +1,75 @@
+package wipe
+
+import (
+	"fmt"
+	"os"
+)
+
+// Values of -sync-mode, which choose how writes are made durable.
+const (
+	syncOSync     = "osync"     // every write returns once it is on stable storage
+	syncFdatasync = "fdatasync" // fdatasync after every SyncEvery buffers
+	syncNone      = "none"      // only the sync at the end of the wipe
+
+	defaultSyncEvery = 64
+)
+
+func validSyncMode(mode string) bool {
+	switch mode {
+	case syncOSync, syncFdatasync, syncNone:
+		return true
+	}
+	return false
+}
+
+// periodicSync flushes a device opened without O_SYNC as its sync mode
+// asks: every so many writes for fdatasync, and before a checkpoint is
+// recorded, so that a checkpoint never claims more than reached the
+// device. With O_SYNC there is never anything to flush.
+type periodicSync struct {
+	osync   bool
+	every   int // writes between flushes; 0 only flushes when asked to
+	pending int // writes since the last flush
+}
+
+func newPeriodicSync(opts Options) *periodicSync {
+	switch opts.SyncMode {
+	case syncFdatasync:
+		return &periodicSync{every: opts.SyncEvery}
+	case syncNone:
+		return &periodicSync{}
+	}
+	return &periodicSync{osync: true}
+}
+
+// wrote records a write to file and flushes file if a flush is due.
+func (s *periodicSync) wrote(file *os.File) error {
+	if s.osync {
+		return nil
+	}
+	s.pending++
+	if s.every > 0 && s.pending >= s.every {
+		return s.flush(file)
+	}
+	return nil
+}
+
+// flush makes the writes to file since the last flush durable.
+func (s *periodicSync) flush(file *os.File) error {
+	if s.pending == 0 {
+		return nil
+	}
+	s.pending = 0
+	return fdatasync(file)
+}
+
+// describeSync describes how writes made with opts reach stable storage.
+func describeSync(opts Options) string {
+	switch opts.SyncMode {
+	case syncFdatasync:
+		return fmt.Sprintf("fdatasync every %d buffers", opts.SyncEvery)
+	case syncNone:
+		return "synced once at the end"
+	}
+	return "O_SYNC"
+}
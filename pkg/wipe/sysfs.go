@@ -0,0 +1,110 @@
+package wipe
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sysClassBlock is where the kernel exposes every block device and partition.
+const sysClassBlock = "/sys/class/block"
+
+// blockDeviceName resolves symlinks such as /dev/disk/by-id/... and returns
+// the kernel name of the device, e.g. "sdb" or "nvme0n1p2".
+func blockDeviceName(path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return filepath.Base(path)
+}
+
+// readSysfsString returns the trimmed contents of a sysfs attribute, or ""
+// if it cannot be read.
+func readSysfsString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// listPartitions returns the /dev paths of the partitions of a whole-disk
+// device, ordered by partition number. It returns nil for devices without a
+// partition table and for partitions themselves.
+func listPartitions(device string) ([]string, error) {
+	name := blockDeviceName(device)
+	entries, err := os.ReadDir(filepath.Join(sysClassBlock, name))
+	if err != nil {
+		return nil, err
+	}
+
+	type part struct {
+		name   string
+		number int
+	}
+	var parts []part
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), name) {
+			continue
+		}
+		number, err := strconv.Atoi(readSysfsString(filepath.Join(sysClassBlock, name, entry.Name(), "partition")))
+		if err != nil {
+			continue
+		}
+		parts = append(parts, part{entry.Name(), number})
+	}
+	sort.Slice(parts, func(i, j int) bool { return parts[i].number < parts[j].number })
+
+	paths := make([]string, len(parts))
+	for i, p := range parts {
+		paths[i] = filepath.Join("/dev", p.name)
+	}
+	return paths, nil
+}
+
+// parentDiskName returns the kernel name of the whole disk a partition
+// belongs to, or name itself if it is not a partition.
+func parentDiskName(name string) string {
+	if _, err := os.Stat(filepath.Join(sysClassBlock, name, "partition")); err != nil {
+		return name
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Join(sysClassBlock, name))
+	if err != nil {
+		return name
+	}
+	return filepath.Base(filepath.Dir(resolved))
+}
+
+// deviceSerial returns the serial number the kernel reports for the disk
+// holding device, or "" if it is unknown.
+func deviceSerial(device string) string {
+	disk := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)))
+	for _, attr := range []string{"device/serial", "serial", "device/vpd_pg80"} {
+		if serial := readSysfsString(filepath.Join(disk, attr)); serial != "" {
+			return strings.TrimFunc(serial, func(r rune) bool { return r < ' ' || r > '~' })
+		}
+	}
+	return ""
+}
+
+// deviceModel returns the model the kernel reports for the disk holding
+// device, or "" if it is unknown.
+func deviceModel(device string) string {
+	disk := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)))
+	return readSysfsString(filepath.Join(disk, "device", "model"))
+}
+
+// deviceType returns "HDD" or "SSD" depending on whether the kernel reports
+// the disk holding device as rotational, or "" if it doesn't say.
+func deviceType(device string) string {
+	disk := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)))
+	switch readSysfsString(filepath.Join(disk, "queue", "rotational")) {
+	case "1":
+		return "HDD"
+	case "0":
+		return "SSD"
+	}
+	return ""
+}
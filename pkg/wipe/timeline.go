@@ -0,0 +1,76 @@
+package wipe
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// timeline appends one CSV row per progress update, for plotting where a
+// drive slowed down.
+type timeline struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+}
+
+// openTimeline opens (or creates) the CSV file at path for appending and
+// writes the header row if the file is new.
+func openTimeline(path string) (*timeline, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	t := &timeline{file: file, writer: csv.NewWriter(file)}
+
+	if fi, err := file.Stat(); err == nil && fi.Size() == 0 {
+		t.writer.Write([]string{"timestamp", "device", "offset", "bytes_written", "speed_bps", "temperature_c"})
+		t.writer.Flush()
+	}
+	return t, t.writer.Error()
+}
+
+// record is a ProgressFunc adding a row for u. Rows are flushed immediately
+// so the data survives a crash.
+func (t *timeline) record(u Progress) {
+	temperature := ""
+	if millis, ok := deviceTemperature(u.Device); ok {
+		temperature = strconv.FormatFloat(float64(millis)/1000, 'f', 1, 64)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writer.Write([]string{
+		time.Now().UTC().Format(time.RFC3339Nano),
+		u.Device,
+		strconv.FormatInt(u.BytesProcessed, 10),
+		strconv.FormatInt(u.BytesWritten, 10),
+		strconv.FormatFloat(u.Speed, 'f', 0, 64),
+		temperature,
+	})
+	t.writer.Flush()
+}
+
+func (t *timeline) Close() error {
+	t.writer.Flush()
+	return t.file.Close()
+}
+
+// deviceTemperature reads the drive temperature in millidegrees Celsius from
+// the hwmon sensor the kernel exposes for NVMe drives and, with the drivetemp
+// module, for SATA drives.
+func deviceTemperature(device string) (int64, bool) {
+	disk := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)), "device")
+	for _, pattern := range []string{"hwmon*/temp1_input", "hwmon/hwmon*/temp1_input", "device/hwmon*/temp1_input"} {
+		matches, _ := filepath.Glob(filepath.Join(disk, pattern))
+		for _, match := range matches {
+			if millis, err := strconv.ParseInt(readSysfsString(match), 10, 64); err == nil {
+				return millis, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,351 @@
+package wipe
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"time"
+)
+
+// verifyDevice reads back every block the wipe of job wrote and checks it,
+// returning a *VerifyError listing every mismatched block. Deterministic patterns are
+// regenerated, visiting blocks in the same order and with the same skipping
+// as wipeDevice; random data is checked against the digests wipeDevice
+// recorded in result.
+func verifyDevice(ctx context.Context, job wipeJob, result Result, report ProgressFunc) error {
+	if !deterministicPattern(job.Pattern) {
+		return verifyDigests(ctx, job, result.digests, report)
+	}
+
+	path, size, bufferSize := job.Device, job.size, job.BufferSize
+	cov := job.coverage()
+
+	// Bypass the page cache so we see what actually reached the media
+	file, err := openDirect(path, os.O_RDONLY)
+	if err != nil {
+		file, err = os.Open(path)
+		if err != nil {
+			return newDeviceError("open", path, err)
+		}
+	}
+	defer file.Close()
+
+	got, err := AllocAlignedBuffer(bufferSize)
+	if err != nil {
+		return fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(got)
+	want := make([]byte, bufferSize)
+	blockSize := deviceBlockSize(path)
+
+	fmt.Printf("\nVerifying %s...\n", path)
+	selector := job.blockSelector()
+
+	startTime := time.Now()
+	lastUpdateTime := startTime
+	var verr *VerifyError
+	offset, bytesRead := job.passStart(), int64(0)
+	offset = min(size, offset+int64(bufferSize)*selector.skipRun())
+	lastUpdateBytes := offset
+	for offset < size {
+		if ctx.Err() != nil {
+			return &InterruptedError{Offset: offset, Err: context.Cause(ctx)}
+		}
+
+		readSize := min(int64(bufferSize), size-offset)
+		n, err := readAligned(file, got, int(readSize), job.physical(offset), blockSize)
+		if err != nil {
+			return newDeviceError("read", path, err)
+		}
+		if err := fillPattern(job, want[:n], offset); err != nil {
+			return err
+		}
+		if !bytes.Equal(got[:n], want[:n]) {
+			if verr == nil {
+				verr = newVerifyError(job.Pattern, job.physical(offset), got[:n], want[:n])
+			}
+			verr.Blocks = append(verr.Blocks, job.physical(offset))
+		}
+		offset += int64(n)
+		bytesRead += int64(n)
+
+		if !cov.full() && offset < size {
+			offset = min(size, offset+int64(bufferSize)*selector.skipRun())
+		}
+
+		if now := time.Now(); now.Sub(lastUpdateTime) >= job.ProgressInterval {
+			speed := float64(offset-lastUpdateBytes) / now.Sub(lastUpdateTime).Seconds()
+			// A stalled device has no ETA yet
+			var eta time.Duration
+			if speed > 0 {
+				eta = time.Duration(float64(size-offset)/speed) * time.Second
+			}
+			report(Progress{
+				Device:         path,
+				BytesProcessed: offset,
+				BytesWritten:   bytesRead,
+				Total:          size,
+				Speed:          speed,
+				ETA:            eta,
+				Coverage:       cov,
+			})
+			lastUpdateTime, lastUpdateBytes = now, offset
+		}
+	}
+
+	if verr != nil {
+		return reportMismatches(path, verr)
+	}
+	fmt.Printf("\nVerified %s: %s read back as written in %s\n",
+		path, FormatBytes(bytesRead), FormatDuration(time.Since(startTime)))
+	return nil
+}
+
+// verifyDigests reads back the blocks listed in digests and compares their
+// digest with the recorded one.
+func verifyDigests(ctx context.Context, job wipeJob, digests []blockDigest, report ProgressFunc) error {
+	path, size := job.Device, job.size
+
+	file, err := openDirect(path, os.O_RDONLY)
+	if err != nil {
+		file, err = os.Open(path)
+		if err != nil {
+			return newDeviceError("open", path, err)
+		}
+	}
+	defer file.Close()
+
+	got, err := AllocAlignedBuffer(job.BufferSize)
+	if err != nil {
+		return fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(got)
+	blockSize := deviceBlockSize(path)
+
+	fmt.Printf("\nVerifying %s against %d block digests...\n", path, len(digests))
+	startTime := time.Now()
+	lastUpdateTime := startTime
+	lastUpdateBytes := int64(0)
+	bytesRead := int64(0)
+	var verr *VerifyError
+	var entropy *entropyCheck
+	if job.EntropyCheck {
+		entropy = newEntropyCheck()
+	}
+	for _, d := range digests {
+		if ctx.Err() != nil {
+			return &InterruptedError{Offset: d.Offset, Err: context.Cause(ctx)}
+		}
+
+		n, err := readAligned(file, got, d.Length, job.physical(d.Offset), blockSize)
+		if err != nil {
+			return newDeviceError("read", path, err)
+		}
+		if blockSum(job.Digest, got[:n]) != d.Sum {
+			if verr == nil {
+				verr = &VerifyError{Offset: job.physical(d.Offset), Detail: "block digest differs"}
+			}
+			verr.Blocks = append(verr.Blocks, job.physical(d.Offset))
+		}
+		if entropy != nil {
+			entropy.add(job.physical(d.Offset), got[:n])
+		}
+		bytesRead += int64(n)
+
+		if now := time.Now(); now.Sub(lastUpdateTime) >= job.ProgressInterval {
+			speed := float64(bytesRead-lastUpdateBytes) / now.Sub(lastUpdateTime).Seconds()
+			report(Progress{
+				Device:         path,
+				BytesProcessed: d.Offset + int64(n),
+				BytesWritten:   bytesRead,
+				Total:          size,
+				Speed:          speed,
+				Coverage:       job.coverage(),
+			})
+			lastUpdateTime, lastUpdateBytes = now, bytesRead
+		}
+	}
+
+	if entropy != nil {
+		entropy.report(path)
+	}
+	if verr != nil {
+		return reportMismatches(path, verr)
+	}
+	fmt.Printf("\nVerified %s: %s read back as written in %s\n",
+		path, FormatBytes(bytesRead), FormatDuration(time.Since(startTime)))
+	return nil
+}
+
+// maxListedMismatches limits how many mismatched block offsets are printed;
+// the *VerifyError still holds all of them.
+const maxListedMismatches = 20
+
+// reportMismatches prints how many blocks of path failed verification and
+// where, and returns verr.
+func reportMismatches(path string, verr *VerifyError) error {
+	fmt.Println()
+	errorf("%s: %d block(s) did not read back as written", path, len(verr.Blocks))
+	for i, offset := range verr.Blocks {
+		if i == maxListedMismatches {
+			fmt.Printf("  ... and %d more\n", len(verr.Blocks)-i)
+			break
+		}
+		fmt.Printf("  block at offset %d\n", offset)
+	}
+	return verr
+}
+
+// newVerifyError locates the first differing byte of a block read at offset.
+// For the counter pattern it also decodes which LBA the sector holds, which
+// tells a misdirected write apart from corrupted or unwritten data.
+func newVerifyError(pattern string, offset int64, got, want []byte) *VerifyError {
+	i := 0
+	for i < len(got) && got[i] == want[i] {
+		i++
+	}
+	verr := &VerifyError{Offset: offset + int64(i)}
+
+	if pattern == patternCounter {
+		sector := i / counterSectorSize * counterSectorSize
+		if sector+8 <= len(got) && (offset+int64(sector))%counterSectorSize == 0 {
+			lba := binary.LittleEndian.Uint64(got[sector : sector+8])
+			verr.Detail = fmt.Sprintf("sector %d holds data for LBA %d",
+				(offset+int64(sector))/counterSectorSize, lba)
+		}
+	}
+	return verr
+}
+
+// sampleBlock is a block picked for verification by verifySample.
+type sampleBlock struct {
+	Offset int64
+	Length int
+	Random bool     // check against Sum rather than the pattern
+	Sum    [32]byte // digest recorded while writing random data
+}
+
+// pickSamples chooses up to count of the blocks of job's last pass at
+// random, using the block digests for random data and walking the blocks
+// the way wipeDevice does for deterministic patterns. The samples are
+// returned in device order.
+func pickSamples(job wipeJob, digests []blockDigest, count int) []sampleBlock {
+	var samples []sampleBlock
+	seen := 0
+	// Reservoir sampling keeps every block equally likely without holding
+	// a list of all of them
+	add := func(b sampleBlock) {
+		seen++
+		if len(samples) < count {
+			samples = append(samples, b)
+		} else if i := rand.IntN(seen); i < count {
+			samples[i] = b
+		}
+	}
+
+	if !deterministicPattern(job.Pattern) {
+		for _, d := range digests {
+			add(sampleBlock{Offset: d.Offset, Length: d.Length, Random: true, Sum: d.Sum})
+		}
+	} else {
+		cov := job.coverage()
+		selector := job.blockSelector()
+		start := job.passStart() + int64(job.BufferSize)*selector.skipRun()
+		for offset := min(job.size, start); offset < job.size; {
+			length := min(int64(job.BufferSize), job.size-offset)
+			add(sampleBlock{Offset: offset, Length: int(length)})
+			offset += length
+			if !cov.full() && offset < job.size {
+				offset = min(job.size, offset+int64(job.BufferSize)*selector.skipRun())
+			}
+		}
+	}
+	slices.SortFunc(samples, func(a, b sampleBlock) int { return cmp.Compare(a.Offset, b.Offset) })
+	return samples
+}
+
+// verifySample reads back job.VerifySample randomly chosen written blocks
+// instead of all of them, printing the result for each, and returns how
+// many were checked. Mismatches are returned as a *VerifyError like
+// verifyDevice does.
+func verifySample(ctx context.Context, job wipeJob, result Result) (int, error) {
+	path := job.Device
+	samples := pickSamples(job, result.digests, job.VerifySample)
+
+	file, err := openDirect(path, os.O_RDONLY)
+	if err != nil {
+		file, err = os.Open(path)
+		if err != nil {
+			return 0, newDeviceError("open", path, err)
+		}
+	}
+	defer file.Close()
+
+	got, err := AllocAlignedBuffer(job.BufferSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(got)
+	want := make([]byte, job.BufferSize)
+	blockSize := deviceBlockSize(path)
+
+	fmt.Printf("\nVerifying %d sampled blocks of %s...\n", len(samples), path)
+	startTime := time.Now()
+	var verr *VerifyError
+	var entropy *entropyCheck
+	if job.EntropyCheck {
+		entropy = newEntropyCheck()
+	}
+	for _, s := range samples {
+		if ctx.Err() != nil {
+			return 0, &InterruptedError{Offset: s.Offset, Err: context.Cause(ctx)}
+		}
+
+		offset := job.physical(s.Offset)
+		n, err := readAligned(file, got, s.Length, offset, blockSize)
+		if err != nil {
+			return 0, newDeviceError("read", path, err)
+		}
+		var bad *VerifyError
+		if s.Random {
+			if blockSum(job.Digest, got[:n]) != s.Sum {
+				bad = &VerifyError{Offset: offset, Detail: "block digest differs"}
+			}
+			if entropy != nil {
+				entropy.add(offset, got[:n])
+			}
+		} else {
+			if err := fillPattern(job, want[:n], s.Offset); err != nil {
+				return 0, err
+			}
+			if !bytes.Equal(got[:n], want[:n]) {
+				bad = newVerifyError(job.Pattern, offset, got[:n], want[:n])
+			}
+		}
+
+		status := "ok"
+		if bad != nil {
+			status = "MISMATCH"
+			if verr == nil {
+				verr = bad
+			}
+			verr.Blocks = append(verr.Blocks, offset)
+		}
+		fmt.Printf("  block at offset %d: %s\n", offset, status)
+	}
+
+	if entropy != nil {
+		entropy.report(path)
+	}
+	if verr != nil {
+		return len(samples), reportMismatches(path, verr)
+	}
+	fmt.Printf("Verified %s: %d sampled blocks read back as written in %s\n",
+		path, len(samples), FormatDuration(time.Since(startTime)))
+	return len(samples), nil
+}
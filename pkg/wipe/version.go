@@ -0,0 +1,77 @@
+package wipe
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// version, commit and date identify the build. Release builds set them with
+//
+//	go build -ldflags "-X github.com/f0o/quickwipe/pkg/wipe.version=v1.2.3 -X github.com/f0o/quickwipe/pkg/wipe.commit=$(git rev-parse HEAD) -X github.com/f0o/quickwipe/pkg/wipe.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// and other builds fall back to the module version and the VCS information
+// the Go toolchain embeds.
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildVersion returns the best available version string for this binary.
+func buildVersion() string {
+	if version != "dev" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return version
+}
+
+// buildCommit and buildDate return the git commit and the build (or, for
+// builds without -ldflags, commit) time, or "unknown".
+func buildCommit() string {
+	return buildSetting(commit, "vcs.revision")
+}
+
+func buildDate() string {
+	return buildSetting(date, "vcs.time")
+}
+
+func buildSetting(value, key string) string {
+	if value != "" {
+		return value
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == key && setting.Value != "" {
+				return setting.Value
+			}
+		}
+	}
+	return "unknown"
+}
+
+// runInfo identifies who ran a wipe, where, and with which build. It is
+// stamped on every record the tool produces for chain-of-custody purposes.
+type runInfo struct {
+	Hostname string `json:"hostname"`
+	Operator string `json:"operator"`
+	Version  string `json:"version"`
+}
+
+// newRunInfo collects the run metadata. An empty operator falls back to the
+// invoking user, looking through sudo.
+func newRunInfo(operator string) runInfo {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	if operator == "" {
+		operator = os.Getenv("SUDO_USER")
+	}
+	if operator == "" {
+		operator = os.Getenv("USER")
+	}
+	return runInfo{Hostname: hostname, Operator: operator, Version: buildVersion()}
+}
@@ -0,0 +1,796 @@
+package wipe
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// Wipe wipes device as the quickwipe command would with the flags that opts
+// mirrors, without asking for confirmation: the caller is responsible for
+// having picked the right device. The safety checks of -assume-yes still
+// apply, so a device that is mounted, in use or swap, or a path outside
+// /dev/, is refused unless opts.Force is set. Unset options take their
+// defaults, and progress, if not nil, is called once per ProgressInterval;
+// with several passes or a verify, its TotalETA covers all of them.
+//
+// Cancelling ctx stops the wipe at the next block boundary: Wipe then
+// returns the partial result with an *InterruptedError, which names the
+// checkpoint it wrote if opts.Checkpoint is set. Messages go to standard
+// output like the command's do.
+func Wipe(ctx context.Context, device string, opts Options, progress ProgressFunc) (Result, error) {
+	job := wipeJob{Device: device, Options: opts.withDefaults(), assumeYes: true}
+	if err := validateJob(job); err != nil {
+		return Result{}, err
+	}
+	if err := prepareJob(ctx, &job, opts.Force); err != nil {
+		return Result{}, err
+	}
+	// prepareJob picks the command's default checkpoint file
+	job.Checkpoint = opts.Checkpoint
+	if progress == nil {
+		progress = func(Progress) {}
+	}
+	estimate := newRunEstimate([]wipeJob{job}, false)
+	return runJob(ctx, job, nil, newRunInfo(""), estimate.progress(0, progress))
+}
+
+// errTooSmallToBenchmark is returned by BenchmarkWriteSpeed for devices
+// smaller than two buffers, which are too small to time writes to.
+var errTooSmallToBenchmark = errors.New("device too small to benchmark")
+
+// BenchmarkWriteSpeed performs a short write test at the start of the
+// device to determine write speed in bytes per second; see
+// benchmarkWriteSpeedAt. The data it writes is overwritten, not restored.
+func BenchmarkWriteSpeed(ctx context.Context, path string, opts Options) (float64, error) {
+	opts = opts.withDefaults()
+	deviceSize, err := DeviceSize(path)
+	if err != nil {
+		return 0, err
+	}
+	return benchmarkWriteSpeedAt(ctx, path, opts, 0, deviceSize)
+}
+
+// benchmarkWriteSpeedAt performs a short write test within the deviceSize
+// bytes from start. The device is opened, and random data regenerated, as
+// wipeDevice would do with opts, so that the estimate matches the real
+// wipe. It never writes past start+deviceSize.
+func benchmarkWriteSpeedAt(ctx context.Context, path string, opts Options, start, deviceSize int64) (float64, error) {
+	// Direct I/O needs whole blocks
+	blockSize := deviceBlockSize(path)
+	alignedBufferSize := alignBufferSize(opts.BufferSize, blockSize)
+
+	if deviceSize < int64(alignedBufferSize)*2 {
+		return 0, errTooSmallToBenchmark
+	}
+	return benchmarkWriteRange(ctx, path, opts, start, benchmarkSize(opts, deviceSize, alignedBufferSize, blockSize))
+}
+
+// benchmarkWriteRange times writing benchSize bytes of random data at
+// start, or for opts.BenchmarkTime if that comes first. Like wipeDevice it
+// writes at explicit offsets, never through the file position; start is
+// rounded down to a block boundary for direct I/O. When ctx is cancelled it
+// syncs what it wrote and returns an error wrapping the cause.
+func benchmarkWriteRange(ctx context.Context, path string, opts Options, start, benchSize int64) (float64, error) {
+	blockSize := deviceBlockSize(path)
+	alignedBufferSize := alignBufferSize(opts.BufferSize, blockSize)
+	start = start / int64(blockSize) * int64(blockSize)
+
+	// Open the device the same way wipeDevice does
+	file, err := openForWipe(path, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	// Create an aligned buffer for direct I/O
+	buffer, err := AllocAlignedBuffer(alignedBufferSize)
+	if err != nil {
+		file.Close()
+		return 0, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(buffer)
+
+	if opts.BenchmarkTime > 0 {
+		fmt.Printf("Running benchmark: writing random data for %s, at most %s...\n", opts.BenchmarkTime, FormatBytes(benchSize))
+	} else {
+		fmt.Printf("Running benchmark: writing %s of random data...\n", FormatBytes(benchSize))
+	}
+
+	bytesWritten := int64(0)
+	startTime := time.Now()
+
+	for writes := 0; bytesWritten < benchSize; writes++ {
+		if ctx.Err() != nil {
+			if err := file.Sync(); err != nil {
+				fmt.Println()
+				warnf("Sync after interruption failed: %v", err)
+			}
+			file.Close()
+			fmt.Println()
+			return 0, fmt.Errorf("benchmark of %s stopped: %w", path, context.Cause(ctx))
+		}
+
+		// Fill buffer with random data, reusing it between refreshes
+		if writes%opts.RandomRefresh == 0 {
+			if err := fillRandom(opts.RNG, buffer); err != nil {
+				file.Close()
+				return 0, err
+			}
+		}
+
+		// Calculate how many bytes to write in this iteration
+		writeSize := int64(alignedBufferSize)
+		if benchSize-bytesWritten < writeSize {
+			writeSize = benchSize - bytesWritten
+		}
+
+		// Write the buffer to the device
+		n, err := writeAligned(file, path, buffer[:writeSize], start+bytesWritten, blockSize)
+		if err != nil {
+			file.Close()
+			return 0, newWriteError(start+bytesWritten, err)
+		}
+		bytesWritten += int64(n)
+
+		// Print progress as a simple percentage
+		percentComplete := float64(bytesWritten) / float64(benchSize) * 100.0
+		timeUp := false
+		if opts.BenchmarkTime > 0 {
+			elapsed := time.Since(startTime)
+			percentComplete = max(percentComplete, min(100, float64(elapsed)/float64(opts.BenchmarkTime)*100.0))
+			timeUp = elapsed >= opts.BenchmarkTime
+		}
+		fmt.Printf("\r\033[K\rBenchmarking: %.1f%% complete...", percentComplete)
+		if timeUp {
+			break
+		}
+	}
+
+	// Ensure all data is flushed to disk before stopping the timer
+	err = file.Sync()
+	if err != nil {
+		file.Close()
+		return 0, fmt.Errorf("benchmark sync failed: %w", newDeviceError("sync", path, err))
+	}
+
+	file.Close()
+
+	// Calculate speed
+	elapsedTime := time.Since(startTime).Seconds()
+	writeSpeed := float64(bytesWritten) / elapsedTime
+
+	fmt.Printf("\r\033[K\rBenchmark complete: wrote %s in %.2f seconds\n",
+		FormatBytes(bytesWritten), elapsedTime)
+
+	return writeSpeed, nil
+}
+
+// benchmarkSize returns how much the write benchmark writes to a device of
+// deviceSize bytes: opts.BenchmarkSize, 10 GB by default, or as much as a
+// time limit alone allows, but at most a quarter of small devices.
+func benchmarkSize(opts Options, deviceSize int64, bufferSize, blockSize int) int64 {
+	benchSize := int64(defaultBenchmarkSize)
+	switch {
+	case opts.BenchmarkSize > 0:
+		benchSize = opts.BenchmarkSize
+	case opts.BenchmarkTime > 0:
+		benchSize = deviceSize
+	}
+
+	// For very small devices, adjust benchmark size
+	if deviceSize < benchSize*2 {
+		benchSize = deviceSize / 4 // Use at most 25% of the device for benchmarking
+		if benchSize < int64(bufferSize)*2 {
+			benchSize = int64(bufferSize) * 2 // Minimum two buffers
+		}
+	}
+	// ...in whole blocks, and never past the end of the device
+	return min(benchSize, deviceSize) / int64(blockSize) * int64(blockSize)
+}
+
+// readBenchmarkSize is how much the read benchmark reads before a verify.
+const readBenchmarkSize = 1024 * 1024 * 1024
+
+// benchmarkReadSpeed measures sequential read speed over the first limit
+// bytes of the device, which is what a verify pass is limited by. Unlike
+// BenchmarkWriteSpeed it does not modify the device.
+func benchmarkReadSpeed(path string, bufferSize int, limit int64) (float64, error) {
+	// Read around the page cache so that cached data doesn't inflate the result
+	file, err := openDirect(path, os.O_RDONLY)
+	if err != nil {
+		warnf("Direct I/O not supported, read benchmark may be inflated by the page cache: %v", err)
+		file, err = os.Open(path)
+		if err != nil {
+			return 0, newDeviceError("open", path, err)
+		}
+	}
+	defer file.Close()
+
+	// Direct I/O needs whole blocks
+	blockSize := deviceBlockSize(path)
+	alignedBufferSize := alignBufferSize(bufferSize, blockSize)
+
+	buffer, err := AllocAlignedBuffer(alignedBufferSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer FreeAlignedBuffer(buffer)
+
+	deviceSize, err := DeviceSize(path)
+	if err != nil {
+		return 0, err
+	}
+
+	// Reading is harmless, so up to limit or the whole device if it is smaller
+	benchSize := min(limit, deviceSize/int64(blockSize)*int64(blockSize))
+	if benchSize == 0 {
+		return 0, fmt.Errorf("device too small to benchmark")
+	}
+
+	fmt.Printf("Running benchmark: reading %s...\n", FormatBytes(benchSize))
+
+	bytesRead := int64(0)
+	startTime := time.Now()
+	for bytesRead < benchSize {
+		n, err := file.ReadAt(buffer[:min(int64(len(buffer)), benchSize-bytesRead)], bytesRead)
+		if err != nil {
+			return 0, newDeviceError("read", path, err)
+		}
+		bytesRead += int64(n)
+
+		percentComplete := float64(bytesRead) / float64(benchSize) * 100.0
+		fmt.Printf("\r\033[K\rBenchmarking: %.1f%% complete...", percentComplete)
+	}
+
+	elapsedTime := time.Since(startTime).Seconds()
+	readSpeed := float64(bytesRead) / elapsedTime
+
+	fmt.Printf("\r\033[K\rBenchmark complete: read %s in %.2f seconds\n",
+		FormatBytes(bytesRead), elapsedTime)
+
+	return readSpeed, nil
+}
+
+// DeviceSize returns the size in bytes of the block device or file at path.
+func DeviceSize(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, newDeviceError("open", path, err)
+	}
+	defer file.Close()
+
+	size, err := deviceSize(file)
+	if err != nil {
+		return 0, newDeviceError("get size of", path, err)
+	}
+	return size, nil
+}
+
+// wipeDevice overwrites job's device with its pattern, writing only the
+// share of blocks given by its coverage, and calls report with a
+// Progress once per update interval. The job must have been prepared.
+// When ctx is cancelled it stops at the next block boundary, syncs, records a
+// checkpoint (if the job names one) and returns the partial result with an
+// *InterruptedError. A non-nil resume continues from where that checkpoint
+// left off.
+func wipeDevice(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	path, size, bufferSize := job.Device, job.size, job.BufferSize
+	cov, checkpointPath := job.coverage(), job.Checkpoint
+	var skipSeed uint64
+	if job.SkipMode == skipModeRandom && !cov.full() {
+		skipSeed = job.SkipSeed
+	}
+
+	file, err := openForWipe(path, job.Options)
+	if err != nil {
+		return Result{}, err
+	}
+	// The file is replaced if the device has to be re-opened
+	defer func() { file.Close() }()
+	serial := deviceSerial(path)
+
+	// Direct I/O needs whole blocks. prepareJob has already aligned the
+	// buffer size; this only matters for jobs that bypassed it.
+	job.blockSize = deviceBlockSize(path)
+	bufferSize = alignBufferSize(bufferSize, job.blockSize)
+	job.BufferSize = bufferSize
+	alignedBufferSize := bufferSize
+	debugBlockSize(path, job.blockSize)
+
+	// Create the aligned buffers for direct I/O
+	ring, err := newBufferRing(job.PipelineDepth, alignedBufferSize)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to allocate aligned buffer: %w", err)
+	}
+	defer ring.release()
+	debugBuffers(path, ring.buffers)
+	if job.Mlock {
+		defer lockBuffer(ring.slab)()
+	}
+
+	var inline *inlineVerifier
+	if job.VerifyInline {
+		if inline, err = newInlineVerifier(job, alignedBufferSize); err != nil {
+			return Result{}, err
+		}
+		defer inline.close()
+	}
+
+	// Patterns that are the same for every block are written from buffers
+	// that are filled up front and never touched again
+	if staticPattern(job.Pattern) {
+		for _, buf := range ring.buffers {
+			if err := fillPattern(job, buf, 0); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+
+	// Decide which blocks get written when not covering the whole device
+	selector := job.blockSelector()
+	if skipSeed != 0 && resume == nil && job.pass == 0 && job.gaps == nil {
+		fmt.Printf("Choosing blocks at random with seed %d (-skip-seed %d repeats the choice)\n", skipSeed, skipSeed)
+	}
+
+	// Track progress
+	bytesWritten := int64(0)
+	bytesProcessed := int64(0) // Track both written and skipped bytes
+
+	// Later passes of a partial wipe start further in; see passStart. The
+	// first block is written, unless a random selection skips some first.
+	bytesProcessed = job.passStart()
+	bytesProcessed = min(size, bytesProcessed+int64(bufferSize)*selector.skipRun())
+
+	// The second stage of -fill-gaps writes the blocks the pass skipped:
+	// those before its start, then those between its blocks
+	var lead int64
+	if job.gaps != nil {
+		lead = (bytesProcessed + int64(bufferSize) - 1) / int64(bufferSize)
+		selector = newGapSelector(cov, lead)
+		if job.SkipMode == skipModeRandom {
+			selector = newRandomSelector(cov, job.passSeed(), true)
+		}
+		bytesProcessed = 0
+		for !selector.next() {
+			bytesProcessed += int64(bufferSize)
+		}
+		bytesProcessed = min(size, bytesProcessed)
+		bytesWritten = job.gaps.written
+	}
+
+	// Pick up where an interrupted run stopped
+	resumedAt := int64(0)
+	if resume != nil {
+		resumedAt = resume.Offset
+		bytesProcessed = resume.Offset
+		bytesWritten = resume.BytesWritten
+		selector.acc = resume.SelectorState
+		if job.gaps != nil {
+			selector.lead = max(lead-resume.Offset/int64(bufferSize)-1, 0)
+		}
+	}
+	selectorState := selector.acc
+
+	// Random data can only be verified against a record of what was written,
+	// which also goes into the certificate
+	recordDigests := (job.Verify || job.Certificate != "") && !deterministicPattern(job.Pattern)
+	var digests []blockDigest
+	var badBlocks []int64
+	if job.gaps != nil {
+		digests = job.gaps.digests
+	}
+
+	// Fill buffers in the background while the previous ones are written
+	producerCtx, stopProducer := context.WithCancel(ctx)
+	blocks := make(chan pipelineBlock, job.PipelineDepth)
+	producerDone := make(chan struct{})
+	go func() {
+		defer close(producerDone)
+		produceBlocks(producerCtx, job, ring, bytesProcessed, selector, recordDigests, blocks)
+	}()
+	defer func() {
+		stopProducer()
+		<-producerDone
+	}()
+
+	passes := len(job.passPatterns())
+	stage := 0
+	if job.gaps != nil {
+		stage = 2
+	} else if job.fillsGaps() {
+		stage = 1
+	}
+	limiter := newPacer(job.Rate)
+	syncer := newPeriodicSync(job.Options)
+	startTime := time.Now()
+	lastUpdateTime := startTime
+	lastUpdateBytes := bytesProcessed
+
+	// The ETA uses the average speed over the last few updates
+	window := newSpeedWindow(job.ETAWindow)
+
+	// currentProgress describes the wipe as it stands at now: the speed
+	// since the previous update, for reference, and the ETA at the windowed
+	// average speed
+	currentProgress := func(now time.Time) Progress {
+		// Calculate speed based on processed bytes, not just written
+		instantSpeed := window.speed()
+		if elapsed := now.Sub(lastUpdateTime); elapsed > 0 {
+			instantSpeed = float64(bytesProcessed-lastUpdateBytes) / elapsed.Seconds()
+		}
+		var eta time.Duration
+		if speed := window.speed(); speed > 0 {
+			eta = time.Duration(float64(size-bytesProcessed)/speed) * time.Second
+		}
+		return Progress{
+			Device:         path,
+			BytesProcessed: bytesProcessed,
+			BytesWritten:   bytesWritten,
+			Total:          size,
+			Speed:          instantSpeed,
+			ETA:            eta,
+			Coverage:       cov,
+			Pass:           job.pass + 1,
+			Passes:         passes,
+			Stage:          stage,
+		}
+	}
+
+	// SIGUSR1 prints a progress snapshot at once, as it does for dd, even
+	// with -quiet
+	snapshots := make(chan os.Signal, 1)
+	signal.Notify(snapshots, syscall.SIGUSR1)
+	defer signal.Stop(snapshots)
+
+	updateInterval := job.ProgressInterval
+
+	// The checkpoint is also kept up to date while wiping, so that even a
+	// crash or power loss can be resumed from
+	progressCheckpoint := func() checkpoint {
+		cp := checkpoint{
+			Device:         path,
+			Size:           size,
+			Offset:         bytesProcessed,
+			BytesWritten:   bytesWritten,
+			BufferSize:     bufferSize,
+			Coverage:       cov,
+			Pattern:        job.Pattern,
+			SelectorState:  selectorState,
+			SkipSeed:       skipSeed,
+			Pass:           job.pass,
+			FillingGaps:    job.gaps != nil,
+			PartitionTable: job.partitionTable,
+			Run:            run,
+		}
+		// The checkpoint is for the whole disk
+		if job.window != nil {
+			cp.Size, cp.Partition = job.diskSize, job.window.Number
+		}
+		return cp
+	}
+	lastCheckpointTime := startTime
+	checkpointFailed := false
+
+	// -debug reports the first and last write, which show what the
+	// alignment of every write in between was
+	var firstWrite, lastWrite string
+
+	for bytesProcessed < size {
+		// Stop cleanly between blocks if we have been asked to. The
+		// producer only stops early once ctx is done.
+		block, ok := <-blocks
+		if ctx.Err() != nil || !ok {
+			result := Result{
+				Device:         path,
+				Size:           size,
+				BytesProcessed: bytesProcessed,
+				BytesWritten:   bytesWritten,
+				Coverage:       cov,
+				Duration:       time.Since(startTime),
+				ResumedAt:      resumedAt,
+			}
+			return result, interruptWipe(ctx, file, checkpointPath, progressCheckpoint())
+		}
+
+		if block.err != nil {
+			return Result{}, block.err
+		}
+
+		// Write the buffer to the device and hand it back for refilling
+		if debugEnabled {
+			lastWrite = describeWrite(block.buf[:block.length], job.physical(block.offset), job.blockSize)
+			if firstWrite == "" {
+				firstWrite = lastWrite
+				debugf("%s: first write: %s", path, firstWrite)
+			}
+		}
+		n, err := writeWithRetries(ctx, &file, job, serial, block.buf[:block.length], block.offset)
+		if err != nil {
+			debugf("%s: write of %s failed with %s", path, lastWrite, errnoName(err))
+		}
+		if errors.Is(err, ErrDeviceGone) || errors.Is(err, ErrDeviceResized) {
+			// Nothing can be synced any more, but the checkpoint lets
+			// -resume rewrite this block once the device is back
+			result := Result{
+				Device:         path,
+				Size:           size,
+				BytesProcessed: bytesProcessed,
+				BytesWritten:   bytesWritten,
+				Coverage:       cov,
+				Duration:       time.Since(startTime),
+				ResumedAt:      resumedAt,
+			}
+			gone := &DisconnectError{Path: path, Offset: bytesProcessed, Size: size, Err: err}
+			if checkpointPath != "" {
+				if err := writeCheckpoint(checkpointPath, progressCheckpoint()); err != nil {
+					fmt.Println()
+					warnf("Failed to write checkpoint: %v", err)
+				} else {
+					gone.Checkpoint = checkpointPath
+				}
+			}
+			return result, gone
+		}
+		if errors.Is(err, ErrNoSpace) {
+			// Only image files can run out of space: the image is sparse
+			// and the filesystem can't back the blocks being written
+			return Result{}, fmt.Errorf("%s: filesystem full after %s of %s, is the image sparse? %w",
+				path, FormatBytes(bytesProcessed), FormatBytes(size), err)
+		}
+		skipped := false
+		if err != nil && job.Retries > 0 && retryable(err) {
+			// A best-effort wipe of a dying disk carries on past blocks
+			// that can't be written
+			fmt.Println()
+			warnf("Giving up on %s at offset %d: %v", FormatBytes(int64(block.length)), job.physical(block.offset), err)
+			badBlocks = append(badBlocks, job.physical(block.offset))
+			skipped, n, err = true, 0, nil
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		// The buffer still holds exactly what was written until it is
+		// handed back
+		if inline != nil && !skipped {
+			if err := inline.check(block.buf[:n], block.offset); err != nil {
+				return Result{}, err
+			}
+		}
+		ring.free <- block.buf
+		if err := syncer.wrote(file); err != nil {
+			return Result{}, newDeviceError("sync", path, err)
+		}
+		if recordDigests && !skipped {
+			digests = append(digests, blockDigest{Offset: block.offset, Length: n, Sum: block.sum})
+		}
+		bytesWritten += int64(n)
+		bytesProcessed, selectorState = block.next, block.selectorState
+		limiter.wait(ctx, n)
+
+		// Show progress update if enough time has passed
+		currentTime := time.Now()
+		if currentTime.Sub(lastUpdateTime) >= updateInterval {
+			window.add(bytesProcessed-lastUpdateBytes, currentTime.Sub(lastUpdateTime))
+			report(currentProgress(currentTime))
+
+			// Update tracking variables
+			lastUpdateTime = currentTime
+			lastUpdateBytes = bytesProcessed
+		}
+
+		// A snapshot on request leaves the regular updates alone
+		select {
+		case <-snapshots:
+			fmt.Fprintf(os.Stderr, "\n[%s] %s\n", path, currentProgress(time.Now()))
+		default:
+		}
+
+		if checkpointPath != "" && currentTime.Sub(lastCheckpointTime) >= checkpointInterval {
+			if err := syncer.flush(file); err != nil {
+				return Result{}, newDeviceError("sync", path, err)
+			}
+			if err := writeCheckpoint(checkpointPath, progressCheckpoint()); err != nil && !checkpointFailed {
+				fmt.Println()
+				warnf("Failed to write checkpoint: %v", err)
+				checkpointFailed = true
+			}
+			lastCheckpointTime = currentTime
+		}
+
+		// Filling gaps is optional and ends with the time allowed for it
+		if job.gaps != nil && !job.gaps.deadline.IsZero() && currentTime.After(job.gaps.deadline) {
+			break
+		}
+	}
+
+	// Once every gap is filled, the whole device holds the pattern
+	if job.gaps != nil && bytesProcessed >= size {
+		cov = coverage{Num: 1, Den: 1}
+	}
+	if job.gaps != nil && recordDigests {
+		slices.SortFunc(digests, func(a, b blockDigest) int { return cmp.Compare(a.Offset, b.Offset) })
+	}
+
+	result := Result{
+		Device:         path,
+		Size:           size,
+		BytesProcessed: bytesProcessed,
+		BytesWritten:   bytesWritten,
+		Coverage:       cov,
+		Duration:       time.Since(startTime),
+		ResumedAt:      resumedAt,
+		Method:         methodOverwrite,
+		BadBlocks:      badBlocks,
+		SkipSeed:       skipSeed,
+		digests:        digests,
+	}
+	if recordDigests {
+		result.Digest, result.DigestAlgorithm = digestOf(job.Digest, digests), job.Digest
+	}
+
+	if lastWrite != "" {
+		debugf("%s: last write: %s", path, lastWrite)
+	}
+
+	// Add a final fsync at the end to ensure all data is written to disk
+	err = file.Sync()
+	if err != nil {
+		warnf("Final sync operation failed: %v", err)
+	}
+
+	if inline != nil {
+		if inline.verr != nil {
+			return result, inline.verr
+		}
+		result.Verified = true
+	}
+	return result, nil
+}
+
+// openForWipe opens path for writing with direct I/O unless opts.NoDirect
+// is set, and with O_SYNC unless opts choose another sync mode; see
+// openUnbuffered. Unless
+// opts.NoExcl is set, block devices are also opened with O_EXCL, so the
+// kernel refuses if the device is mounted, held by md or LVM, or being
+// wiped by another process, and keeps them from claiming it while the wipe
+// runs.
+func openForWipe(path string, opts Options) (*os.File, error) {
+	mode := os.O_WRONLY
+	if !opts.NoExcl {
+		mode |= syscall.O_EXCL
+	}
+	if opts.SyncMode == "" || opts.SyncMode == syncOSync {
+		mode |= syscall.O_SYNC
+	}
+	if opts.NoDirect {
+		return openBuffered(path, mode)
+	}
+	return openUnbuffered(path, mode)
+}
+
+// errInUse explains an exclusive open refused with EBUSY.
+var errInUse = errors.New("in use: mounted, part of an md RAID or LVM volume, or opened exclusively by another process (-no-excl overrides this)")
+
+// openSynced opens path with mode and O_SYNC for direct, synchronized I/O;
+// see openUnbuffered.
+func openSynced(path string, mode int) (*os.File, error) {
+	return openUnbuffered(path, mode|syscall.O_SYNC)
+}
+
+// openUnbuffered opens path with mode and direct I/O, falling back to
+// buffered I/O if direct I/O is not supported. Regular files such as disk
+// images always get buffered I/O: whether direct I/O works on them, and
+// with which alignment, depends on the filesystem they live on.
+func openUnbuffered(path string, mode int) (*os.File, error) {
+	if !isRegularFile(path) {
+		file, err := openDirect(path, mode)
+		if err == nil {
+			debugf("%s: opened with %s", path, openFlagNames(mode, true))
+			return file, nil
+		}
+		if mode&syscall.O_EXCL != 0 && errors.Is(err, syscall.EBUSY) {
+			return nil, newDeviceError("open", path, fmt.Errorf("%w: %w", ErrDeviceBusy, errInUse))
+		}
+		debugf("%s: opening with %s failed with %s", path, openFlagNames(mode, true), errnoName(err))
+		warnf("Direct I/O not supported, falling back to buffered I/O: %v", err)
+	}
+	return openBuffered(path, mode)
+}
+
+// openBuffered opens path with mode through the page cache.
+func openBuffered(path string, mode int) (*os.File, error) {
+	if isRegularFile(path) {
+		// O_EXCL without O_CREAT only means something for block devices
+		mode &^= syscall.O_EXCL
+	}
+	file, err := os.OpenFile(path, mode, 0)
+	if mode&syscall.O_EXCL != 0 && errors.Is(err, syscall.EBUSY) {
+		return nil, newDeviceError("open", path, fmt.Errorf("%w: %w", ErrDeviceBusy, errInUse))
+	}
+	if err != nil {
+		return nil, newDeviceError("open", path, err)
+	}
+	debugf("%s: opened with %s, through the page cache", path, openFlagNames(mode, false))
+	return file, nil
+}
+
+// interruptWipe flushes outstanding writes and records cp so that an
+// interrupted wipe leaves a consistent device and a record of its progress.
+func interruptWipe(ctx context.Context, file *os.File, checkpointPath string, cp checkpoint) error {
+	if err := file.Sync(); err != nil {
+		fmt.Println()
+		warnf("Sync after interruption failed: %v", err)
+	}
+
+	interrupted := &InterruptedError{Offset: cp.Offset, Err: context.Cause(ctx)}
+	if checkpointPath != "" {
+		if err := writeCheckpoint(checkpointPath, cp); err != nil {
+			fmt.Println()
+			warnf("Failed to write checkpoint: %v", err)
+		} else {
+			interrupted.Checkpoint = checkpointPath
+		}
+	}
+	return interrupted
+}
+
+// AllocAlignedBuffer maps size bytes of zeroed, page-aligned memory for
+// direct I/O. A buffer carved out of a Go slice would only stay aligned as
+// long as the garbage collector never moves it, which the language doesn't
+// promise; an anonymous mapping lives outside the Go heap, so its address is
+// fixed until FreeAlignedBuffer unmaps it. No slice of the buffer may be
+// used after that.
+func AllocAlignedBuffer(size int) ([]byte, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size %d", size)
+	}
+	buffer, err := syscall.Mmap(-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, fmt.Errorf("mmap %s: %w", FormatBytes(int64(size)), err)
+	}
+	return buffer, nil
+}
+
+// FreeAlignedBuffer releases a buffer from AllocAlignedBuffer.
+func FreeAlignedBuffer(buffer []byte) {
+	if err := syscall.Munmap(buffer); err != nil {
+		warnf("Cannot unmap buffer: %v", err)
+	}
+}
+
+// FormatDuration formats d, rounded to the second, as MM:SS, or as HH:MM:SS
+// from an hour up.
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	s := d / time.Second
+
+	if h > 0 {
+		return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%02d:%02d", m, s)
+}
+
+// FormatBytes formats a byte count with a binary unit, e.g. "1.5 GB" for 1.5
+// GiB.
+func FormatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,1827 @@
+package wipe
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		bytes int64
+		want  string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{1024*1024 - 1, "1024.0 KB"},
+		{1024 * 1024, "1.0 MB"},
+		{4 * 1024 * 1024, "4.0 MB"},
+		{1024 * 1024 * 1024, "1.0 GB"},
+		{500107862016, "465.8 GB"},
+		{1 << 40, "1.0 TB"},
+		{1 << 50, "1.0 PB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.bytes); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.bytes, got, tt.want)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "00:00"},
+		{400 * time.Millisecond, "00:00"},
+		{500 * time.Millisecond, "00:01"},
+		{59 * time.Second, "00:59"},
+		{time.Minute, "01:00"},
+		{59*time.Minute + 59*time.Second, "59:59"},
+		{time.Hour, "01:00:00"},
+		{20*time.Hour + 3*time.Minute + 7*time.Second, "20:03:07"},
+		{100 * time.Hour, "100:00:00"},
+	}
+	for _, tt := range tests {
+		if got := FormatDuration(tt.d); got != tt.want {
+			t.Errorf("FormatDuration(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// tempImage creates a zero-filled file of size bytes and returns its path.
+func tempImage(t *testing.T, size int64) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, size); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGetDeviceSize(t *testing.T) {
+	for _, size := range []int64{1, 4096, 1000003, 64 << 20} {
+		got, err := DeviceSize(tempImage(t, size))
+		if err != nil {
+			t.Fatalf("DeviceSize of a %d byte file: %v", size, err)
+		}
+		if got != size {
+			t.Errorf("DeviceSize of a %d byte file = %d", size, got)
+		}
+	}
+
+	if _, err := DeviceSize(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeviceSize of a missing file = %v, want ErrNotFound", err)
+	}
+}
+
+func TestPrepareJobRejectsZeroSize(t *testing.T) {
+	job := wipeJob{Device: tempImage(t, 0), Options: Options{}.withDefaults()}
+	if err := prepareJob(context.Background(), &job, true); !errors.Is(err, ErrNoMedia) {
+		t.Errorf("prepareJob of an empty device = %v, want ErrNoMedia", err)
+	}
+}
+
+func TestWipeDevice(t *testing.T) {
+	const block = 4096
+	tests := []struct {
+		name string
+		size int64
+		skip int
+	}{
+		{"aligned", 16 * block, 1},
+		{"unaligned tail", 16*block + 100, 1},
+		{"smaller than a block", 100, 1},
+		{"skip, size a multiple of the stride", 12 * block, 3},
+		{"skip, last stride cut short", 13 * block, 3},
+		{"skip, last block written and partial", 12*block + 100, 3},
+		{"skip, partial block skipped", 11*block + 100, 3},
+		{"skip larger than the device", 5 * block, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tempImage(t, tt.size)
+			job := wipeJob{
+				Device:  path,
+				Options: Options{BufferSize: block, SkipFactor: tt.skip, Pattern: patternOne}.withDefaults(),
+			}
+			job.size = tt.size
+
+			result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+			if err != nil {
+				t.Fatalf("wipeDevice: %v", err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if int64(len(data)) != tt.size {
+				t.Fatalf("file grew from %d to %d bytes", tt.size, len(data))
+			}
+
+			// Exactly the first block of every stride is written
+			var wantWritten int64
+			for i := int64(0); i*block < tt.size; i++ {
+				chunk := data[i*block : min(tt.size, (i+1)*block)]
+				want := byte(0)
+				if i%int64(tt.skip) == 0 {
+					want = 0xFF
+					wantWritten += int64(len(chunk))
+				}
+				if !bytes.Equal(chunk, bytes.Repeat([]byte{want}, len(chunk))) {
+					t.Errorf("block %d: want every byte %#x", i, want)
+				}
+			}
+			if result.BytesWritten != wantWritten {
+				t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, wantWritten)
+			}
+			if result.BytesProcessed != tt.size {
+				t.Errorf("BytesProcessed = %d, want %d", result.BytesProcessed, tt.size)
+			}
+
+			if err := verifyDevice(context.Background(), job, result, func(Progress) {}); err != nil {
+				t.Errorf("verifyDevice: %v", err)
+			}
+		})
+	}
+}
+
+func TestWipeDeviceRandomVerifies(t *testing.T) {
+	const size = 3*1024*1024 + 12345
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: 1024 * 1024, Verify: true}.withDefaults(),
+	}
+	job.size = size
+
+	result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("wipeDevice: %v", err)
+	}
+	if result.BytesWritten != size {
+		t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, size)
+	}
+	if err := verifyDevice(context.Background(), job, result, func(Progress) {}); err != nil {
+		t.Fatalf("verifyDevice: %v", err)
+	}
+
+	// Changing a byte must be caught
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := make([]byte, 1)
+	if _, err := file.ReadAt(last, size-1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteAt([]byte{^last[0]}, size-1); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	var verr *VerifyError
+	if err := verifyDevice(context.Background(), job, result, func(Progress) {}); !errors.As(err, &verr) {
+		t.Fatalf("verifyDevice after corruption = %v, want a *VerifyError", err)
+	}
+}
+
+// writtenBlocks returns the indexes of the blockSize blocks of the file at
+// path that hold 0xFF bytes, failing the test for blocks that are only
+// partly written.
+func writtenBlocks(t *testing.T, path string, blockSize int) []int64 {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []int64
+	for i := 0; i*blockSize < len(data); i++ {
+		chunk := data[i*blockSize : min(len(data), (i+1)*blockSize)]
+		switch {
+		case bytes.Equal(chunk, bytes.Repeat([]byte{0xFF}, len(chunk))):
+			written = append(written, int64(i))
+		case !bytes.Equal(chunk, make([]byte, len(chunk))):
+			t.Errorf("block %d is only partly written", i)
+		}
+	}
+	return written
+}
+
+func TestWipeDeviceWrittenOffsets(t *testing.T) {
+	const block = 4096
+	tests := []struct {
+		name     string
+		size     int64
+		skip     int
+		coverage float64
+		passes   int
+		pass     int
+		want     []int64 // indexes of the written blocks
+	}{
+		{name: "skip 2", size: 7 * block, skip: 2, want: []int64{0, 2, 4, 6}},
+		{name: "skip 2, partial last block", size: 6*block + 1, skip: 2, want: []int64{0, 2, 4, 6}},
+		{name: "skip 4", size: 10 * block, skip: 4, want: []int64{0, 4, 8}},
+		{name: "skip equal to the size", size: 4 * block, skip: 4, want: []int64{0}},
+		{name: "coverage 0.4", size: 12 * block, coverage: 0.4, want: []int64{0, 3, 5, 8, 10}},
+		{name: "coverage 0.75", size: 10*block + 10, coverage: 0.75, want: []int64{0, 2, 3, 4, 6, 7, 8, 10}},
+		{name: "second of two passes, skip 4", size: 10 * block, skip: 4, passes: 2, pass: 1, want: []int64{2, 6}},
+		{name: "third of four passes, skip 4", size: 9 * block, skip: 4, passes: 4, pass: 2, want: []int64{2, 6}},
+		{name: "shift past the end", size: 2 * block, skip: 8, passes: 2, pass: 1, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tempImage(t, tt.size)
+			job := wipeJob{
+				Device: path,
+				Options: Options{
+					BufferSize: block,
+					SkipFactor: tt.skip,
+					Coverage:   tt.coverage,
+					Passes:     tt.passes,
+					Pattern:    patternOne,
+				}.withDefaults(),
+			}
+			job.size, job.pass = tt.size, tt.pass
+
+			result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+			if err != nil {
+				t.Fatalf("wipeDevice: %v", err)
+			}
+
+			got := writtenBlocks(t, path, block)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("written blocks = %v, want %v", got, tt.want)
+			}
+			var wantWritten int64
+			for _, i := range tt.want {
+				wantWritten += min(tt.size, (i+1)*block) - i*block
+			}
+			if result.BytesWritten != wantWritten {
+				t.Errorf("BytesWritten = %d, want %d", result.BytesWritten, wantWritten)
+			}
+			if result.BytesProcessed != tt.size {
+				t.Errorf("BytesProcessed = %d, want %d", result.BytesProcessed, tt.size)
+			}
+		})
+	}
+}
+
+func TestNVMeAdminCmdLayout(t *testing.T) {
+	// struct nvme_passthru_cmd is 72 bytes, and the ioctl number encodes it
+	size := unsafe.Sizeof(nvmeAdminCmd{})
+	encoded := uintptr(nvmeIoctlAdminCmd >> 16 & 0x3fff)
+	if size != 72 || encoded != size {
+		t.Errorf("nvmeAdminCmd is %d bytes, NVME_IOCTL_ADMIN_CMD encodes %d", size, encoded)
+	}
+}
+
+func TestATAPassThrough(t *testing.T) {
+	// struct sg_io_hdr is 88 bytes on 64-bit Linux
+	if size := unsafe.Sizeof(sgIOHdr{}); unsafe.Sizeof(uintptr(0)) == 8 && size != 88 {
+		t.Errorf("sgIOHdr is %d bytes, want 88", size)
+	}
+
+	sector := ataPasswordSector(0x2)
+	if len(sector) != 512 || sector[0] != 0x2 || sector[1] != 0 {
+		t.Fatalf("password sector starts with %#x %#x, want the control word 0x2", sector[0], sector[1])
+	}
+	if got := string(bytes.TrimRight(sector[2:34], "\x00")); got != ataErasePassword {
+		t.Errorf("password = %q, want %q", got, ataErasePassword)
+	}
+	if !bytes.Equal(sector[34:], make([]byte, 512-34)) {
+		t.Error("password sector has data past the password")
+	}
+}
+
+func TestBenchmarkWriteSpeedStaysWithinDevice(t *testing.T) {
+	const buffer = 64 * 1024
+	if _, err := BenchmarkWriteSpeed(context.Background(), tempImage(t, buffer+4096), Options{BufferSize: buffer, RandomRefresh: 1, RNG: rngChaCha}); !errors.Is(err, errTooSmallToBenchmark) {
+		t.Errorf("benchmark of a device smaller than two buffers = %v, want errTooSmallToBenchmark", err)
+	}
+
+	for _, size := range []int64{2 * buffer, 3*buffer + 100} {
+		path := tempImage(t, size)
+		if _, err := BenchmarkWriteSpeed(context.Background(), path, Options{BufferSize: buffer, RandomRefresh: 1, RNG: rngChaCha}); err != nil {
+			t.Fatalf("benchmark of a %d byte device: %v", size, err)
+		}
+		if got, err := DeviceSize(path); err != nil || got != size {
+			t.Errorf("benchmark changed the size of a %d byte device to %d (%v)", size, got, err)
+		}
+	}
+}
+
+func TestApplyConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quickwipe.toml")
+	config := "# policy\n" +
+		"device = [\"/dev/sdb\", '/dev/sdc'] # two drives\n" +
+		"buffer = \"8M\"\n" +
+		"passes = 3\n" +
+		"verify = true # read back\n" +
+		"\n" +
+		"pattern = zero\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+	var devices deviceListFlag
+	fs.Var(&devices, "device", "")
+	buffer := fs.String("buffer", "4M", "")
+	passes := fs.Int("passes", 1, "")
+	verify := fs.Bool("verify", false, "")
+	pattern := fs.String("pattern", "random", "")
+	if err := fs.Parse([]string{"-passes", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfigFile(path, fs); err != nil {
+		t.Fatalf("applyConfigFile: %v", err)
+	}
+	if !slices.Equal(devices, []string{"/dev/sdb", "/dev/sdc"}) || *buffer != "8M" || !*verify || *pattern != "zero" {
+		t.Errorf("got devices %v, buffer %q, verify %t, pattern %q", devices, *buffer, *verify, *pattern)
+	}
+	if *passes != 2 {
+		t.Errorf("passes = %d, want the command-line value 2", *passes)
+	}
+
+	for _, bad := range []string{"nonsense = 1\n", "passes\n", "passes = three\n", "buffer = \"8M\n", "passes = 1\npasses = 2\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fs := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+		fs.Int("passes", 1, "")
+		fs.String("buffer", "4M", "")
+		if err := applyConfigFile(path, fs); err == nil {
+			t.Errorf("applyConfigFile accepted %q", bad)
+		}
+	}
+}
+
+func TestHiddenAreas(t *testing.T) {
+	tests := []struct {
+		areas    hiddenAreas
+		hpa, dco uint64
+	}{
+		{hiddenAreas{Accessible: 1000, Native: 1000}, 0, 0},
+		{hiddenAreas{Accessible: 900, Native: 1000}, 100, 0},
+		{hiddenAreas{Accessible: 900, Native: 1000, DCO: 1200}, 100, 200},
+		{hiddenAreas{Accessible: 1000, Native: 1000, DCO: 1000}, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.areas.hpa(); got != tt.hpa {
+			t.Errorf("%+v: hpa() = %d, want %d", tt.areas, got, tt.hpa)
+		}
+		if got := tt.areas.dco(); got != tt.dco {
+			t.Errorf("%+v: dco() = %d, want %d", tt.areas, got, tt.dco)
+		}
+	}
+}
+
+func TestVerifySample(t *testing.T) {
+	const block = 4096
+	for _, pattern := range []string{patternOne, patternRandom} {
+		t.Run(pattern, func(t *testing.T) {
+			const size = 40*block + 100
+			path := tempImage(t, size)
+			job := wipeJob{
+				Device:  path,
+				Options: Options{BufferSize: block, SkipFactor: 2, Pattern: pattern, Verify: true, VerifySample: 5}.withDefaults(),
+			}
+			job.size = size
+
+			result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+			if err != nil {
+				t.Fatalf("wipeDevice: %v", err)
+			}
+			samples := pickSamples(job, result.digests, job.VerifySample)
+			if len(samples) != 5 {
+				t.Fatalf("picked %d samples, want 5", len(samples))
+			}
+			for i, s := range samples {
+				if s.Offset%(2*block) != 0 || (i > 0 && s.Offset <= samples[i-1].Offset) {
+					t.Errorf("sample offsets %v: want distinct written blocks in order", samples)
+					break
+				}
+			}
+			if n, err := verifySample(context.Background(), job, result); err != nil || n != 5 {
+				t.Fatalf("verifySample = %d, %v", n, err)
+			}
+
+			// Sampling at least as many blocks as were written checks all of them
+			if got := pickSamples(job, result.digests, 100); len(got) != 21 {
+				t.Errorf("picked %d of the 21 written blocks", len(got))
+			}
+			// Corrupting every written block must be caught by any sample
+			data := bytes.Repeat([]byte{0x5A}, size)
+			if err := os.WriteFile(path, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			var verr *VerifyError
+			if _, err := verifySample(context.Background(), job, result); !errors.As(err, &verr) || len(verr.Blocks) != 5 {
+				t.Errorf("verifySample after corruption = %v, want a *VerifyError for all 5 samples", err)
+			}
+		})
+	}
+}
+
+func TestConfirmsDevice(t *testing.T) {
+	tests := []struct {
+		response, serial string
+		want             bool
+	}{
+		{"/dev/sdb", "WD-123", true},
+		{" /dev/sdb ", "", true},
+		{"WD-123", "WD-123", true},
+		{"YES", "WD-123", false},
+		{"sdb", "WD-123", false},
+		{"/dev/sdc", "WD-123", false},
+		{"", "", false},
+		{"wd-123", "WD-123", false},
+	}
+	for _, tt := range tests {
+		if got := confirmsDevice(tt.response, "/dev/sdb", tt.serial); got != tt.want {
+			t.Errorf("confirmsDevice(%q, /dev/sdb, %q) = %t, want %t", tt.response, tt.serial, got, tt.want)
+		}
+	}
+}
+
+func TestActivityLogJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quickwipe.log")
+	activity, err := openActivityLog(path, 1<<20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activity.event("run started", "devices", []string{"/dev/sdb"})
+	activity.progress(Progress{Device: "/dev/sdb", BytesProcessed: 50, Total: 200, Pass: 1, Passes: 2})
+	activity.progress(Progress{Device: "/dev/sdb", BytesProcessed: 100, Total: 200, Pass: 1, Passes: 2})
+	activity.progress(Progress{Device: "/dev/sdb", BytesProcessed: 100, Total: 200})
+	activity.failure("wipe failed", "device", "/dev/sdb", "error", "boom")
+	activity.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var record struct {
+			Time  time.Time `json:"time"`
+			Level string    `json:"level"`
+			Msg   string    `json:"msg"`
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if record.Time.IsZero() || record.Level == "" {
+			t.Errorf("log line %q lacks a time or level", line)
+		}
+		msgs = append(msgs, record.Msg)
+	}
+	want := []string{"run started", "pass started", "progress", "progress", "verify started", "progress", "wipe failed"}
+	if !slices.Equal(msgs, want) {
+		t.Errorf("logged %q, want %q", msgs, want)
+	}
+
+	var nilLog *activityLog
+	nilLog.event("ignored")
+	nilLog.progress(Progress{Device: "/dev/sdb", Total: 1})
+}
+
+func TestSpeedWindow(t *testing.T) {
+	w := newSpeedWindow(3)
+	if got := w.speed(); got != 0 {
+		t.Errorf("speed of an empty window = %v, want 0", got)
+	}
+	w.add(100, time.Second)
+	w.add(300, time.Second)
+	if got := w.speed(); got != 200 {
+		t.Errorf("speed = %v, want 200", got)
+	}
+	// A dip moves the average by a third at most
+	w.add(0, time.Second)
+	if got := w.speed(); got != 400.0/3 {
+		t.Errorf("speed = %v, want %v", got, 400.0/3)
+	}
+	// The oldest sample drops out
+	w.add(600, 2*time.Second)
+	if got := w.speed(); got != 900.0/4 {
+		t.Errorf("speed = %v, want %v", got, 900.0/4)
+	}
+	if got := newSpeedWindow(0); cap(got.bytes) != 1 {
+		t.Errorf("window of size 0 holds %d samples, want 1", cap(got.bytes))
+	}
+}
+
+func TestRunEstimate(t *testing.T) {
+	job := wipeJob{Options: Options{SkipFactor: 1, Pattern: patternRandom, Passes: 2, Verify: true}, size: 100}
+	job.estimate = estimateJob(job, 10, 20)
+	if want := (jobEstimate{passes: 2, pass: 10 * time.Second, verify: 5 * time.Second}); *job.estimate != want {
+		t.Fatalf("estimateJob = %+v, want %+v", *job.estimate, want)
+	}
+	if estimateJob(job, 0, 20) != nil {
+		t.Error("estimateJob without a speed returned an estimate")
+	}
+	other := job
+	other.estimate = &jobEstimate{passes: 1, pass: 30 * time.Second}
+
+	if got := newRunEstimate([]wipeJob{job, other}, false).timeLeft(); got != 55*time.Second {
+		t.Errorf("sequential estimate = %v, want 55s", got)
+	}
+	if got := newRunEstimate([]wipeJob{job, other}, true).timeLeft(); got != 30*time.Second {
+		t.Errorf("parallel estimate = %v, want 30s", got)
+	}
+	if newRunEstimate([]wipeJob{job, {}}, false) != nil {
+		t.Error("a job without an estimate still gave the run one")
+	}
+
+	// The first pass of the first job is half done: its other pass and
+	// verify, and all of the second job, are still to come
+	r := newRunEstimate([]wipeJob{job, other}, false)
+	var got Progress
+	report := r.progress(0, func(u Progress) { got = u })
+	report(Progress{Pass: 1, Passes: 2, ETA: 5 * time.Second})
+	if got.TotalETA != 50*time.Second {
+		t.Errorf("TotalETA during the first pass = %v, want 50s", got.TotalETA)
+	}
+	report(Progress{ETA: 2 * time.Second}) // verifying
+	if got.TotalETA != 32*time.Second {
+		t.Errorf("TotalETA during the verify = %v, want 32s", got.TotalETA)
+	}
+	r.finish(0)
+	if left := r.timeLeft(); left != 30*time.Second {
+		t.Errorf("time left after the first job = %v, want 30s", left)
+	}
+
+	// A resumed job only counts what is left of it
+	job.resumeFrom = &checkpoint{Pass: 1, Offset: 50}
+	if left := newRunEstimate([]wipeJob{job}, false).timeLeft(); left != 10*time.Second {
+		t.Errorf("resumed estimate = %v, want 10s", left)
+	}
+
+	// A single pass has nothing to add to its own ETA
+	single := wipeJob{estimate: &jobEstimate{passes: 1, pass: time.Second}}
+	var plain Progress
+	newRunEstimate([]wipeJob{single}, false).progress(0, func(u Progress) { plain = u })(Progress{Pass: 1, Passes: 1, ETA: time.Second})
+	if plain.TotalETA != 0 {
+		t.Errorf("TotalETA of a single pass = %v, want 0", plain.TotalETA)
+	}
+}
+
+func TestPrintDisks(t *testing.T) {
+	for name, want := range map[string]bool{"loop0": true, "dm-1": true, "zram0": true, "sr0": true, "sda": false, "nvme0n1": false, "vdb": false} {
+		if got := isVirtualDisk(name); got != want {
+			t.Errorf("isVirtualDisk(%q) = %t, want %t", name, got, want)
+		}
+	}
+
+	var out bytes.Buffer
+	err := printDisks(&out, []candidateDisk{
+		{Path: "/dev/sda", Model: "Samsung SSD 870", Serial: "S5XY", Size: 500107862016, Type: "SSD",
+			InUse: []mountInfo{{Device: "/dev/sda1", MountPoint: "/"}, {Device: "/dev/sda2", Swap: true}}},
+		{Path: "/dev/sdb", Size: 1 << 30},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "DEVICE    MODEL            SERIAL  SIZE      TYPE  IN USE\n" +
+		"/dev/sda  Samsung SSD 870  S5XY    465.8 GB  SSD   /dev/sda1 on /, /dev/sda2 (swap)\n" +
+		"/dev/sdb  -                -       1.0 GB    -     -\n"
+	if out.String() != want {
+		t.Errorf("printDisks wrote\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	u := Progress{BytesProcessed: 512 << 20, Total: 1 << 30, Speed: 100 << 20, ETA: 5 * time.Second, Coverage: coverage{Num: 1, Den: 1}}
+	got := u.bar(80)
+	want := "[#############-------------] 50.00% (512.0 MB/1.0 GB) at 100.00 MB/s, ETA: 00:05"
+	if got != want || len(got) != 80 {
+		t.Errorf("bar(80) =\n%q, want\n%q", got, want)
+	}
+
+	// Narrow terminals keep a minimal bar and cut the line off
+	if got := u.bar(20); len(got) != 20 || got[:minBarWidth+2] != "[#####-----]" {
+		t.Errorf("bar(20) = %q", got)
+	}
+
+	u.Pass, u.Passes, u.BytesProcessed = 2, 3, 1<<30
+	if got := u.bar(120); !strings.HasPrefix(got, "Pass 2/3: [###") || strings.Contains(got, "-") || len(got) > 120 {
+		t.Errorf("bar(120) for a finished second pass = %q", got)
+	}
+}
+
+func TestSummaryFile(t *testing.T) {
+	const block = 4096
+	const size = 16 * block
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: block, SkipFactor: 2, Pattern: patternOne, Passes: 2}.withDefaults(),
+		Summary: filepath.Join(t.TempDir(), "summary.json"),
+	}
+	job.size = size
+
+	readSummary := func() wipeSummary {
+		t.Helper()
+		data, err := os.ReadFile(job.Summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var summary wipeSummary
+		if err := json.Unmarshal(data, &summary); err != nil {
+			t.Fatal(err)
+		}
+		return summary
+	}
+
+	if _, err := runJob(context.Background(), job, nil, runInfo{}, func(Progress) {}); err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	got := readSummary()
+	want := wipeSummary{
+		Status:          summaryCompleted,
+		Device:          path,
+		Method:          methodOverwrite,
+		BytesProcessed:  size,
+		BytesWritten:    size / 2,
+		CoveragePercent: 50,
+		Passes:          2,
+		Pattern:         "one,one",
+	}
+	if got.ElapsedSeconds < 0 || got.AverageSpeed <= 0 {
+		t.Errorf("elapsed %v s at %v B/s, want a time and speed", got.ElapsedSeconds, got.AverageSpeed)
+	}
+	got.ElapsedSeconds, got.AverageSpeed = 0, 0
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("summary = %+v, want %+v", got, want)
+	}
+
+	// An interrupted wipe still writes one, saying so
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := runJob(ctx, job, nil, runInfo{}, func(Progress) {})
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("runJob with a cancelled context = %v, want an *InterruptedError", err)
+	}
+	if got := readSummary(); got.Status != summaryInterrupted || got.Passes != 1 {
+		t.Errorf("summary = %+v, want the first pass interrupted", got)
+	}
+}
+
+func TestFillGaps(t *testing.T) {
+	// The gap selector picks exactly the blocks a pass skipped
+	for _, tt := range []struct {
+		cov  coverage
+		lead int64
+	}{{skipCoverage(4), 0}, {coverage{Num: 3, Den: 10}, 0}, {skipCoverage(5), 2}} {
+		pass, gaps := newBlockSelector(tt.cov), newGapSelector(tt.cov, tt.lead)
+		pass.next() // the first block of the pass
+		for i := int64(0); i < 100; i++ {
+			written := i >= tt.lead && (i == tt.lead || pass.next())
+			if gaps.next() == written {
+				t.Fatalf("%v from block %d: block %d written by both stages or neither", tt.cov, tt.lead, i)
+			}
+		}
+	}
+
+	const block = 4096
+	const size = 30*block + 100
+	for _, passes := range []int{1, 2} {
+		path := tempImage(t, size)
+		job := wipeJob{
+			Device:  path,
+			Options: Options{BufferSize: block, SkipFactor: 4, Pattern: patternOne, Passes: passes, FillGaps: true, Verify: true}.withDefaults(),
+		}
+		job.size = size
+
+		result, err := runJob(context.Background(), job, nil, runInfo{}, func(Progress) {})
+		if err != nil {
+			t.Fatalf("%d passes: runJob: %v", passes, err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, bytes.Repeat([]byte{0xFF}, size)) {
+			t.Errorf("%d passes: gaps left unwritten", passes)
+		}
+		if result.BytesWritten != size || !result.Coverage.full() || !result.Verified {
+			t.Errorf("%d passes: wrote %d bytes, coverage %v, verified %t; want the whole device", passes,
+				result.BytesWritten, result.Coverage, result.Verified)
+		}
+	}
+
+	// Past the deadline the second stage stops after a block
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: block, SkipFactor: 4, Pattern: patternOne}.withDefaults(),
+		gaps:    &gapFill{written: 8 * block, deadline: time.Now().Add(-time.Second)},
+	}
+	job.size = size
+	result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("wipeDevice: %v", err)
+	}
+	if result.BytesWritten != 9*block || result.Coverage.full() {
+		t.Errorf("wrote %d bytes with coverage %v, want one gap block more than the pass", result.BytesWritten, result.Coverage)
+	}
+	if data, _ := os.ReadFile(path); data[block] != 0xFF || data[0] != 0 || data[2*block] != 0 {
+		t.Errorf("want only the first gap, at offset %d, written", block)
+	}
+}
+
+func TestPeriodicSync(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "image"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	tests := []struct {
+		opts    Options
+		pending []int // after each of five writes
+	}{
+		{Options{}.withDefaults(), []int{0, 0, 0, 0, 0}},
+		{Options{SyncMode: syncFdatasync, SyncEvery: 2}, []int{1, 0, 1, 0, 1}},
+		{Options{SyncMode: syncNone}, []int{1, 2, 3, 4, 5}},
+	}
+	for _, tt := range tests {
+		s := newPeriodicSync(tt.opts)
+		for i, want := range tt.pending {
+			if err := s.wrote(file); err != nil {
+				t.Fatalf("%s: wrote: %v", tt.opts.SyncMode, err)
+			}
+			if s.pending != want {
+				t.Errorf("%s: %d writes pending after write %d, want %d", tt.opts.SyncMode, s.pending, i+1, want)
+			}
+		}
+		// A checkpoint flushes whatever is pending
+		if err := s.flush(file); err != nil || s.pending != 0 {
+			t.Errorf("%s: flush left %d writes pending (%v)", tt.opts.SyncMode, s.pending, err)
+		}
+	}
+}
+
+func TestMaxDuration(t *testing.T) {
+	const block = 4096
+	const size = 256 * block
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device: path,
+		// 1 MB at 2 MB/s takes half a second, well past the limit
+		Options: Options{BufferSize: block, Pattern: patternZero, Rate: 2 << 20, MaxDuration: 100 * time.Millisecond,
+			Checkpoint: filepath.Join(t.TempDir(), "checkpoint")}.withDefaults(),
+	}
+	job.size = size
+
+	result, err := runJob(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) || !errors.Is(err, errMaxDuration) {
+		t.Fatalf("runJob = %v, want an interruption by -max-duration", err)
+	}
+	if code := exitCodeFor(err); code != exitTimeLimit {
+		t.Errorf("exit code %d, want %d", code, exitTimeLimit)
+	}
+	if result.BytesProcessed <= 0 || result.BytesProcessed >= size {
+		t.Errorf("stopped after %d of %d bytes", result.BytesProcessed, size)
+	}
+	if interrupted.Checkpoint != job.Checkpoint {
+		t.Errorf("checkpoint %q, want %q", interrupted.Checkpoint, job.Checkpoint)
+	}
+}
+
+func TestStaticPatternFilledOnce(t *testing.T) {
+	const block, blocks, depth = 4096, 16, 2
+	produce := func(pattern string) (rewritten int) {
+		job := wipeJob{Options: Options{BufferSize: block, Pattern: pattern, PipelineDepth: depth}.withDefaults()}
+		job.size = blocks * block
+		ring, err := newBufferRing(depth, block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ring.release()
+		// Mark the buffers as wipeDevice leaves them once it has filled them
+		// up front; a refill would overwrite the mark
+		for _, buf := range ring.buffers {
+			if err := fillPattern(job, buf, 0); err != nil {
+				t.Fatal(err)
+			}
+			buf[0] = 0x5A
+		}
+
+		out := make(chan pipelineBlock, depth)
+		go produceBlocks(context.Background(), job, ring, 0, job.blockSelector(), false, out)
+		written := 0
+		for b := range out {
+			if b.err != nil {
+				t.Fatal(b.err)
+			}
+			if b.buf[0] != 0x5A {
+				rewritten++
+			}
+			written++
+			ring.free <- b.buf
+		}
+		if written != blocks {
+			t.Errorf("%s: produced %d blocks, want %d", pattern, written, blocks)
+		}
+		return rewritten
+	}
+
+	for _, pattern := range []string{patternZero, patternOne, "0xAA"} {
+		if n := produce(pattern); n != 0 {
+			t.Errorf("%s: %d of %d blocks were refilled, want the buffers filled once", pattern, n, blocks)
+		}
+	}
+	// Patterns that depend on the offset are refilled for every block
+	if n := produce(patternCounter); n != blocks {
+		t.Errorf("counter: %d of %d blocks were refilled, want all", n, blocks)
+	}
+}
+
+func TestPatternFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pattern")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPatternFile(path); err == nil {
+		t.Error("readPatternFile accepted an empty file")
+	}
+	if err := os.WriteFile(path, []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := readPatternFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The payload continues across blocks, whatever their size
+	job := wipeJob{Options: Options{Pattern: patternFile, PatternFile: path}, patternData: data}
+	buf := make([]byte, 8)
+	if err := fillPattern(job, buf, 4); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "bcabcabc"; got != want {
+		t.Errorf("block at offset 4 = %q, want %q", got, want)
+	}
+}
+
+func TestEntropyCheck(t *testing.T) {
+	random := make([]byte, 4096)
+	if err := fillRandom(rngChaCha, random); err != nil {
+		t.Fatal(err)
+	}
+	if e := shannonEntropy(random); e < minEntropy {
+		t.Errorf("entropy of 4 KB random data = %.3f, below %v", e, minEntropy)
+	}
+	if e := shannonEntropy(make([]byte, 4096)); e != 0 {
+		t.Errorf("entropy of zeros = %.3f, want 0", e)
+	}
+
+	c := newEntropyCheck()
+	c.add(0, random)
+	c.add(4096, make([]byte, 4096))
+	if c.checked != 2 || c.low != 1 || c.lowestAt != 4096 {
+		t.Errorf("entropyCheck = %+v, want one low block of two at offset 4096", *c)
+	}
+}
+
+func TestAllocAlignedBuffer(t *testing.T) {
+	for _, size := range []int{512, 4096, 1 << 20, 1<<20 + 512} {
+		buf, err := AllocAlignedBuffer(size)
+		if err != nil {
+			t.Fatalf("AllocAlignedBuffer(%d): %v", size, err)
+		}
+		if len(buf) != size {
+			t.Errorf("AllocAlignedBuffer(%d) has length %d", size, len(buf))
+		}
+		if addr := uintptr(unsafe.Pointer(&buf[0])); addr%4096 != 0 {
+			t.Errorf("AllocAlignedBuffer(%d) at %#x is not page-aligned", size, addr)
+		}
+		if !bytes.Equal(buf, make([]byte, size)) {
+			t.Errorf("AllocAlignedBuffer(%d) is not zeroed", size)
+		}
+		FreeAlignedBuffer(buf)
+	}
+}
+
+func TestPreserveTable(t *testing.T) {
+	const sector = 512
+	const size = 8192 * sector
+	disk := bytes.Repeat([]byte{0xff}, size)
+	entry := func(table []byte, i int, kind byte, start, count uint32) {
+		e := table[446+16*i:]
+		e[4] = kind
+		binary.LittleEndian.PutUint32(e[8:], start)
+		binary.LittleEndian.PutUint32(e[12:], count)
+	}
+	// Two primary partitions and an extended one holding a logical one
+	mbr := make([]byte, sector)
+	entry(mbr, 0, 0x83, 2048, 1024)
+	entry(mbr, 1, 0x83, 4096, 1024)
+	entry(mbr, 2, 0x05, 6144, 2048)
+	mbr[510], mbr[511] = 0x55, 0xaa
+	ebr := make([]byte, sector)
+	entry(ebr, 0, 0x83, 8, 1024)
+	ebr[510], ebr[511] = 0x55, 0xaa
+	copy(disk, mbr)
+	copy(disk[6144*sector:], ebr)
+	path := filepath.Join(t.TempDir(), "disk.img")
+	if err := os.WriteFile(path, disk, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	job := wipeJob{
+		Device:        path,
+		Options:       Options{BufferSize: 64 << 10, Pattern: patternZero, Verify: true}.withDefaults(),
+		PreserveTable: true,
+	}
+	job.size = size
+	if err := findExtents(&job); err != nil {
+		t.Fatal(err)
+	}
+	want := []partitionExtent{
+		{Number: 1, Start: 2048 * sector, Length: 1024 * sector},
+		{Number: 2, Start: 4096 * sector, Length: 1024 * sector},
+		{Number: 5, Start: 6152 * sector, Length: 1024 * sector},
+	}
+	if !slices.Equal(job.extents, want) {
+		t.Fatalf("extents = %v, want %v", job.extents, want)
+	}
+
+	result, err := runJob(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Verified || result.BytesWritten != 3*1024*sector {
+		t.Errorf("result = %+v, want 1.5 MB written and verified", result)
+	}
+
+	// Only the partitions are zeroed; the MBR, the EBR and the gaps between
+	// the partitions are left as they were
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range want {
+		copy(disk[e.Start:e.Start+e.Length], make([]byte, e.Length))
+	}
+	if !bytes.Equal(got, disk) {
+		t.Error("wipe touched the disk outside its partitions")
+	}
+}
+
+func TestAssumeYes(t *testing.T) {
+	// Unlike -force, -assume-yes refuses what it would otherwise ask about
+	job := wipeJob{Device: tempImage(t, 1<<20), Options: Options{}.withDefaults(), assumeYes: true}
+	if err := prepareJob(context.Background(), &job, false); !errors.Is(err, errNotDevPath) || exitCodeFor(err) != exitAborted {
+		t.Errorf("prepareJob outside /dev/ = %v, want errNotDevPath", err)
+	}
+	job.ExpectSize = 2 << 20
+	if err := prepareJob(context.Background(), &job, false); !errors.Is(err, errSizeMismatch) {
+		t.Errorf("prepareJob with the wrong size = %v, want errSizeMismatch", err)
+	}
+}
+
+func TestOffloadsZeroes(t *testing.T) {
+	// Disk images can't offload zeroing, whatever the kernel would emulate
+	if max := writeZeroesMax(tempImage(t, 1<<20)); max != 0 {
+		t.Errorf("writeZeroesMax of an image = %d, want 0", max)
+	}
+
+	job := wipeJob{Options: Options{Pattern: patternZero, WriteZeroes: true}.withDefaults(), zeroOutMax: 1 << 30}
+	if !job.offloadsZeroes() {
+		t.Error("a full zero pass is not offloaded")
+	}
+	for name, change := range map[string]func(*wipeJob){
+		"random pattern": func(j *wipeJob) { j.Pattern = patternRandom },
+		"skip factor":    func(j *wipeJob) { j.SkipFactor = 4 },
+		"rate limit":     func(j *wipeJob) { j.Rate = 50e6 },
+		"no offload":     func(j *wipeJob) { j.zeroOutMax = 0 },
+	} {
+		j := job
+		change(&j)
+		if j.offloadsZeroes() {
+			t.Errorf("pass with %s is offloaded", name)
+		}
+	}
+}
+
+func TestBenchmarkSize(t *testing.T) {
+	const buffer, block = 4 << 20, 4096
+	tests := []struct {
+		opts       Options
+		deviceSize int64
+		want       int64
+	}{
+		{Options{}, 1 << 40, defaultBenchmarkSize},
+		{Options{BenchmarkSize: 1 << 30}, 1 << 40, 1 << 30},
+		// A time limit is only bounded by the quarter of the device
+		{Options{BenchmarkTime: time.Minute}, 1 << 40, 1 << 38},
+		{Options{BenchmarkSize: 1 << 30, BenchmarkTime: time.Minute}, 1 << 40, 1 << 30},
+		// Small devices get a quarter, but at least two buffers
+		{Options{BenchmarkSize: 1 << 30}, 1 << 30, 1 << 28},
+		{Options{}, 16 << 20, 8 << 20},
+		{Options{BenchmarkSize: 1000000}, 1 << 40, 1000000 / block * block},
+	}
+	for _, tt := range tests {
+		if got := benchmarkSize(tt.opts, tt.deviceSize, buffer, block); got != tt.want {
+			t.Errorf("benchmarkSize(%+v, %d) = %d, want %d", tt.opts, tt.deviceSize, got, tt.want)
+		}
+	}
+}
+
+func TestBenchmarkWriteRegions(t *testing.T) {
+	const buffer = 64 * 1024
+	opts := Options{BufferSize: buffer, RandomRefresh: 1, RNG: rngChaCha, BenchmarkRegions: benchmarkWorst}
+	if _, _, err := benchmarkWriteRegions(context.Background(), tempImage(t, 4*buffer), opts, 0, 4*buffer); !errors.Is(err, errTooSmallToBenchmark) {
+		t.Errorf("benchmark of a device smaller than three pairs of buffers = %v, want errTooSmallToBenchmark", err)
+	}
+
+	const size = 12 << 20
+	path := tempImage(t, size)
+	worst, speeds, err := benchmarkWriteRegions(context.Background(), path, opts, 0, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A quarter of each third, at the start, in the middle and at the end
+	const part = size / 3 / 4
+	want := []int64{0, size/2 - part/2, size - part}
+	if len(speeds) != 3 {
+		t.Fatalf("got %d speeds, want 3", len(speeds))
+	}
+	for i, s := range speeds {
+		if s.Offset != want[i] || s.Speed <= 0 || s.Speed < worst {
+			t.Errorf("%s benchmark at offset %d with %.0f B/s, want offset %d and at least %.0f B/s", s.Position, s.Offset, s.Speed, want[i], worst)
+		}
+	}
+	if got, err := DeviceSize(path); err != nil || got != size {
+		t.Errorf("benchmark changed the size of the device to %d (%v)", got, err)
+	}
+}
+
+func TestBenchmarkWriteRangeIsPositional(t *testing.T) {
+	const buffer = 64 * 1024
+	path := tempImage(t, 1<<20)
+	// An unaligned start is rounded down to the 4096-byte blocks of images
+	opts := Options{BufferSize: buffer, RandomRefresh: 1, RNG: rngChaCha}
+	if _, err := benchmarkWriteRange(context.Background(), path, opts, buffer+100, 2*buffer); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data[:buffer], make([]byte, buffer)) || !bytes.Equal(data[3*buffer:], make([]byte, len(data)-3*buffer)) {
+		t.Error("benchmark wrote outside its range")
+	}
+	if bytes.Equal(data[buffer:buffer+4096], make([]byte, 4096)) || bytes.Equal(data[3*buffer-4096:3*buffer], make([]byte, 4096)) {
+		t.Error("benchmark didn't write the ends of its range")
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{errAborted, exitAborted},
+		{newDeviceError("open", "/dev/sdx", syscall.ENOENT), exitNoAccess},
+		{newDeviceError("open", "/dev/sdx", syscall.EACCES), exitNoAccess},
+		{newDeviceError("open", "/dev/sdx", syscall.EIO), exitDeviceError},
+		{newWriteError(4096, syscall.EIO), exitDeviceError},
+		{&SignalError{Signal: syscall.SIGTERM}, 143},
+	}
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.err); got != tt.want {
+			t.Errorf("exitCodeFor(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+
+	flags := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+	var out bytes.Buffer
+	flags.SetOutput(&out)
+	flags.Bool("force", false, "skip confirmation")
+	printUsage(&out, flags)
+	for _, want := range []string{"-force", "Exit codes:", "  7 ", "  128+N "} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("usage doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestWipe(t *testing.T) {
+	const size = 1 << 20
+	path := tempImage(t, size)
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0xAA}, size), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Without a checkpoint option nothing is written to the working directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	// An image file is outside /dev/, which the safety checks refuse
+	opts := Options{BufferSize: 64 * 1024, Pattern: patternZero, Verify: true, ProgressInterval: time.Nanosecond}
+	if _, err := Wipe(context.Background(), path, opts, nil); err == nil {
+		t.Fatal("Wipe of an image file without Force succeeded")
+	}
+	opts.Force = true
+
+	var updates int
+	result, err := Wipe(context.Background(), path, opts, func(u Progress) {
+		if u.Device != path || u.Total != size {
+			t.Errorf("progress for %s of %d bytes, want %s of %d", u.Device, u.Total, path, size)
+		}
+		updates++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BytesWritten != size || !result.Verified || updates == 0 {
+		t.Errorf("wrote %d bytes, verified %v, %d progress updates; want %d bytes, verified, some updates", result.BytesWritten, result.Verified, updates, size)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, make([]byte, size)) {
+		t.Error("device not zeroed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var interrupted *InterruptedError
+	if _, err := Wipe(ctx, path, opts, nil); !errors.As(err, &interrupted) || !errors.Is(err, context.Canceled) {
+		t.Errorf("wipe with a cancelled context returned %v, want an *InterruptedError wrapping context.Canceled", err)
+	}
+	if entries, _ := os.ReadDir(dir); interrupted != nil && (interrupted.Checkpoint != "" || len(entries) > 0) {
+		t.Errorf("interrupted wipe wrote checkpoint %q and %d files to the working directory", interrupted.Checkpoint, len(entries))
+	}
+
+	opts.Checkpoint = filepath.Join(dir, "wipe.checkpoint")
+	if _, err := Wipe(ctx, path, opts, nil); !errors.As(err, &interrupted) || interrupted.Checkpoint != opts.Checkpoint {
+		t.Errorf("interrupted wipe with a checkpoint returned %v, want one naming %s", err, opts.Checkpoint)
+	}
+}
+
+func TestBenchmarkCancelled(t *testing.T) {
+	const buffer = 64 * 1024
+	path := tempImage(t, 16*buffer)
+	opts := Options{BufferSize: buffer}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&SignalError{Signal: syscall.SIGINT})
+	var writeErr *WriteError
+	_, err := BenchmarkWriteSpeed(ctx, path, opts)
+	if !errors.Is(err, context.Canceled) || errors.As(err, &writeErr) {
+		t.Errorf("benchmark with a cancelled context returned %v, want context.Canceled", err)
+	}
+	if exitCodeFor(err) != exitSignalBase+int(syscall.SIGINT) {
+		t.Errorf("benchmark stopped by SIGINT exits with %d", exitCodeFor(err))
+	}
+}
+
+func TestNoDirect(t *testing.T) {
+	var job wipeJob
+	if err := applyJobOverride(&job, "no-direct", "true"); err != nil || !job.NoDirect {
+		t.Fatalf("no-direct=true gave %v (%v)", job.NoDirect, err)
+	}
+	// Not a regular file, so only -no-direct keeps it from trying direct I/O
+	if mode := describeIOMode(os.DevNull, job.Options); !strings.HasPrefix(mode, "buffered") || !strings.Contains(mode, "-no-direct") {
+		t.Errorf("I/O mode with -no-direct is %q", mode)
+	}
+	file, err := openForWipe(os.DevNull, Options{NoDirect: true, NoExcl: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritable(filepath.Join(dir, "missing")); !errors.Is(err, ErrNotFound) || exitCodeFor(err) != exitNoAccess {
+		t.Errorf("missing device: %v", err)
+	}
+	// Left for checkTarget to explain
+	if err := checkWritable(dir); err != nil {
+		t.Errorf("directory: %v", err)
+	}
+	path := tempImage(t, 4096)
+	if err := checkWritable(path); err != nil {
+		t.Errorf("writable image: %v", err)
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only files")
+	}
+	if err := os.Chmod(path, 0o400); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkWritable(path); !errors.Is(err, ErrPermission) || exitCodeFor(err) != exitNoAccess {
+		t.Errorf("read-only image: %v", err)
+	}
+}
+
+func TestRandomSkipMode(t *testing.T) {
+	// Exactly Num of every Den blocks, and the gaps are the rest
+	for _, cov := range []coverage{{1, 4}, {3, 10}, {1, 1000}} {
+		const groups = 5
+		pass, gaps := newRandomSelector(cov, 42, false), newRandomSelector(cov, 42, true)
+		for g := 0; g < groups; g++ {
+			var written int64
+			for range cov.Den {
+				w := pass.next()
+				if w == gaps.next() {
+					t.Fatalf("%v: a block written by both stages or neither", cov)
+				}
+				if w {
+					written++
+				}
+			}
+			if written != cov.Num {
+				t.Errorf("%v: group %d has %d blocks written, want %d", cov, g, written, cov.Num)
+			}
+		}
+	}
+
+	const block, blocks = 4096, 64
+	path := tempImage(t, block*blocks)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: block, SkipFactor: 4, SkipMode: skipModeRandom, SkipSeed: 7, Pattern: patternOne}.withDefaults(),
+	}
+	job.size = block * blocks
+	result, err := wipeDevice(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.BytesWritten != job.size/4 || result.SkipSeed != 7 {
+		t.Errorf("wrote %d bytes with seed %d, want %d with seed 7", result.BytesWritten, result.SkipSeed, job.size/4)
+	}
+	if err := verifyDevice(context.Background(), job, result, func(Progress) {}); err != nil {
+		t.Errorf("verifyDevice: %v", err)
+	}
+
+	// The seed decides which blocks: the same one repeats the choice, and
+	// neither is the stride's
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector := newRandomSelector(job.coverage(), job.passSeed(), false)
+	stride := true
+	for i := range int64(blocks) {
+		written := data[i*block] == 0xFF
+		if written != selector.next() {
+			t.Fatalf("block %d written %v, not as seed 7 chooses", i, written)
+		}
+		if written != (i%4 == 0) {
+			stride = false
+		}
+	}
+	if stride {
+		t.Error("random skip mode wrote 1 in every 4 blocks at regular intervals")
+	}
+}
+
+func TestBenchmarkCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quickwipe", "benchmarks.json")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if _, ok := lookupBenchmark(path, "disk", time.Hour, now); ok {
+		t.Fatal("hit in a missing cache")
+	}
+	if key := benchmarkCacheKey(tempImage(t, 4096), Options{}); key != "" {
+		t.Errorf("disk image cached as %q", key)
+	}
+
+	old := cachedBenchmark{Speed: 1, MeasuredAt: now.Add(-2 * time.Hour)}
+	if err := storeBenchmark(path, "old", old, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	fresh := cachedBenchmark{Speed: 200 << 20, RegionSpeeds: []regionSpeed{{Position: "start", Speed: 200 << 20}}, MeasuredAt: now.Add(-time.Minute)}
+	if err := storeBenchmark(path, "disk", fresh, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := lookupBenchmark(path, "disk", time.Hour, now); !ok || !reflect.DeepEqual(got, fresh) {
+		t.Errorf("lookup = %+v, %v; want %+v", got, ok, fresh)
+	}
+	for _, tt := range []struct {
+		key    string
+		maxAge time.Duration
+	}{
+		{"disk", time.Minute},   // stale
+		{"other", time.Hour},    // another drive or other options
+		{"old", 24 * time.Hour}, // dropped when storing with a shorter age
+	} {
+		if _, ok := lookupBenchmark(path, tt.key, tt.maxAge, now); ok {
+			t.Errorf("%s within %s: cache hit", tt.key, tt.maxAge)
+		}
+	}
+
+	// A damaged cache is benchmarked around and then replaced
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lookupBenchmark(path, "disk", time.Hour, now); ok {
+		t.Error("hit in a damaged cache")
+	}
+	if err := storeBenchmark(path, "disk", fresh, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lookupBenchmark(path, "disk", time.Hour, now); !ok {
+		t.Error("damaged cache not replaced")
+	}
+}
+
+func TestStamp(t *testing.T) {
+	const size = 64 * 1024
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: 4096, SkipFactor: 1, Pattern: patternOne, Verify: true}.withDefaults(),
+		Stamp:   true,
+	}
+	job.size = size
+
+	result, err := runJob(context.Background(), job, nil, runInfo{Version: "v9.9.9", Hostname: "station"}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	if !result.Stamped || !result.Verified {
+		t.Errorf("stamped %t, verified %t; want both", result.Stamped, result.Verified)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := deviceBlockSize(path)
+	if !isStamped(data) || !bytes.Contains(data[:block], []byte("Tool: quickwipe v9.9.9\n")) || data[block-1] != 0 {
+		t.Errorf("first block doesn't hold the marker:\n%q", bytes.TrimRight(data[:block], "\x00"))
+	}
+	if !bytes.Equal(data[block:], bytes.Repeat([]byte{0xFF}, size-block)) {
+		t.Error("the marker spilled past the first block")
+	}
+
+	// A stamped device still counts as wiped
+	if wiped, offset, err := sampleMatchesPattern(job); err != nil || !wiped {
+		t.Errorf("sampleMatchesPattern of a stamped device = %t at %d, %v; want true", wiped, offset, err)
+	}
+
+	job.PreserveTable = true
+	if err := validateJob(job); err == nil {
+		t.Error("validateJob accepted -stamp with -preserve-table")
+	}
+}
+
+func TestVerifyInline(t *testing.T) {
+	const size = 64 * 1024
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:  path,
+		Options: Options{BufferSize: 4096, SkipFactor: 1, Pattern: patternRandom, Passes: 2, VerifyInline: true}.withDefaults(),
+	}
+	job.size = size
+
+	result, err := runJob(context.Background(), job, nil, runInfo{}, func(Progress) {})
+	if err != nil {
+		t.Fatalf("runJob: %v", err)
+	}
+	if !result.Verified || len(result.digests) != 0 {
+		t.Errorf("verified %t with %d digests; want verified without any", result.Verified, len(result.digests))
+	}
+
+	// A block that doesn't read back is reported, and the rest still checked
+	v, err := newInlineVerifier(job, 4096)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.check(data[:4096], 0); err != nil || v.verr != nil {
+		t.Fatalf("check of a block as written = %v, %v", err, v.verr)
+	}
+	wrong := bytes.Clone(data[4096:8192])
+	wrong[100] ^= 0xFF
+	if err := v.check(wrong, 4096); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.check(bytes.Clone(data[8192:12288]), 8192); err != nil {
+		t.Fatal(err)
+	}
+	if v.verr == nil || v.verr.Offset != 4096+100 || !slices.Equal(v.verr.Blocks, []int64{4096}) {
+		t.Errorf("mismatch recorded as %+v, want offset %d in block 4096", v.verr, 4096+100)
+	}
+
+	job.Verify = true
+	if err := validateJob(job); err == nil {
+		t.Error("validateJob accepted -verify-inline with -verify")
+	}
+}
+
+func TestCheckStillThere(t *testing.T) {
+	const size = 1 << 20
+	path := tempImage(t, size)
+	writeErr := newWriteError(4096, syscall.EIO)
+
+	if err := checkStillThere(path, size, writeErr); err != writeErr {
+		t.Errorf("checkStillThere of a device that is still there = %v, want the write error", err)
+	}
+	if err := os.Truncate(path, size/2); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkStillThere(path, size, writeErr); !errors.Is(err, ErrDeviceResized) || !errors.Is(err, syscall.EIO) {
+		t.Errorf("checkStillThere of a shrunk device = %v, want ErrDeviceResized wrapping EIO", err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	err := checkStillThere(path, size, writeErr)
+	if !errors.Is(err, ErrDeviceGone) {
+		t.Fatalf("checkStillThere of a removed device = %v, want ErrDeviceGone", err)
+	}
+
+	gone := &DisconnectError{Path: "/dev/sdx", Offset: size / 4, Size: size, Err: err}
+	if !strings.Contains(gone.Error(), "/dev/sdx disappeared, wipe incomplete at 25.0%") {
+		t.Errorf("DisconnectError reads %q", gone.Error())
+	}
+	if code := exitCodeFor(gone); code != exitDeviceGone {
+		t.Errorf("exit code of a vanished device = %d, want %d", code, exitDeviceGone)
+	}
+	if retryable(gone) {
+		t.Error("a write to a vanished device is retried")
+	}
+}
+
+func TestParseCoverage(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  float64
+	}{{"25", 0.25}, {"25%", 0.25}, {"100", 1}, {"1", 0.01}, {"12.5%", 0.125}} {
+		if got, err := parseCoverage(tt.value); err != nil || got != tt.want {
+			t.Errorf("parseCoverage(%q) = %v, %v; want %v", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"0", "0.75", "0.5%", "101", "-5", "half", ""} {
+		if got, err := parseCoverage(value); err == nil {
+			t.Errorf("parseCoverage(%q) = %v, want an error", value, got)
+		}
+	}
+	if c := fractionCoverage(0.25); c != skipCoverage(4) {
+		t.Errorf("coverage 25%% is %v, want the same as -skip 4", c)
+	}
+
+	job := wipeJob{Device: "/dev/sdx", Options: Options{BufferSize: 4096, Coverage: 0.5}.withDefaults()}
+	if err := validateJob(job); err != nil {
+		t.Fatalf("validateJob: %v", err)
+	}
+	for _, other := range []func(*wipeJob){
+		func(j *wipeJob) { j.SkipFactor = 4 },
+		func(j *wipeJob) { j.AutoSkip = true },
+		func(j *wipeJob) { j.Coverage, j.SkipFactor, j.AutoSkip = 0, 4, true },
+	} {
+		j := job
+		other(&j)
+		if err := validateJob(j); err == nil {
+			t.Errorf("validateJob accepted coverage %v, skip %d and auto-skip %t together", j.Coverage, j.SkipFactor, j.AutoSkip)
+		}
+	}
+
+	// A devices-file override replaces the way of choosing blocks given for all devices
+	if err := applyJobOverride(&job, "skip", "4"); err != nil || job.Coverage != 0 || validateJob(job) != nil {
+		t.Errorf("skip=4 over coverage left coverage %v (%v)", job.Coverage, err)
+	}
+	if err := applyJobOverride(&job, "coverage", "10%"); err != nil || job.SkipFactor != 1 || validateJob(job) != nil {
+		t.Errorf("coverage=10%% over skip left skip %d (%v)", job.SkipFactor, err)
+	}
+}
+
+func TestDebugDiagnostics(t *testing.T) {
+	if got := openFlagNames(os.O_WRONLY|syscall.O_EXCL|syscall.O_SYNC, true); got != "O_WRONLY|O_EXCL|O_SYNC|"+directIOFlag {
+		t.Errorf("openFlagNames of a direct open = %q", got)
+	}
+	if got := openFlagNames(os.O_RDONLY, false); got != "O_RDONLY" {
+		t.Errorf("openFlagNames of a plain read = %q", got)
+	}
+	if got := errnoName(newWriteError(4096, syscall.EINVAL)); got != "EINVAL" {
+		t.Errorf("errnoName of a rejected write = %q, want EINVAL", got)
+	}
+
+	buf, err := AllocAlignedBuffer(8192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer FreeAlignedBuffer(buf)
+	if a := memAlignment(buf); a < os.Getpagesize() {
+		t.Errorf("mapped buffer aligned to %d bytes, want at least a page", a)
+	}
+	if a := memAlignment(buf[512:]); a != 512 {
+		t.Errorf("buffer 512 bytes into a page aligned to %d bytes", a)
+	}
+	if got := describeWrite(buf[:4096], 8192, 4096); !strings.Contains(got, "(aligned to 4096-byte blocks)") {
+		t.Errorf("aligned write described as %q", got)
+	}
+	if got := describeWrite(buf[512:1536], 512, 4096); !strings.Contains(got, "length, offset, buffer address not aligned") {
+		t.Errorf("misaligned write described as %q", got)
+	}
+}
+
+func TestEnvDefaultsYieldToCommandLine(t *testing.T) {
+	t.Setenv("QUICKWIPE_DEVICE", "/dev/sda")
+	t.Setenv("QUICKWIPE_PASSES", "3")
+	parse := func(args ...string) (deviceListFlag, int) {
+		fs := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+		var devices deviceListFlag
+		fs.Var(&devices, "device", "")
+		passes := fs.Int("passes", 1, "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		if err := applyEnvDefaults(fs); err != nil {
+			t.Fatalf("applyEnvDefaults: %v", err)
+		}
+		return devices, *passes
+	}
+
+	if devices, passes := parse(); !slices.Equal(devices, []string{"/dev/sda"}) || passes != 3 {
+		t.Errorf("from the environment alone got devices %v, passes %d", devices, passes)
+	}
+	// A device on the command line replaces the environment's list
+	if devices, passes := parse("-device", "/dev/sdb", "-device", "/dev/sdc"); !slices.Equal(devices, []string{"/dev/sdb", "/dev/sdc"}) || passes != 3 {
+		t.Errorf("with -device on the command line got devices %v, passes %d", devices, passes)
+	}
+}
+
+func TestOutputOptionsFromConfigFile(t *testing.T) {
+	load := func(config string) (*jsonStream, ProgressFunc, flagSources) {
+		path := filepath.Join(t.TempDir(), "quickwipe.conf")
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		fs := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+		fs.String("config", "", "")
+		jsonOutput := fs.Bool("json", false, "")
+		quiet := fs.Bool("quiet", false, "")
+		bar := fs.Bool("bar", false, "")
+		if err := fs.Parse([]string{"-config", path}); err != nil {
+			t.Fatal(err)
+		}
+		sources, err := loadFlags(fs)
+		if err != nil {
+			t.Fatalf("loadFlags: %v", err)
+		}
+		stream, display := newDisplay(*jsonOutput, *quiet, *bar, false)
+		return stream, display, sources
+	}
+
+	if stream, display, sources := load("json = true\n"); stream == nil || display == nil || sources["json"] != sourceConfig {
+		t.Errorf("json from the config file got stream %v, display set %t, source %d", stream, display != nil, sources["json"])
+	}
+	if stream, display, sources := load("quiet = true\nbar = true\n"); stream != nil || display == nil || sources["quiet"] != sourceConfig {
+		t.Errorf("quiet from the config file got stream %v, display set %t, source %d", stream, display != nil, sources["quiet"])
+	}
+	if stream, display, _ := load(""); stream != nil || display != nil {
+		t.Errorf("empty config file got stream %v, display set %t", stream, display != nil)
+	}
+}
+
+func TestSettleExclusive(t *testing.T) {
+	t.Setenv("QUICKWIPE_SKIP", "2")
+	parse := func(args ...string) (*flag.FlagSet, flagSources, *int, *string) {
+		fs := flag.NewFlagSet("quickwipe", flag.ContinueOnError)
+		skip := fs.Int("skip", 1, "")
+		cov := fs.String("coverage", "", "")
+		fs.Bool("auto-skip", false, "")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		sources := flagSources{}
+		sources.record(fs, sourceCommandLine)
+		if err := applyEnvDefaults(fs); err != nil {
+			t.Fatal(err)
+		}
+		sources.record(fs, sourceEnv)
+		return fs, sources, skip, cov
+	}
+
+	// The command line outranks the environment
+	fs, sources, skip, cov := parse("-coverage", "50")
+	if sources["skip"] != sourceEnv || sources["coverage"] != sourceCommandLine {
+		t.Fatalf("sources = %v", sources)
+	}
+	if err := settleExclusive(fs, sources, "coverage", "skip", "auto-skip"); err != nil || *skip != 1 || *cov != "50" {
+		t.Errorf("-coverage over QUICKWIPE_SKIP left skip %d, coverage %q (%v)", *skip, *cov, err)
+	}
+
+	fs, sources, _, _ = parse("-coverage", "50", "-skip", "4")
+	if err := settleExclusive(fs, sources, "coverage", "skip", "auto-skip"); err == nil {
+		t.Error("settleExclusive accepted -coverage and -skip on the command line")
+	}
+
+	fs, sources, skip, _ = parse()
+	if err := settleExclusive(fs, sources, "coverage", "skip", "auto-skip"); err != nil || *skip != 2 {
+		t.Errorf("QUICKWIPE_SKIP alone gave skip %d (%v)", *skip, err)
+	}
+}
+
+func TestCoverageString(t *testing.T) {
+	for _, tt := range []struct {
+		cov  coverage
+		want string
+	}{
+		{skipCoverage(1), "every block"},
+		{skipCoverage(2), "1 in every 2 blocks"},
+		{skipCoverage(3), "1 in every 3 blocks"},
+		{coverage{Num: 3, Den: 10}, "30% of blocks"},
+	} {
+		if got := tt.cov.String(); got != tt.want {
+			t.Errorf("%+v reads %q, want %q", tt.cov, got, tt.want)
+		}
+	}
+}
+
+func TestCheckWipedFindsStamp(t *testing.T) {
+	const size = 64 * 1024
+	path := tempImage(t, size)
+	job := wipeJob{
+		Device:     path,
+		Options:    Options{BufferSize: 4096, SkipFactor: 1, Pattern: patternRandom}.withDefaults(),
+		CheckWiped: checkWipedSkip,
+	}
+	job.size = size
+
+	// Random data can't be recognized without a marker
+	if err := checkAlreadyWiped(job, false); err != nil {
+		t.Fatalf("checkAlreadyWiped of an unstamped device = %v", err)
+	}
+
+	stamp := wipeStamp(job, Result{Coverage: coverage{Num: 1, Den: 1}}, runInfo{Version: "v9.9.9"}, 4096)
+	if err := os.WriteFile(path, stamp, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkAlreadyWiped(job, false); !errors.Is(err, errAlreadyWiped) {
+		t.Errorf("checkAlreadyWiped of a stamped device = %v, want errAlreadyWiped", err)
+	}
+	text, err := readStamp(path)
+	if err != nil || stampField(text, "Tool") != "quickwipe v9.9.9" {
+		t.Errorf("readStamp = %q, %v", text, err)
+	}
+}
+
+func TestDaemonKeepsSafetyChecks(t *testing.T) {
+	d := &daemon{
+		watchdog: newServiceWatchdog(),
+		queue:    make(chan *daemonJob, 2),
+		cancels:  make(map[string]context.CancelCauseFunc),
+	}
+	if _, err := d.submit(wipeJob{Device: "/dev/sdx", Options: Options{NoExcl: true}}); !errors.Is(err, errDaemonNoExcl) {
+		t.Errorf("submit with no_excl = %v, want errDaemonNoExcl", err)
+	}
+
+	// Submitting confirms the wipe but doesn't force it: a path outside
+	// /dev is refused, as with -assume-yes
+	path := tempImage(t, 64*1024)
+	if _, err := d.submit(wipeJob{Device: path, Options: Options{BufferSize: 4096}}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	job := <-d.queue
+	d.process(context.Background(), job)
+	if job.State != jobFailed || !strings.Contains(job.Error, "doesn't look like a block device") {
+		t.Errorf("job on %s ended %s: %q, want it refused", path, job.State, job.Error)
+	}
+}
+
+func TestDaemonResumesAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "sdx.checkpoint")
+	if err := writeCheckpoint(saved, checkpoint{Device: "/dev/sdx", Size: 1 << 30, Offset: 1 << 20}); err != nil {
+		t.Fatal(err)
+	}
+	// Jobs that were running when the daemon died, with and without a
+	// checkpoint to show for it
+	jobs := []*daemonJob{
+		{ID: "1", Spec: wipeJob{Device: "/dev/sdx"}, State: jobRunning, Checkpoint: saved},
+		{ID: "2", Spec: wipeJob{Device: "/dev/sdy"}, State: jobRunning, Checkpoint: filepath.Join(dir, "sdy.checkpoint")},
+		{ID: "3", Spec: wipeJob{Device: "/dev/sdz"}, State: jobRunning, Checkpoint: saved},
+	}
+	statePath := filepath.Join(dir, "jobs.json")
+	if err := writeJSONFile(statePath, jobs); err != nil {
+		t.Fatal(err)
+	}
+
+	d := &daemon{statePath: statePath, queue: make(chan *daemonJob, 3)}
+	if err := d.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	for i, want := range []string{saved, "", ""} {
+		if job := d.jobs[i]; job.State != jobQueued || job.ResumeFrom != want {
+			t.Errorf("job %s on %s requeued as %s resuming from %q, want queued resuming from %q",
+				job.ID, job.Spec.Device, job.State, job.ResumeFrom, want)
+		}
+	}
+}
+
+func TestRemoteAPIRestrictions(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true, "[::1]:8080": true, "localhost:8080": true,
+		":8080": false, "0.0.0.0:8080": false, "10.0.0.5:8080": false,
+	} {
+		if got := isLoopbackAddr(addr); got != want {
+			t.Errorf("isLoopbackAddr(%q) = %t", addr, got)
+		}
+	}
+
+	for _, body := range []string{`{"device": "/dev/sdx", "skip": 4}`, `{"device": "/dev/sdx", "certificate": "auto"}`} {
+		var sent wipeJob
+		if err := json.Unmarshal([]byte(body), &sent); err != nil {
+			t.Fatal(err)
+		}
+		if err := checkRemoteSpec(sent); err != nil {
+			t.Errorf("checkRemoteSpec(%s) = %v", body, err)
+		}
+	}
+	d := &daemon{}
+	for _, body := range []string{
+		`{"device": "/dev/sdx", "checkpoint": "/etc/cron.d/x"}`,
+		`{"device": "/dev/sdx", "pattern": "file", "pattern_file": "/etc/shadow"}`,
+		`{"device": "/dev/sdx", "certificate": "/root/.ssh/authorized_keys"}`,
+		`{"device": "/dev/sdx", "summary": "/etc/passwd"}`,
+		`{"device": "/dev/sdx", "Checkpoint": "/etc/cron.d/x"}`,
+		`{"device": "/dev/sdx", "pattern": "file", "PATTERN_FILE": "/etc/shadow"}`,
+		`{"device": "/dev/sdx", "Certificate": "/root/.ssh/authorized_keys"}`,
+		`{"device": "/dev/sdx", "SUMMARY": "/etc/passwd"}`,
+	} {
+		rec := httptest.NewRecorder()
+		d.handleSubmit(rec, httptest.NewRequest(http.MethodPost, "/jobs", strings.NewReader(body)), true)
+		if rec.Code != http.StatusForbidden {
+			t.Errorf("remote submit of %s got status %d, want %d", body, rec.Code, http.StatusForbidden)
+		}
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := requireToken("s3cret", ok)
+	for header, want := range map[string]int{"": http.StatusUnauthorized, "Bearer wrong": http.StatusUnauthorized, "Bearer s3cret": http.StatusNoContent} {
+		req := httptest.NewRequest(http.MethodGet, "/jobs", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("Authorization %q got status %d, want %d", header, rec.Code, want)
+		}
+	}
+}
+
+func TestGRPCToken(t *testing.T) {
+	ctx := context.Background()
+	if err := checkGRPCToken(ctx, "s3cret"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call without metadata = %v, want Unauthenticated", err)
+	}
+	wrong := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer wrong"))
+	if err := checkGRPCToken(wrong, "s3cret"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("call with the wrong token = %v, want Unauthenticated", err)
+	}
+	right := metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", "Bearer s3cret"))
+	if err := checkGRPCToken(right, "s3cret"); err != nil {
+		t.Errorf("call with the token = %v", err)
+	}
+	if err := checkGRPCToken(ctx, ""); err != nil {
+		t.Errorf("call to a loopback server without a token = %v", err)
+	}
+}
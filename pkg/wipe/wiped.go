@@ -0,0 +1,136 @@
+package wipe
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Modes for -check-wiped.
+const (
+	checkWipedAsk  = "ask"  // ask whether to skip a device that looks wiped
+	checkWipedSkip = "skip" // skip it without asking
+)
+
+// errAlreadyWiped is returned by prepareJob for a device that is skipped
+// because it already reads back as the job's pattern.
+var errAlreadyWiped = errors.New("device already looks wiped")
+
+// wipedSampleCount and wipedSampleSize control the check for a device that
+// already holds the pattern.
+const (
+	wipedSampleCount = 64
+	wipedSampleSize  = 4096
+)
+
+func validCheckWiped(mode string) bool {
+	return mode == "" || mode == checkWipedAsk || mode == checkWipedSkip
+}
+
+// sampleMatchesPattern reads samples from the start, the end and random
+// positions of the device and reports whether every one of them holds what
+// the job's final pass would write there. If not, it also returns the offset
+// of the first sample that differs.
+func sampleMatchesPattern(job wipeJob) (bool, int64, error) {
+	file, err := openDirect(job.Device, os.O_RDONLY)
+	if err != nil {
+		file, err = os.Open(job.Device)
+		if err != nil {
+			return false, 0, newDeviceError("open", job.Device, err)
+		}
+	}
+	defer file.Close()
+
+	got, err := AllocAlignedBuffer(wipedSampleSize)
+	if err != nil {
+		return false, 0, err
+	}
+	defer FreeAlignedBuffer(got)
+	want := make([]byte, wipedSampleSize)
+
+	offsets, err := sampleOffsets(job.size, wipedSampleCount, wipedSampleSize)
+	if err != nil {
+		return false, 0, err
+	}
+	for _, offset := range offsets {
+		if _, err := file.ReadAt(got, offset); err != nil {
+			return false, 0, newDeviceError("read", job.Device, err)
+		}
+		if err := fillPattern(job, want, offset); err != nil {
+			return false, 0, err
+		}
+		// The marker of -stamp takes the place of the pattern
+		if offset == 0 && isStamped(got) {
+			skip := min(deviceBlockSize(job.Device), len(got))
+			copy(want[:skip], got[:skip])
+		}
+		if !bytes.Equal(got, want) {
+			return false, offset, nil
+		}
+	}
+	return true, 0, nil
+}
+
+// checkAlreadyWiped looks for the marker of an earlier -stamp wipe in the
+// first block of the device or, failing that, samples it, and if the device
+// already holds the pattern the wipe would leave behind, asks whether to
+// skip it or, in skip mode, skips it outright with errAlreadyWiped. With
+// force nothing is asked and the device is wiped again; with -assume-yes the
+// question gets its default answer and the device is skipped. Random data
+// can't be told apart from the disk's own contents, so without a marker
+// random wipes are not checked.
+func checkAlreadyWiped(job wipeJob, force bool) error {
+	stamp, err := readStamp(job.Device)
+	if err != nil {
+		warnf("Cannot check whether %s is already wiped: %v", job.Device, err)
+		return nil
+	}
+
+	if stamp != "" {
+		finished := stampField(stamp, "Finished")
+		if finished == "" {
+			finished = "an unknown time"
+		}
+		fmt.Printf("%s already looks wiped: its first block holds the marker of a wipe finished at %s\n", job.Device, finished)
+	} else {
+		patterns := job.passPatterns()
+		final := job
+		final.Pattern = patterns[len(patterns)-1]
+		if !deterministicPattern(final.Pattern) {
+			fmt.Printf("Not checking whether %s is already wiped: it has no wipe marker and random data cannot be recognized\n", job.Device)
+			return nil
+		}
+
+		wiped, offset, err := sampleMatchesPattern(final)
+		switch {
+		case err != nil:
+			warnf("Cannot check whether %s is already wiped: %v", job.Device, err)
+			return nil
+		case !wiped:
+			fmt.Printf("%s is not wiped yet: offset %d does not hold the %s pattern\n", job.Device, offset, final.Pattern)
+			return nil
+		}
+		fmt.Printf("%s already looks wiped: all %d sampled blocks hold the %s pattern\n", job.Device, wipedSampleCount, final.Pattern)
+	}
+
+	if job.CheckWiped == checkWipedSkip {
+		return fmt.Errorf("%s: %w", job.Device, errAlreadyWiped)
+	}
+	if force {
+		return nil
+	}
+	fmt.Print("Skip this device? (Y/n): ")
+	if job.assumeYes {
+		// The default answer
+		fmt.Println("y (-assume-yes)")
+		return fmt.Errorf("%s: %w", job.Device, errAlreadyWiped)
+	}
+	var response string
+	fmt.Scanln(&response)
+	if !strings.HasPrefix(strings.ToLower(response), "n") {
+		return fmt.Errorf("%s: %w", job.Device, errAlreadyWiped)
+	}
+	return nil
+}
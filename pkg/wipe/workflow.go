@@ -0,0 +1,40 @@
+package wipe
+
+import (
+	"flag"
+	"fmt"
+)
+
+// workflowFull is the -workflow that runs every step a compliance record
+// needs: overwrite, verify, SMART snapshots and a certificate.
+const workflowFull = "full"
+
+// certificateAuto as the -certificate path names each device's certificate
+// after the device, see defaultCertificatePath.
+const certificateAuto = "auto"
+
+// applyWorkflow switches on the steps of the named workflow. Flags that
+// were given explicitly keep their value, so single steps can still be
+// turned off, e.g. -workflow full -smart=false.
+func applyWorkflow(name string, fs *flag.FlagSet) error {
+	var steps map[string]string
+	switch name {
+	case "":
+		return nil
+	case workflowFull:
+		steps = map[string]string{"verify": "true", "smart": "true", "certificate": certificateAuto}
+	default:
+		return fmt.Errorf("unknown workflow %q", name)
+	}
+
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	for step, value := range steps {
+		if !explicit[step] {
+			if err := fs.Set(step, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
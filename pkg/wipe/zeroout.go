@@ -0,0 +1,186 @@
+package wipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	// blkZeroOut is the BLKZEROOUT ioctl, _IO(0x12, 127). Like BLKDISCARD
+	// its argument points to a {start, length} pair of byte offsets. The
+	// kernel asks the device not to deallocate the range, so it is written
+	// rather than trimmed.
+	blkZeroOut = 0x127f
+
+	// zeroOutChunkSize is how much is zeroed per ioctl, which can't be
+	// interrupted, so that progress is reported and cancellation honored.
+	zeroOutChunkSize = 256 << 20
+)
+
+// errZeroOutUnsupported is returned by zeroOutDevice when the kernel or the
+// device turns BLKZEROOUT down before anything was written.
+var errZeroOutUnsupported = errors.New("write-zeroes offload not supported")
+
+// writeZeroesMax returns the most the disk of device zeroes per write-zeroes
+// command, as its queue reports it, or 0 if it can't offload zeroing. Only
+// block devices on Linux can; the kernel would emulate BLKZEROOUT on others
+// by writing zero pages, which is no faster than the write loop.
+func writeZeroesMax(device string) int64 {
+	if fi, err := os.Stat(device); err != nil || fi.Mode()&os.ModeDevice == 0 || fi.Mode()&os.ModeCharDevice != 0 {
+		return 0
+	}
+	queue := filepath.Join(sysClassBlock, parentDiskName(blockDeviceName(device)), "queue")
+	max, err := strconv.ParseInt(readSysfsString(filepath.Join(queue, "write_zeroes_max_bytes")), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return max
+}
+
+// offloadsZeroes reports whether the job's current pass is left to the
+// device with BLKZEROOUT: a whole-device zero pass with -write-zeroes on a
+// disk that supports it. A -rate limit can't be applied to the device, and
+// -verify-inline needs the written buffers, so both keep the write loop.
+func (job wipeJob) offloadsZeroes() bool {
+	return job.WriteZeroes && job.zeroOutMax > 0 && job.Pattern == patternZero &&
+		job.coverage().full() && job.gaps == nil && job.Rate == 0 && !job.VerifyInline
+}
+
+// zeroOutRange zeroes length bytes at offset with BLKZEROOUT.
+func zeroOutRange(file *os.File, offset, length int64) error {
+	r := [2]uint64{uint64(offset), uint64(length)}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), blkZeroOut, uintptr(unsafe.Pointer(&r)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// writePass runs one overwrite pass of job, offloading it to the device if
+// offloadsZeroes allows and falling back to writing the zeros if the device
+// turns the offload down.
+func writePass(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	if job.offloadsZeroes() {
+		result, err := zeroOutDevice(ctx, job, resume, run, report)
+		if !errors.Is(err, errZeroOutUnsupported) {
+			return result, err
+		}
+		fmt.Println()
+		warnf("%v; writing the zeros instead", err)
+	}
+	return wipeDevice(ctx, job, resume, run, report)
+}
+
+// zeroOutDevice zeroes the whole of job's device, or its window, with
+// BLKZEROOUT in chunks of zeroOutChunkSize, reporting progress and keeping
+// the checkpoint up to date like wipeDevice does. A non-nil resume
+// continues from where that checkpoint left off.
+func zeroOutDevice(ctx context.Context, job wipeJob, resume *checkpoint, run runInfo, report ProgressFunc) (Result, error) {
+	path, size := job.Device, job.size
+	file, err := openForWipe(path, job.Options)
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	var offset int64
+	if resume != nil {
+		offset = resume.Offset
+	}
+	resumedAt := offset
+	progressCheckpoint := func() checkpoint {
+		cp := checkpoint{
+			Device:         path,
+			Size:           size,
+			Offset:         offset,
+			BytesWritten:   offset,
+			BufferSize:     job.BufferSize,
+			Coverage:       coverage{Num: 1, Den: 1},
+			Pattern:        job.Pattern,
+			Pass:           job.pass,
+			PartitionTable: job.partitionTable,
+			Run:            run,
+		}
+		// The checkpoint is for the whole disk
+		if job.window != nil {
+			cp.Size, cp.Partition = job.diskSize, job.window.Number
+		}
+		return cp
+	}
+
+	fmt.Printf("Zeroing %s with the device's write-zeroes offload\n", path)
+	startTime := time.Now()
+	lastUpdateTime, lastCheckpointTime := startTime, startTime
+	lastUpdateBytes := offset
+	window := newSpeedWindow(job.ETAWindow)
+	for offset < size {
+		if ctx.Err() != nil {
+			result := Result{
+				Device:         path,
+				Size:           size,
+				BytesProcessed: offset,
+				BytesWritten:   offset,
+				Coverage:       coverage{Num: 1, Den: 1},
+				Duration:       time.Since(startTime),
+				ResumedAt:      resumedAt,
+			}
+			return result, interruptWipe(ctx, file, job.Checkpoint, progressCheckpoint())
+		}
+
+		length := min(int64(zeroOutChunkSize), size-offset)
+		if err := zeroOutRange(file, job.physical(offset), length); err != nil {
+			unsupported := errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOTTY)
+			if unsupported && offset == resumedAt {
+				return Result{}, fmt.Errorf("%s: %w", path, errZeroOutUnsupported)
+			}
+			return Result{}, newWriteError(job.physical(offset), err)
+		}
+		offset += length
+
+		now := time.Now()
+		if now.Sub(lastUpdateTime) >= job.ProgressInterval || offset == size {
+			window.add(offset-lastUpdateBytes, now.Sub(lastUpdateTime))
+			u := Progress{
+				Device:         path,
+				BytesProcessed: offset,
+				BytesWritten:   offset,
+				Total:          size,
+				Speed:          window.speed(),
+				Coverage:       coverage{Num: 1, Den: 1},
+			}
+			if u.Speed > 0 {
+				u.ETA = time.Duration(float64(size-offset)/u.Speed) * time.Second
+			}
+			report(u)
+			lastUpdateTime, lastUpdateBytes = now, offset
+		}
+		if job.Checkpoint != "" && now.Sub(lastCheckpointTime) >= checkpointInterval {
+			if err := writeCheckpoint(job.Checkpoint, progressCheckpoint()); err != nil {
+				fmt.Println()
+				warnf("Failed to write checkpoint: %v", err)
+			}
+			lastCheckpointTime = now
+		}
+	}
+
+	if err := file.Sync(); err != nil {
+		warnf("Final sync operation failed: %v", err)
+	}
+	return Result{
+		Device:         path,
+		Size:           size,
+		BytesProcessed: size,
+		BytesWritten:   size,
+		Coverage:       coverage{Num: 1, Den: 1},
+		Duration:       time.Since(startTime),
+		ResumedAt:      resumedAt,
+		Method:         methodOverwrite,
+	}, nil
+}
@@ -0,0 +1,6 @@
+// Package rpc contains the gRPC service definition used by the quickwipe
+// daemon's remote control interface. The Go code is generated from
+// quickwipe.proto.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative quickwipe.proto
@@ -0,0 +1,643 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: quickwipe.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartWipeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Device        string                 `protobuf:"bytes,1,opt,name=device,proto3" json:"device,omitempty"`
+	BufferSize    *int64                 `protobuf:"varint,2,opt,name=buffer_size,json=bufferSize,proto3,oneof" json:"buffer_size,omitempty"`
+	SkipFactor    *int32                 `protobuf:"varint,3,opt,name=skip_factor,json=skipFactor,proto3,oneof" json:"skip_factor,omitempty"`
+	Coverage      *float64               `protobuf:"fixed64,4,opt,name=coverage,proto3,oneof" json:"coverage,omitempty"`
+	RandomRefresh *int32                 `protobuf:"varint,5,opt,name=random_refresh,json=randomRefresh,proto3,oneof" json:"random_refresh,omitempty"`
+	DiscardVerify *bool                  `protobuf:"varint,6,opt,name=discard_verify,json=discardVerify,proto3,oneof" json:"discard_verify,omitempty"`
+	AutoSkip      *bool                  `protobuf:"varint,7,opt,name=auto_skip,json=autoSkip,proto3,oneof" json:"auto_skip,omitempty"`
+	TargetHours   *float64               `protobuf:"fixed64,8,opt,name=target_hours,json=targetHours,proto3,oneof" json:"target_hours,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartWipeRequest) Reset() {
+	*x = StartWipeRequest{}
+	mi := &file_quickwipe_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartWipeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartWipeRequest) ProtoMessage() {}
+
+func (x *StartWipeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quickwipe_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartWipeRequest.ProtoReflect.Descriptor instead.
+func (*StartWipeRequest) Descriptor() ([]byte, []int) {
+	return file_quickwipe_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartWipeRequest) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *StartWipeRequest) GetBufferSize() int64 {
+	if x != nil && x.BufferSize != nil {
+		return *x.BufferSize
+	}
+	return 0
+}
+
+func (x *StartWipeRequest) GetSkipFactor() int32 {
+	if x != nil && x.SkipFactor != nil {
+		return *x.SkipFactor
+	}
+	return 0
+}
+
+func (x *StartWipeRequest) GetCoverage() float64 {
+	if x != nil && x.Coverage != nil {
+		return *x.Coverage
+	}
+	return 0
+}
+
+func (x *StartWipeRequest) GetRandomRefresh() int32 {
+	if x != nil && x.RandomRefresh != nil {
+		return *x.RandomRefresh
+	}
+	return 0
+}
+
+func (x *StartWipeRequest) GetDiscardVerify() bool {
+	if x != nil && x.DiscardVerify != nil {
+		return *x.DiscardVerify
+	}
+	return false
+}
+
+func (x *StartWipeRequest) GetAutoSkip() bool {
+	if x != nil && x.AutoSkip != nil {
+		return *x.AutoSkip
+	}
+	return false
+}
+
+func (x *StartWipeRequest) GetTargetHours() float64 {
+	if x != nil && x.TargetHours != nil {
+		return *x.TargetHours
+	}
+	return 0
+}
+
+type JobRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *JobRequest) Reset() {
+	*x = JobRequest{}
+	mi := &file_quickwipe_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *JobRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*JobRequest) ProtoMessage() {}
+
+func (x *JobRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quickwipe_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use JobRequest.ProtoReflect.Descriptor instead.
+func (*JobRequest) Descriptor() ([]byte, []int) {
+	return file_quickwipe_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *JobRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Job struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Device        string                 `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	Serial        string                 `protobuf:"bytes,3,opt,name=serial,proto3" json:"serial,omitempty"`
+	State         string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"`
+	Error         string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	Progress      *Progress              `protobuf:"bytes,6,opt,name=progress,proto3" json:"progress,omitempty"`
+	SubmittedAt   string                 `protobuf:"bytes,7,opt,name=submitted_at,json=submittedAt,proto3" json:"submitted_at,omitempty"`
+	StartedAt     string                 `protobuf:"bytes,8,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt    string                 `protobuf:"bytes,9,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Job) Reset() {
+	*x = Job{}
+	mi := &file_quickwipe_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Job) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Job) ProtoMessage() {}
+
+func (x *Job) ProtoReflect() protoreflect.Message {
+	mi := &file_quickwipe_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Job.ProtoReflect.Descriptor instead.
+func (*Job) Descriptor() ([]byte, []int) {
+	return file_quickwipe_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Job) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Job) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *Job) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *Job) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Job) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Job) GetProgress() *Progress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+func (x *Job) GetSubmittedAt() string {
+	if x != nil {
+		return x.SubmittedAt
+	}
+	return ""
+}
+
+func (x *Job) GetStartedAt() string {
+	if x != nil {
+		return x.StartedAt
+	}
+	return ""
+}
+
+func (x *Job) GetFinishedAt() string {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return ""
+}
+
+type Progress struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	JobId          string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	State          string                 `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	BytesProcessed int64                  `protobuf:"varint,3,opt,name=bytes_processed,json=bytesProcessed,proto3" json:"bytes_processed,omitempty"`
+	BytesWritten   int64                  `protobuf:"varint,4,opt,name=bytes_written,json=bytesWritten,proto3" json:"bytes_written,omitempty"`
+	Total          int64                  `protobuf:"varint,5,opt,name=total,proto3" json:"total,omitempty"`
+	SpeedBps       float64                `protobuf:"fixed64,6,opt,name=speed_bps,json=speedBps,proto3" json:"speed_bps,omitempty"`
+	EtaSeconds     int64                  `protobuf:"varint,7,opt,name=eta_seconds,json=etaSeconds,proto3" json:"eta_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *Progress) Reset() {
+	*x = Progress{}
+	mi := &file_quickwipe_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Progress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Progress) ProtoMessage() {}
+
+func (x *Progress) ProtoReflect() protoreflect.Message {
+	mi := &file_quickwipe_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Progress.ProtoReflect.Descriptor instead.
+func (*Progress) Descriptor() ([]byte, []int) {
+	return file_quickwipe_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Progress) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Progress) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Progress) GetBytesProcessed() int64 {
+	if x != nil {
+		return x.BytesProcessed
+	}
+	return 0
+}
+
+func (x *Progress) GetBytesWritten() int64 {
+	if x != nil {
+		return x.BytesWritten
+	}
+	return 0
+}
+
+func (x *Progress) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Progress) GetSpeedBps() float64 {
+	if x != nil {
+		return x.SpeedBps
+	}
+	return 0
+}
+
+func (x *Progress) GetEtaSeconds() int64 {
+	if x != nil {
+		return x.EtaSeconds
+	}
+	return 0
+}
+
+type Certificate struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	JobId           string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	Device          string                 `protobuf:"bytes,2,opt,name=device,proto3" json:"device,omitempty"`
+	Serial          string                 `protobuf:"bytes,3,opt,name=serial,proto3" json:"serial,omitempty"`
+	Size            int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	Method          string                 `protobuf:"bytes,5,opt,name=method,proto3" json:"method,omitempty"`
+	Coverage        float64                `protobuf:"fixed64,6,opt,name=coverage,proto3" json:"coverage,omitempty"`
+	BytesWritten    int64                  `protobuf:"varint,7,opt,name=bytes_written,json=bytesWritten,proto3" json:"bytes_written,omitempty"`
+	StartedAt       string                 `protobuf:"bytes,8,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	FinishedAt      string                 `protobuf:"bytes,9,opt,name=finished_at,json=finishedAt,proto3" json:"finished_at,omitempty"`
+	AverageSpeedBps float64                `protobuf:"fixed64,10,opt,name=average_speed_bps,json=averageSpeedBps,proto3" json:"average_speed_bps,omitempty"`
+	Hostname        string                 `protobuf:"bytes,11,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	Operator        string                 `protobuf:"bytes,12,opt,name=operator,proto3" json:"operator,omitempty"`
+	Version         string                 `protobuf:"bytes,13,opt,name=version,proto3" json:"version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Certificate) Reset() {
+	*x = Certificate{}
+	mi := &file_quickwipe_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Certificate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Certificate) ProtoMessage() {}
+
+func (x *Certificate) ProtoReflect() protoreflect.Message {
+	mi := &file_quickwipe_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Certificate.ProtoReflect.Descriptor instead.
+func (*Certificate) Descriptor() ([]byte, []int) {
+	return file_quickwipe_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Certificate) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *Certificate) GetDevice() string {
+	if x != nil {
+		return x.Device
+	}
+	return ""
+}
+
+func (x *Certificate) GetSerial() string {
+	if x != nil {
+		return x.Serial
+	}
+	return ""
+}
+
+func (x *Certificate) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Certificate) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *Certificate) GetCoverage() float64 {
+	if x != nil {
+		return x.Coverage
+	}
+	return 0
+}
+
+func (x *Certificate) GetBytesWritten() int64 {
+	if x != nil {
+		return x.BytesWritten
+	}
+	return 0
+}
+
+func (x *Certificate) GetStartedAt() string {
+	if x != nil {
+		return x.StartedAt
+	}
+	return ""
+}
+
+func (x *Certificate) GetFinishedAt() string {
+	if x != nil {
+		return x.FinishedAt
+	}
+	return ""
+}
+
+func (x *Certificate) GetAverageSpeedBps() float64 {
+	if x != nil {
+		return x.AverageSpeedBps
+	}
+	return 0
+}
+
+func (x *Certificate) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *Certificate) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *Certificate) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+var File_quickwipe_proto protoreflect.FileDescriptor
+
+const file_quickwipe_proto_rawDesc = "" +
+	"\n" +
+	"\x0fquickwipe.proto\x12\fquickwipe.v1\"\xab\x03\n" +
+	"\x10StartWipeRequest\x12\x16\n" +
+	"\x06device\x18\x01 \x01(\tR\x06device\x12$\n" +
+	"\vbuffer_size\x18\x02 \x01(\x03H\x00R\n" +
+	"bufferSize\x88\x01\x01\x12$\n" +
+	"\vskip_factor\x18\x03 \x01(\x05H\x01R\n" +
+	"skipFactor\x88\x01\x01\x12\x1f\n" +
+	"\bcoverage\x18\x04 \x01(\x01H\x02R\bcoverage\x88\x01\x01\x12*\n" +
+	"\x0erandom_refresh\x18\x05 \x01(\x05H\x03R\rrandomRefresh\x88\x01\x01\x12*\n" +
+	"\x0ediscard_verify\x18\x06 \x01(\bH\x04R\rdiscardVerify\x88\x01\x01\x12 \n" +
+	"\tauto_skip\x18\a \x01(\bH\x05R\bautoSkip\x88\x01\x01\x12&\n" +
+	"\ftarget_hours\x18\b \x01(\x01H\x06R\vtargetHours\x88\x01\x01B\x0e\n" +
+	"\f_buffer_sizeB\x0e\n" +
+	"\f_skip_factorB\v\n" +
+	"\t_coverageB\x11\n" +
+	"\x0f_random_refreshB\x11\n" +
+	"\x0f_discard_verifyB\f\n" +
+	"\n" +
+	"_auto_skipB\x0f\n" +
+	"\r_target_hours\"\x1c\n" +
+	"\n" +
+	"JobRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\x88\x02\n" +
+	"\x03Job\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06device\x18\x02 \x01(\tR\x06device\x12\x16\n" +
+	"\x06serial\x18\x03 \x01(\tR\x06serial\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x122\n" +
+	"\bprogress\x18\x06 \x01(\v2\x16.quickwipe.v1.ProgressR\bprogress\x12!\n" +
+	"\fsubmitted_at\x18\a \x01(\tR\vsubmittedAt\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\b \x01(\tR\tstartedAt\x12\x1f\n" +
+	"\vfinished_at\x18\t \x01(\tR\n" +
+	"finishedAt\"\xd9\x01\n" +
+	"\bProgress\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x14\n" +
+	"\x05state\x18\x02 \x01(\tR\x05state\x12'\n" +
+	"\x0fbytes_processed\x18\x03 \x01(\x03R\x0ebytesProcessed\x12#\n" +
+	"\rbytes_written\x18\x04 \x01(\x03R\fbytesWritten\x12\x14\n" +
+	"\x05total\x18\x05 \x01(\x03R\x05total\x12\x1b\n" +
+	"\tspeed_bps\x18\x06 \x01(\x01R\bspeedBps\x12\x1f\n" +
+	"\veta_seconds\x18\a \x01(\x03R\n" +
+	"etaSeconds\"\xff\x02\n" +
+	"\vCertificate\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x16\n" +
+	"\x06device\x18\x02 \x01(\tR\x06device\x12\x16\n" +
+	"\x06serial\x18\x03 \x01(\tR\x06serial\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06method\x18\x05 \x01(\tR\x06method\x12\x1a\n" +
+	"\bcoverage\x18\x06 \x01(\x01R\bcoverage\x12#\n" +
+	"\rbytes_written\x18\a \x01(\x03R\fbytesWritten\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\b \x01(\tR\tstartedAt\x12\x1f\n" +
+	"\vfinished_at\x18\t \x01(\tR\n" +
+	"finishedAt\x12*\n" +
+	"\x11average_speed_bps\x18\n" +
+	" \x01(\x01R\x0faverageSpeedBps\x12\x1a\n" +
+	"\bhostname\x18\v \x01(\tR\bhostname\x12\x1a\n" +
+	"\boperator\x18\f \x01(\tR\boperator\x12\x18\n" +
+	"\aversion\x18\r \x01(\tR\aversion2\xc6\x02\n" +
+	"\x05Wiper\x12>\n" +
+	"\tStartWipe\x12\x1e.quickwipe.v1.StartWipeRequest\x1a\x11.quickwipe.v1.Job\x125\n" +
+	"\x06GetJob\x12\x18.quickwipe.v1.JobRequest\x1a\x11.quickwipe.v1.Job\x12D\n" +
+	"\x0eStreamProgress\x12\x18.quickwipe.v1.JobRequest\x1a\x16.quickwipe.v1.Progress0\x01\x129\n" +
+	"\n" +
+	"CancelWipe\x12\x18.quickwipe.v1.JobRequest\x1a\x11.quickwipe.v1.Job\x12E\n" +
+	"\x0eGetCertificate\x12\x18.quickwipe.v1.JobRequest\x1a\x19.quickwipe.v1.CertificateB\"Z github.com/f0o/quickwipe/rpc;rpcb\x06proto3"
+
+var (
+	file_quickwipe_proto_rawDescOnce sync.Once
+	file_quickwipe_proto_rawDescData []byte
+)
+
+func file_quickwipe_proto_rawDescGZIP() []byte {
+	file_quickwipe_proto_rawDescOnce.Do(func() {
+		file_quickwipe_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_quickwipe_proto_rawDesc), len(file_quickwipe_proto_rawDesc)))
+	})
+	return file_quickwipe_proto_rawDescData
+}
+
+var file_quickwipe_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_quickwipe_proto_goTypes = []any{
+	(*StartWipeRequest)(nil), // 0: quickwipe.v1.StartWipeRequest
+	(*JobRequest)(nil),       // 1: quickwipe.v1.JobRequest
+	(*Job)(nil),              // 2: quickwipe.v1.Job
+	(*Progress)(nil),         // 3: quickwipe.v1.Progress
+	(*Certificate)(nil),      // 4: quickwipe.v1.Certificate
+}
+var file_quickwipe_proto_depIdxs = []int32{
+	3, // 0: quickwipe.v1.Job.progress:type_name -> quickwipe.v1.Progress
+	0, // 1: quickwipe.v1.Wiper.StartWipe:input_type -> quickwipe.v1.StartWipeRequest
+	1, // 2: quickwipe.v1.Wiper.GetJob:input_type -> quickwipe.v1.JobRequest
+	1, // 3: quickwipe.v1.Wiper.StreamProgress:input_type -> quickwipe.v1.JobRequest
+	1, // 4: quickwipe.v1.Wiper.CancelWipe:input_type -> quickwipe.v1.JobRequest
+	1, // 5: quickwipe.v1.Wiper.GetCertificate:input_type -> quickwipe.v1.JobRequest
+	2, // 6: quickwipe.v1.Wiper.StartWipe:output_type -> quickwipe.v1.Job
+	2, // 7: quickwipe.v1.Wiper.GetJob:output_type -> quickwipe.v1.Job
+	3, // 8: quickwipe.v1.Wiper.StreamProgress:output_type -> quickwipe.v1.Progress
+	2, // 9: quickwipe.v1.Wiper.CancelWipe:output_type -> quickwipe.v1.Job
+	4, // 10: quickwipe.v1.Wiper.GetCertificate:output_type -> quickwipe.v1.Certificate
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_quickwipe_proto_init() }
+func file_quickwipe_proto_init() {
+	if File_quickwipe_proto != nil {
+		return
+	}
+	file_quickwipe_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_quickwipe_proto_rawDesc), len(file_quickwipe_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_quickwipe_proto_goTypes,
+		DependencyIndexes: file_quickwipe_proto_depIdxs,
+		MessageInfos:      file_quickwipe_proto_msgTypes,
+	}.Build()
+	File_quickwipe_proto = out.File
+	file_quickwipe_proto_goTypes = nil
+	file_quickwipe_proto_depIdxs = nil
+}
@@ -0,0 +1,277 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: quickwipe.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Wiper_StartWipe_FullMethodName      = "/quickwipe.v1.Wiper/StartWipe"
+	Wiper_GetJob_FullMethodName         = "/quickwipe.v1.Wiper/GetJob"
+	Wiper_StreamProgress_FullMethodName = "/quickwipe.v1.Wiper/StreamProgress"
+	Wiper_CancelWipe_FullMethodName     = "/quickwipe.v1.Wiper/CancelWipe"
+	Wiper_GetCertificate_FullMethodName = "/quickwipe.v1.Wiper/GetCertificate"
+)
+
+// WiperClient is the client API for Wiper service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type WiperClient interface {
+	StartWipe(ctx context.Context, in *StartWipeRequest, opts ...grpc.CallOption) (*Job, error)
+	GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	StreamProgress(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error)
+	CancelWipe(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error)
+	GetCertificate(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Certificate, error)
+}
+
+type wiperClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWiperClient(cc grpc.ClientConnInterface) WiperClient {
+	return &wiperClient{cc}
+}
+
+func (c *wiperClient) StartWipe(ctx context.Context, in *StartWipeRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Wiper_StartWipe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiperClient) GetJob(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Wiper_GetJob_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiperClient) StreamProgress(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Progress], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Wiper_ServiceDesc.Streams[0], Wiper_StreamProgress_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[JobRequest, Progress]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Wiper_StreamProgressClient = grpc.ServerStreamingClient[Progress]
+
+func (c *wiperClient) CancelWipe(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Job, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Job)
+	err := c.cc.Invoke(ctx, Wiper_CancelWipe_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *wiperClient) GetCertificate(ctx context.Context, in *JobRequest, opts ...grpc.CallOption) (*Certificate, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Certificate)
+	err := c.cc.Invoke(ctx, Wiper_GetCertificate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WiperServer is the server API for Wiper service.
+// All implementations must embed UnimplementedWiperServer
+// for forward compatibility.
+type WiperServer interface {
+	StartWipe(context.Context, *StartWipeRequest) (*Job, error)
+	GetJob(context.Context, *JobRequest) (*Job, error)
+	StreamProgress(*JobRequest, grpc.ServerStreamingServer[Progress]) error
+	CancelWipe(context.Context, *JobRequest) (*Job, error)
+	GetCertificate(context.Context, *JobRequest) (*Certificate, error)
+	mustEmbedUnimplementedWiperServer()
+}
+
+// UnimplementedWiperServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWiperServer struct{}
+
+func (UnimplementedWiperServer) StartWipe(context.Context, *StartWipeRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method StartWipe not implemented")
+}
+func (UnimplementedWiperServer) GetJob(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetJob not implemented")
+}
+func (UnimplementedWiperServer) StreamProgress(*JobRequest, grpc.ServerStreamingServer[Progress]) error {
+	return status.Error(codes.Unimplemented, "method StreamProgress not implemented")
+}
+func (UnimplementedWiperServer) CancelWipe(context.Context, *JobRequest) (*Job, error) {
+	return nil, status.Error(codes.Unimplemented, "method CancelWipe not implemented")
+}
+func (UnimplementedWiperServer) GetCertificate(context.Context, *JobRequest) (*Certificate, error) {
+	return nil, status.Error(codes.Unimplemented, "method GetCertificate not implemented")
+}
+func (UnimplementedWiperServer) mustEmbedUnimplementedWiperServer() {}
+func (UnimplementedWiperServer) testEmbeddedByValue()               {}
+
+// UnsafeWiperServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WiperServer will
+// result in compilation errors.
+type UnsafeWiperServer interface {
+	mustEmbedUnimplementedWiperServer()
+}
+
+func RegisterWiperServer(s grpc.ServiceRegistrar, srv WiperServer) {
+	// If the following call panics, it indicates UnimplementedWiperServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Wiper_ServiceDesc, srv)
+}
+
+func _Wiper_StartWipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartWipeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiperServer).StartWipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Wiper_StartWipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiperServer).StartWipe(ctx, req.(*StartWipeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiper_GetJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiperServer).GetJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Wiper_GetJob_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiperServer).GetJob(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiper_StreamProgress_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(JobRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(WiperServer).StreamProgress(m, &grpc.GenericServerStream[JobRequest, Progress]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Wiper_StreamProgressServer = grpc.ServerStreamingServer[Progress]
+
+func _Wiper_CancelWipe_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiperServer).CancelWipe(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Wiper_CancelWipe_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiperServer).CancelWipe(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wiper_GetCertificate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(JobRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WiperServer).GetCertificate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Wiper_GetCertificate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WiperServer).GetCertificate(ctx, req.(*JobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Wiper_ServiceDesc is the grpc.ServiceDesc for Wiper service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Wiper_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "quickwipe.v1.Wiper",
+	HandlerType: (*WiperServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartWipe",
+			Handler:    _Wiper_StartWipe_Handler,
+		},
+		{
+			MethodName: "GetJob",
+			Handler:    _Wiper_GetJob_Handler,
+		},
+		{
+			MethodName: "CancelWipe",
+			Handler:    _Wiper_CancelWipe_Handler,
+		},
+		{
+			MethodName: "GetCertificate",
+			Handler:    _Wiper_GetCertificate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamProgress",
+			Handler:       _Wiper_StreamProgress_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "quickwipe.proto",
+}